@@ -0,0 +1,5 @@
+// Package v1alpha1 holds the kongo.ciroque.io custom resources, which configure Kong Routes, Plugins and Upstreams
+// beyond what fits in Service annotations.
+// +kubebuilder:object:generate=true
+// +groupName=kongo.ciroque.io
+package v1alpha1
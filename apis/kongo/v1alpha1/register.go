@@ -0,0 +1,32 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const GroupName = "kongo.ciroque.io"
+
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1alpha1"}
+
+var (
+	KongoRouteResource          = SchemeGroupVersion.WithResource("kongoroutes")
+	KongoPluginResource         = SchemeGroupVersion.WithResource("kongoplugins")
+	KongoUpstreamPolicyResource = SchemeGroupVersion.WithResource("kongoupstreampolicies")
+)
+
+var (
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+	AddToScheme   = SchemeBuilder.AddToScheme
+)
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion,
+		&KongoRoute{}, &KongoRouteList{},
+		&KongoPlugin{}, &KongoPluginList{},
+		&KongoUpstreamPolicy{}, &KongoUpstreamPolicyList{},
+	)
+	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
+	return nil
+}
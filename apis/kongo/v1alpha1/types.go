@@ -0,0 +1,160 @@
+package v1alpha1
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KongoRoute exposes a Kubernetes Service through a Kong Upstream, Service and Route.
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=kr
+// +kubebuilder:printcolumn:name="Route ID",type=string,JSONPath=`.status.routeID`
+// +kubebuilder:printcolumn:name="Error",type=string,JSONPath=`.status.syncError`
+type KongoRoute struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KongoRouteSpec   `json:"spec"`
+	Status KongoRouteStatus `json:"status,omitempty"`
+}
+
+type KongoRouteSpec struct {
+	Backend KongoRouteBackend `json:"backend"`
+	// +kubebuilder:validation:MinItems=1
+	Paths []string `json:"paths"`
+	// +optional
+	Hosts []string `json:"hosts,omitempty"`
+	// +optional
+	Methods []string `json:"methods,omitempty"`
+	// +optional
+	Protocols []string `json:"protocols,omitempty"`
+	// +optional
+	StripPath bool `json:"stripPath,omitempty"`
+	// Plugins names KongoPlugins in the same namespace, applied to the Route.
+	// +optional
+	Plugins []string `json:"plugins,omitempty"`
+	// UpstreamPolicy names a KongoUpstreamPolicy in the same namespace.
+	// +optional
+	UpstreamPolicy string `json:"upstreamPolicy,omitempty"`
+}
+
+type KongoRouteBackend struct {
+	ServiceName string `json:"serviceName"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	ServicePort int32 `json:"servicePort"`
+}
+
+type KongoRouteStatus struct {
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +optional
+	UpstreamID string `json:"upstreamID,omitempty"`
+	// +optional
+	ServiceID string `json:"serviceID,omitempty"`
+	// +optional
+	RouteID string `json:"routeID,omitempty"`
+	// PluginIDs maps the names of the KongoPlugins to the IDs of the Kong Plugins created for them.
+	// +optional
+	PluginIDs map[string]string `json:"pluginIDs,omitempty"`
+	// +optional
+	SyncError string `json:"syncError,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+type KongoRouteList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KongoRoute `json:"items"`
+}
+
+// KongoPlugin configures a Kong plugin for the KongoRoutes listing it.
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=kp
+// +kubebuilder:printcolumn:name="Plugin",type=string,JSONPath=`.spec.plugin`
+// +kubebuilder:printcolumn:name="Error",type=string,JSONPath=`.status.syncError`
+type KongoPlugin struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KongoPluginSpec   `json:"spec"`
+	Status KongoPluginStatus `json:"status,omitempty"`
+}
+
+type KongoPluginSpec struct {
+	// Plugin is the name of the Kong plugin, e.g. rate-limiting.
+	Plugin string `json:"plugin"`
+	// +optional
+	Config *apiextensionsv1.JSON `json:"config,omitempty"`
+}
+
+type KongoPluginStatus struct {
+	// PluginIDs are the IDs of the Kong Plugins created for the KongoRoutes listing this KongoPlugin.
+	// +optional
+	PluginIDs []string `json:"pluginIDs,omitempty"`
+	// +optional
+	SyncError string `json:"syncError,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+type KongoPluginList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KongoPlugin `json:"items"`
+}
+
+// KongoUpstreamPolicy configures the load balancing and health checking of the Upstreams of the KongoRoutes naming it.
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=kup
+// +kubebuilder:printcolumn:name="Algorithm",type=string,JSONPath=`.spec.algorithm`
+// +kubebuilder:printcolumn:name="Error",type=string,JSONPath=`.status.syncError`
+type KongoUpstreamPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KongoUpstreamPolicySpec   `json:"spec"`
+	Status KongoUpstreamPolicyStatus `json:"status,omitempty"`
+}
+
+type KongoUpstreamPolicySpec struct {
+	// +kubebuilder:validation:Enum=round-robin;consistent-hashing;least-connections
+	// +optional
+	Algorithm string `json:"algorithm,omitempty"`
+	// +optional
+	Healthcheck *KongoHealthcheck `json:"healthcheck,omitempty"`
+}
+
+type KongoHealthcheck struct {
+	// +kubebuilder:validation:Pattern=`^/`
+	Path string `json:"path"`
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Interval int `json:"interval,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Timeout int `json:"timeout,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	HealthyThreshold int `json:"healthyThreshold,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	UnhealthyThreshold int `json:"unhealthyThreshold,omitempty"`
+}
+
+type KongoUpstreamPolicyStatus struct {
+	// UpstreamIDs are the IDs of the Kong Upstreams of the KongoRoutes naming this policy.
+	// +optional
+	UpstreamIDs []string `json:"upstreamIDs,omitempty"`
+	// +optional
+	SyncError string `json:"syncError,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+type KongoUpstreamPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KongoUpstreamPolicy `json:"items"`
+}
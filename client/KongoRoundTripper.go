@@ -1,13 +1,24 @@
 package client
 
 import (
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type KongoRoundTripper struct {
-	headers []string
+	headers      []string
 	roundTripper http.RoundTripper
+	// limiter and breaker are nil unless configured.
+	limiter *rate.Limiter
+	breaker *CircuitBreaker
+	tracer  trace.Tracer
+	logger  Logger
+	auditor *auditor
+	// plan is set for dry runs.
+	plan *Plan
 }
 
 func (krt *KongoRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -23,5 +34,57 @@ func (krt *KongoRoundTripper) RoundTrip(req *http.Request) (*http.Response, erro
 			newRequest.Header[split[0]] = append([]string(nil), split[1])
 		}
 	}
-	return krt.roundTripper.RoundTrip(newRequest)
+
+	if krt.plan != nil {
+		return krt.dryRun(newRequest)
+	}
+	if krt.auditor != nil && isMutating(newRequest.Method) {
+		return krt.auditor.audit(newRequest, krt.traced)
+	}
+	return krt.traced(newRequest)
+}
+
+func (krt *KongoRoundTripper) traced(newRequest *http.Request) (*http.Response, error) {
+	if krt.tracer == nil {
+		return krt.send(newRequest)
+	}
+	spanRequest, span := startRequestSpan(krt.tracer, newRequest)
+	response, err := krt.send(spanRequest)
+	endRequestSpan(span, response, err)
+	return response, err
+}
+
+// send waits for the rate limiter and the circuit breaker, when configured, before sending the request.
+func (krt *KongoRoundTripper) send(newRequest *http.Request) (*http.Response, error) {
+	if krt.limiter != nil {
+		err := krt.limiter.Wait(newRequest.Context())
+		if err != nil {
+			return nil, err
+		}
+	}
+	probe := false
+	if krt.breaker != nil {
+		var err error
+		probe, err = krt.breaker.allow()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	response, err := krt.roundTripper.RoundTrip(newRequest)
+	observeAdminRequest(newRequest, response, err, start)
+	if krt.logger != nil {
+		if err != nil {
+			krt.logger.Debug("Kong Admin API request failed", "method", newRequest.Method, "url", newRequest.URL.String(),
+				"duration", time.Since(start), "error", err)
+		} else {
+			krt.logger.Debug("Kong Admin API request", "method", newRequest.Method, "url", newRequest.URL.String(),
+				"status", response.StatusCode, "duration", time.Since(start))
+		}
+	}
+	if krt.breaker != nil {
+		krt.breaker.record(probe, response, err)
+	}
+	return response, err
 }
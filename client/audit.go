@@ -0,0 +1,278 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditRecord is a request to Kong that changed, or tried to change, an entity. Before and After are the entity, with
+// the ScrubbedFields scrubbed, as Kong had it before the request and as Kong answered it; Before is empty for creations
+// and After for deletions and requests that failed.
+type AuditRecord struct {
+	Time     time.Time `json:"time"`
+	Operator string    `json:"operator"`
+	Method   string    `json:"method"`
+	Url      string    `json:"url"`
+	Kind     string    `json:"kind"`
+	ID       string    `json:"id,omitempty"`
+	Name     string    `json:"name,omitempty"`
+	// Status is the status code of the response, 0 when Kong could not be reached.
+	Status int                 `json:"status"`
+	Error  string              `json:"error,omitempty"`
+	Before jsoniter.RawMessage `json:"before,omitempty"`
+	After  jsoniter.RawMessage `json:"after,omitempty"`
+}
+
+// AuditSink keeps audit records. Write is called concurrently by bulk operations.
+type AuditSink interface {
+	Write(record *AuditRecord) error
+}
+
+// WithAudit writes a record of every POST, PUT, PATCH and DELETE to Kong, made on behalf of the operator, to the sinks.
+// A sink failing to write a record is logged, the request having been made already. The entity a PUT, PATCH or DELETE
+// changes is read from Kong beforehand, costing a GET for each of them.
+func WithAudit(operator string, sinks ...AuditSink) Option {
+	return func(settings *kongoSettings) error {
+		if len(sinks) == 0 {
+			return fmt.Errorf("auditing requires at least one sink")
+		}
+		settings.auditor = &auditor{operator: operator, sinks: sinks}
+		return nil
+	}
+}
+
+// FileAuditSink appends records to a file, one JSON object per line.
+type FileAuditSink struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening audit file '%s': %v", path, err)
+	}
+	return &FileAuditSink{file: file}, nil
+}
+
+func (sink *FileAuditSink) Write(record *AuditRecord) error {
+	line, err := jsoniter.Marshal(record)
+	if err != nil {
+		return err
+	}
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	_, err = sink.file.Write(append(line, '\n'))
+	return err
+}
+
+func (sink *FileAuditSink) Close() error {
+	return sink.file.Close()
+}
+
+// DefaultWebhookQueueSize is how many records a WebhookAuditSink holds while they wait to be posted.
+const DefaultWebhookQueueSize = 1000
+
+// WebhookAuditSink POSTs each record, as JSON, to a Url. Records are posted one at a time in the background, for a slow
+// webhook not to slow down the requests to Kong; records written while the queue is full are dropped. Close posts the
+// records still queued.
+type WebhookAuditSink struct {
+	url        string
+	httpClient *http.Client
+	logger     Logger
+	mutex      sync.Mutex
+	queue      chan *AuditRecord
+	closed     bool
+	done       chan struct{}
+}
+
+// NewWebhookAuditSink starts posting the records to the Url, queueing up to queueSize of them. Records that could not
+// be posted are logged with the logger, which may be nil.
+func NewWebhookAuditSink(url string, queueSize int, logger Logger) *WebhookAuditSink {
+	if logger == nil {
+		logger = discardLogger{}
+	}
+	sink := &WebhookAuditSink{
+		url:        url,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		logger:     logger,
+		queue:      make(chan *AuditRecord, queueSize),
+		done:       make(chan struct{}),
+	}
+	go sink.run()
+	return sink
+}
+
+// Write queues the record, failing when the queue is full or the sink closed.
+func (sink *WebhookAuditSink) Write(record *AuditRecord) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.closed {
+		return fmt.Errorf("audit webhook '%s' is closed, record dropped", sink.url)
+	}
+	select {
+	case sink.queue <- record:
+		return nil
+	default:
+		return fmt.Errorf("audit webhook '%s' has %d records queued already, record dropped", sink.url, cap(sink.queue))
+	}
+}
+
+// Close waits for the records queued to be posted.
+func (sink *WebhookAuditSink) Close() error {
+	sink.mutex.Lock()
+	if !sink.closed {
+		sink.closed = true
+		close(sink.queue)
+	}
+	sink.mutex.Unlock()
+	<-sink.done
+	return nil
+}
+
+func (sink *WebhookAuditSink) run() {
+	defer close(sink.done)
+	for record := range sink.queue {
+		err := sink.post(record)
+		if err != nil {
+			sink.logger.Error("posting audit record failed", "method", record.Method, "url", record.Url, "error", err)
+		}
+	}
+}
+
+func (sink *WebhookAuditSink) post(record *AuditRecord) error {
+	body, err := jsoniter.Marshal(record)
+	if err != nil {
+		return err
+	}
+	response, err := sink.httpClient.Post(sink.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error posting audit record to '%s': %v", sink.url, err)
+	}
+	defer response.Body.Close()
+	ioutil.ReadAll(response.Body)
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("error posting audit record to '%s': %s", sink.url, response.Status)
+	}
+	return nil
+}
+
+type auditor struct {
+	operator string
+	sinks    []AuditSink
+	logger   Logger
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// audit sends the request, loading the entity it changes beforehand, and writes its record to the sinks.
+func (auditor *auditor) audit(req *http.Request, send func(req *http.Request) (*http.Response, error)) (*http.Response, error) {
+	record := &AuditRecord{
+		Time:     time.Now().UTC(),
+		Operator: auditor.operator,
+		Method:   req.Method,
+		Url:      req.URL.String(),
+		Kind:     strings.TrimSuffix(adminKind(req.URL.Path), "s"),
+	}
+	if req.Method != http.MethodPost {
+		record.Before = auditPayload(loadEntity(req, send))
+	}
+
+	response, err := send(req)
+	if err != nil {
+		record.Error = err.Error()
+	} else {
+		record.Status = response.StatusCode
+		body, readErr := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if readErr != nil {
+			return nil, readErr
+		}
+		response.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		switch {
+		case response.StatusCode >= 300:
+			record.Error = strings.TrimSpace(scrubBody(body))
+		case req.Method != http.MethodDelete:
+			record.After = auditPayload(body)
+		}
+	}
+	record.ID, record.Name = identify(req, record.After, record.Before)
+
+	for _, sink := range auditor.sinks {
+		writeErr := sink.Write(record)
+		if writeErr != nil {
+			auditor.logger.Error("writing audit record failed", "method", record.Method, "url", record.Url,
+				"error", writeErr)
+		}
+	}
+	return response, err
+}
+
+// loadEntity gives the entity at the Url of the request, nil when Kong has none there.
+func loadEntity(req *http.Request, send func(req *http.Request) (*http.Response, error)) []byte {
+	getRequest, err := http.NewRequestWithContext(req.Context(), http.MethodGet, req.URL.String(), nil)
+	if err != nil {
+		return nil
+	}
+	getRequest.Header = req.Header.Clone()
+	response, err := send(getRequest)
+	if err != nil {
+		return nil
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil || response.StatusCode != http.StatusOK {
+		return nil
+	}
+	return body
+}
+
+// auditPayload scrubs a JSON body, nil when the body is not JSON.
+func auditPayload(body []byte) jsoniter.RawMessage {
+	if len(body) == 0 || !jsoniter.Valid(body) {
+		return nil
+	}
+	return jsoniter.RawMessage(scrubBody(body))
+}
+
+// identify gives the ID and name of the entity of a request after the first of its payloads naming it, or its name
+// after the last segment of its Url.
+func identify(req *http.Request, payloads ...[]byte) (string, string) {
+	for _, payload := range payloads {
+		if payload == nil {
+			continue
+		}
+		var entity struct {
+			ID     string `json:"id"`
+			Name   string `json:"name"`
+			Target string `json:"target"`
+		}
+		if jsoniter.Unmarshal(payload, &entity) == nil && entity.ID != "" {
+			if entity.Name == "" {
+				return entity.ID, entity.Target
+			}
+			return entity.ID, entity.Name
+		}
+	}
+
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	last := segments[len(segments)-1]
+	if req.Method != http.MethodPost && last != "" && !adminKinds[last] {
+		return "", last
+	}
+	return "", ""
+}
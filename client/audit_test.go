@@ -0,0 +1,197 @@
+package client
+
+import (
+	"bufio"
+	"github.com/ciroque/kongo/client/kongotest"
+	"github.com/hbagdi/go-kong/kong"
+	jsoniter "github.com/json-iterator/go"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingSink struct {
+	mutex   sync.Mutex
+	records []*AuditRecord
+}
+
+func (sink *recordingSink) Write(record *AuditRecord) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.records = append(sink.records, record)
+	return nil
+}
+
+func TestAudit(t *testing.T) {
+	server := kongotest.NewServer()
+	defer server.Close()
+
+	sink := &recordingSink{}
+	kongo, err := NewKongo(&server.URL, WithAudit("jane", sink))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := kongo.CreateService(&ServiceDef{Name: "kongo.audited.service", Host: "audited", Path: "/", Port: 80, Protocol: "http"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin, err := kongo.CreatePlugin(&PluginDef{Name: "key-auth", Config: kong.Configuration{"secret": "s3cret"}, Service: service})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = kongo.ListServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = kongo.DeleteService(*service.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sink.records) != 3 {
+		t.Fatalf("Expected the 2 creations and the deletion to be audited, got %d records", len(sink.records))
+	}
+	created, pluginCreated, deleted := sink.records[0], sink.records[1], sink.records[2]
+
+	if created.Operator != "jane" || created.Method != http.MethodPost || created.Kind != "service" ||
+		created.ID != *service.ID || created.Name != "kongo.audited.service" || created.Status != http.StatusCreated {
+		t.Errorf("Unexpected record of the creation of the Service: %+v", created)
+	}
+	if created.Before != nil || created.After == nil || created.Time.IsZero() {
+		t.Errorf("Expected the creation to have an after payload only, got: %+v", created)
+	}
+
+	if pluginCreated.Kind != "plugin" || pluginCreated.ID != *plugin.ID {
+		t.Errorf("Unexpected record of the creation of the Plugin: %+v", pluginCreated)
+	}
+	if strings.Contains(string(pluginCreated.After), "s3cret") || !strings.Contains(string(pluginCreated.After), scrubbedValue) {
+		t.Errorf("Expected the secret of the Plugin to be scrubbed, got: %s", pluginCreated.After)
+	}
+
+	if deleted.Method != http.MethodDelete || deleted.ID != *service.ID || deleted.Name != "kongo.audited.service" {
+		t.Errorf("Unexpected record of the deletion of the Service: %+v", deleted)
+	}
+	if deleted.Before == nil || deleted.After != nil {
+		t.Errorf("Expected the deletion to have a before payload only, got: %+v", deleted)
+	}
+}
+
+func TestAuditSinks(t *testing.T) {
+	record := &AuditRecord{
+		Time:     time.Now().UTC(),
+		Operator: "jane",
+		Method:   http.MethodDelete,
+		Url:      "http://localhost:8001/services/kongo.audited.service",
+		Kind:     "service",
+		Name:     "kongo.audited.service",
+		Status:   http.StatusNoContent,
+		Before:   jsoniter.RawMessage(`{"name":"kongo.audited.service"}`),
+	}
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		sink, err := NewFileAuditSink(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			err = sink.Write(record)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		sink.Close()
+
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		lines := 0
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			written := &AuditRecord{}
+			err = jsoniter.Unmarshal(scanner.Bytes(), written)
+			if err != nil || written.Name != record.Name || string(written.Before) != string(record.Before) {
+				t.Errorf("Unexpected line: %s", scanner.Text())
+			}
+			lines++
+		}
+		if lines != 2 {
+			t.Fatalf("Expected a line per record, got %d", lines)
+		}
+	})
+
+	t.Run("webhook", func(t *testing.T) {
+		posted := make(chan *AuditRecord, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			written := &AuditRecord{}
+			jsoniter.NewDecoder(request.Body).Decode(written)
+			posted <- written
+		}))
+		defer server.Close()
+
+		sink := NewWebhookAuditSink(server.URL, DefaultWebhookQueueSize, nil)
+		err := sink.Write(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if written := <-posted; written.Operator != "jane" || written.Method != http.MethodDelete {
+			t.Fatalf("Unexpected record posted: %+v", written)
+		}
+		sink.Close()
+		if sink.Write(record) == nil {
+			t.Fatal("Expected a closed webhook sink to fail the write")
+		}
+
+		failing := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer failing.Close()
+		failingSink := NewWebhookAuditSink(failing.URL, DefaultWebhookQueueSize, nil)
+		defer failingSink.Close()
+		if failingSink.post(record) == nil {
+			t.Fatal("Expected a webhook answering 500 to fail the post")
+		}
+	})
+
+	t.Run("webhook queue full", func(t *testing.T) {
+		var mutex sync.Mutex
+		posted := 0
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			<-release
+			mutex.Lock()
+			posted++
+			mutex.Unlock()
+		}))
+		defer server.Close()
+
+		// The first record is being posted, the next two wait in the queue and the last is dropped.
+		sink := NewWebhookAuditSink(server.URL, 2, nil)
+		err := sink.Write(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for len(sink.queue) != 0 {
+			time.Sleep(time.Millisecond)
+		}
+		dropped := 0
+		for i := 0; i < 3; i++ {
+			if sink.Write(record) != nil {
+				dropped++
+			}
+		}
+		close(release)
+		sink.Close()
+		if dropped != 1 || posted != 3 {
+			t.Fatalf("Expected a record to be dropped and 3 to be posted, got %d dropped and %d posted", dropped, posted)
+		}
+	})
+}
@@ -0,0 +1,462 @@
+package client
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/hbagdi/go-kong/kong"
+	jsoniter "github.com/json-iterator/go"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type ConflictStrategy string
+
+const (
+	ConflictSkip      ConflictStrategy = "skip"
+	ConflictOverwrite ConflictStrategy = "overwrite"
+	ConflictFail      ConflictStrategy = "fail"
+)
+
+func ParseConflictStrategy(value string) (ConflictStrategy, error) {
+	switch strategy := ConflictStrategy(value); strategy {
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown conflict strategy '%s', expected one of skip, overwrite or fail", value)
+}
+
+const snapshotTimeFormat = "20060102T150405Z"
+
+type snapshotFile struct {
+	name   string
+	entity func(state *KongState) interface{}
+	// optional files are missing from the snapshots written before their kind was backed up.
+	optional bool
+}
+
+var snapshotFiles = []snapshotFile{
+	{"upstreams.json", func(state *KongState) interface{} { return &state.Upstreams }, false},
+	{"targets.json", func(state *KongState) interface{} { return &state.Targets }, false},
+	{"services.json", func(state *KongState) interface{} { return &state.Services }, false},
+	{"routes.json", func(state *KongState) interface{} { return &state.Routes }, false},
+	{"plugins.json", func(state *KongState) interface{} { return &state.Plugins }, true},
+	{"certificates.json", func(state *KongState) interface{} { return &state.Certificates }, true},
+}
+
+// Backup writes every entity within Kong, IDs included, to a new timestamped directory under baseDir and returns its path.
+func (kongo *Kongo) Backup(baseDir string, compress bool) (string, error) {
+	state, err := kongo.LoadKongState()
+	if err != nil {
+		return "", fmt.Errorf("error loading Kong state for backup: %v", err)
+	}
+
+	dir, err := newSnapshotDir(baseDir, time.Now())
+	if err != nil {
+		return "", err
+	}
+	err = WriteSnapshot(dir, state, compress)
+	if err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// newSnapshotDir creates the directory of a backup taken at the time, suffixed with a sequence number when backups were
+// taken within the same second, for no backup to overwrite another.
+func newSnapshotDir(baseDir string, at time.Time) (string, error) {
+	err := os.MkdirAll(baseDir, 0755)
+	if err != nil {
+		return "", fmt.Errorf("error creating backup directory '%s': %v", baseDir, err)
+	}
+
+	name := "kongo-" + at.UTC().Format(snapshotTimeFormat)
+	dir := filepath.Join(baseDir, name)
+	for sequence := 2; ; sequence++ {
+		err = os.Mkdir(dir, 0755)
+		if err == nil {
+			return dir, nil
+		}
+		if !os.IsExist(err) {
+			return "", fmt.Errorf("error creating snapshot directory '%s': %v", dir, err)
+		}
+		dir = filepath.Join(baseDir, fmt.Sprintf("%s-%d", name, sequence))
+	}
+}
+
+func WriteSnapshot(dir string, state *KongState, compress bool) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("error creating snapshot directory '%s': %v", dir, err)
+	}
+
+	for _, file := range snapshotFiles {
+		path := filepath.Join(dir, file.name)
+		if compress {
+			path += ".gz"
+		}
+		err = writeSnapshotFile(path, file.entity(state), compress)
+		if err != nil {
+			return fmt.Errorf("error writing snapshot file '%s': %v", path, err)
+		}
+	}
+
+	return nil
+}
+
+// writeSnapshotFile only succeeds once the file is closed, for a backup that could not be flushed not to be taken for
+// written.
+func writeSnapshotFile(path string, entities interface{}, compress bool) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = encodeSnapshotFile(file, entities, compress)
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+func encodeSnapshotFile(file io.Writer, entities interface{}, compress bool) error {
+	if !compress {
+		return jsoniter.NewEncoder(file).Encode(entities)
+	}
+	gzipWriter := gzip.NewWriter(file)
+	err := jsoniter.NewEncoder(gzipWriter).Encode(entities)
+	closeErr := gzipWriter.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// ReadSnapshot reads a directory written by WriteSnapshot, compressed or not.
+func ReadSnapshot(dir string) (*KongState, error) {
+	state := new(KongState)
+
+	for _, file := range snapshotFiles {
+		path := filepath.Join(dir, file.name)
+		compressed := false
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			path += ".gz"
+			compressed = true
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) && file.optional {
+			continue
+		}
+		err := readSnapshotFile(path, file.entity(state), compressed)
+		if err != nil {
+			return nil, fmt.Errorf("error reading snapshot file '%s': %v", path, err)
+		}
+	}
+
+	return state, nil
+}
+
+func readSnapshotFile(path string, entities interface{}, compressed bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if compressed {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	return jsoniter.NewDecoder(reader).Decode(entities)
+}
+
+func (kongo *Kongo) Restore(dir string, strategy ConflictStrategy) error {
+	state, err := ReadSnapshot(dir)
+	if err != nil {
+		return err
+	}
+	return kongo.RestoreKongState(state, strategy)
+}
+
+// RestoreKongState recreates the entities with their original IDs, Upstreams, Certificates and Services before the
+// Targets and Routes that refer to them, and Plugins last, the entities of each kind in parallel. Entities that already
+// exist, under the same ID or, on a Kong rebuilt since the backup, under the same name, are handled according to the
+// strategy; the entities referring to them are restored referring to the existing ones.
+func (kongo *Kongo) RestoreKongState(state *KongState, strategy ConflictStrategy) error {
+	ids := &restoredIds{ids: make(map[string]*string)}
+
+	existingCertificates, err := kongo.ListCertificates()
+	if err != nil {
+		return fmt.Errorf("error listing Certificates: %v", err)
+	}
+
+	upstreamTasks := []*Task{}
+	for _, upstream := range state.Upstreams {
+		upstream := upstream
+		upstreamTasks = append(upstreamTasks, &Task{Name: "Upstream " + *upstream.Name, Run: func() error {
+			existing, err := kongo.GetUpstream(*upstream.ID)
+			if kong.IsNotFoundErr(err) {
+				existing, err = kongo.GetUpstream(*upstream.Name)
+			}
+			if existing != nil {
+				ids.set(upstream.ID, existing.ID)
+			}
+			return resolveConflict("Upstream", *upstream.Name, existing != nil, err, strategy, func() error {
+				overwritten := *upstream
+				overwritten.ID = existing.ID
+				_, err := kongo.Kong.Upstreams.Update(kongo.context, &overwritten)
+				return err
+			}, func() error {
+				_, err := kongo.Kong.Upstreams.Create(kongo.context, upstream)
+				return err
+			})
+		}})
+	}
+	for _, certificate := range state.Certificates {
+		certificate := certificate
+		name := certificateName(certificate)
+		upstreamTasks = append(upstreamTasks, &Task{Name: "Certificate " + name, Run: func() error {
+			existing := sameCertificate(certificate, existingCertificates)
+			if existing != nil {
+				ids.set(certificate.ID, existing.ID)
+			}
+			return resolveConflict("Certificate", name, existing != nil, nil, strategy, func() error {
+				overwritten := *certificate
+				overwritten.ID = existing.ID
+				_, err := kongo.Kong.Certificates.Update(kongo.context, &overwritten)
+				return err
+			}, func() error {
+				_, err := kongo.Kong.Certificates.Create(kongo.context, certificate)
+				return err
+			})
+		}})
+	}
+	err = kongo.bulk().Run(kongo.context, upstreamTasks)
+	if err != nil {
+		return err
+	}
+
+	existingTargets := make(map[string]map[string]*kong.Target)
+	targetTasks := []*Task{}
+	for _, target := range state.Targets {
+		target := target
+		upstreamId := ids.get(target.Upstream.ID)
+		if _, loaded := existingTargets[*upstreamId]; !loaded {
+			targets, err := kongo.ListTargets(*upstreamId)
+			if err != nil {
+				return fmt.Errorf("error listing Targets for Upstream '%s': %v", *upstreamId, err)
+			}
+			existingTargets[*upstreamId] = make(map[string]*kong.Target)
+			for _, existing := range targets {
+				existingTargets[*upstreamId][*existing.Target] = existing
+			}
+		}
+
+		existing := existingTargets[*upstreamId][*target.Target]
+		targetTasks = append(targetTasks, &Task{Name: "Target " + *target.Target, Run: func() error {
+			return resolveConflict("Target", *target.Target, existing != nil, nil, strategy, func() error {
+				err := kongo.Kong.Targets.Delete(kongo.context, upstreamId, existing.ID)
+				if err != nil {
+					return err
+				}
+				_, err = kongo.Kong.Targets.Create(kongo.context, upstreamId, restorableTarget(target))
+				return err
+			}, func() error {
+				_, err := kongo.Kong.Targets.Create(kongo.context, upstreamId, restorableTarget(target))
+				return err
+			})
+		}})
+	}
+
+	serviceTasks := []*Task{}
+	for _, service := range state.Services {
+		service := service
+		serviceTasks = append(serviceTasks, &Task{Name: "Service " + *service.Name, Run: func() error {
+			existing, err := kongo.GetService(*service.ID)
+			if kong.IsNotFoundErr(err) {
+				existing, err = kongo.GetService(*service.Name)
+			}
+			if existing != nil {
+				ids.set(service.ID, existing.ID)
+			}
+			restored := *service
+			if service.ClientCertificate != nil {
+				restored.ClientCertificate = &kong.Certificate{ID: ids.get(service.ClientCertificate.ID)}
+			}
+			return resolveConflict("Service", *service.Name, existing != nil, err, strategy, func() error {
+				restored.ID = existing.ID
+				_, err := kongo.Kong.Services.Update(kongo.context, &restored)
+				return err
+			}, func() error {
+				_, err := kongo.Kong.Services.Create(kongo.context, &restored)
+				return err
+			})
+		}})
+	}
+
+	routeTasks := []*Task{}
+	for _, route := range state.Routes {
+		route := route
+		routeTasks = append(routeTasks, &Task{Name: "Route " + *route.Name, Run: func() error {
+			existing, err := kongo.GetRoute(*route.ID)
+			if kong.IsNotFoundErr(err) {
+				existing, err = kongo.GetRoute(*route.Name)
+			}
+			if existing != nil {
+				ids.set(route.ID, existing.ID)
+			}
+			restored := *route
+			if route.Service != nil {
+				restored.Service = &kong.Service{ID: ids.get(route.Service.ID)}
+			}
+			return resolveConflict("Route", *route.Name, existing != nil, err, strategy, func() error {
+				restored.ID = existing.ID
+				_, err := kongo.Kong.Routes.Update(kongo.context, &restored)
+				return err
+			}, func() error {
+				_, err := kongo.Kong.Routes.Create(kongo.context, &restored)
+				return err
+			})
+		}})
+	}
+
+	err = kongo.bulk().RunPhases(kongo.context, targetTasks, serviceTasks, routeTasks)
+	if err != nil {
+		return err
+	}
+
+	// Plugins go after the Services and Routes they are applied to, matched on those when their IDs are taken.
+	existingPlugins, err := kongo.ListPlugins()
+	if err != nil {
+		return fmt.Errorf("error listing Plugins: %v", err)
+	}
+	pluginTasks := []*Task{}
+	for _, plugin := range state.Plugins {
+		plugin := plugin
+		name := *plugin.Name + " " + *plugin.ID
+		pluginTasks = append(pluginTasks, &Task{Name: "Plugin " + name, Run: func() error {
+			restored := *plugin
+			if plugin.Service != nil {
+				restored.Service = &kong.Service{ID: ids.get(plugin.Service.ID)}
+			}
+			if plugin.Route != nil {
+				restored.Route = &kong.Route{ID: ids.get(plugin.Route.ID)}
+			}
+			existing := samePlugin(&restored, existingPlugins)
+			return resolveConflict("Plugin", name, existing != nil, nil, strategy, func() error {
+				restored.ID = existing.ID
+				_, err := kongo.Kong.Plugins.Update(kongo.context, &restored)
+				return err
+			}, func() error {
+				_, err := kongo.Kong.Plugins.Create(kongo.context, &restored)
+				return err
+			})
+		}})
+	}
+	return kongo.bulk().Run(kongo.context, pluginTasks)
+}
+
+// restoredIds maps the IDs of a backup to those of the entities already in Kong under another ID.
+type restoredIds struct {
+	mutex sync.Mutex
+	ids   map[string]*string
+}
+
+func (restoredIds *restoredIds) set(backupId *string, id *string) {
+	restoredIds.mutex.Lock()
+	defer restoredIds.mutex.Unlock()
+	restoredIds.ids[*backupId] = id
+}
+
+func (restoredIds *restoredIds) get(backupId *string) *string {
+	restoredIds.mutex.Lock()
+	defer restoredIds.mutex.Unlock()
+	if id, found := restoredIds.ids[*backupId]; found {
+		return id
+	}
+	return backupId
+}
+
+// certificateName names a Certificate after its first SNI, or its ID without any.
+func certificateName(certificate *kong.Certificate) string {
+	if len(certificate.SNIs) > 0 && certificate.SNIs[0] != nil {
+		return *certificate.SNIs[0]
+	}
+	return *certificate.ID
+}
+
+// sameCertificate finds the Certificate with the ID of the certificate, or else serving one of its SNIs.
+func sameCertificate(certificate *kong.Certificate, existingCertificates []*kong.Certificate) *kong.Certificate {
+	for _, existing := range existingCertificates {
+		if *existing.ID == *certificate.ID {
+			return existing
+		}
+	}
+	for _, existing := range existingCertificates {
+		for _, sni := range existing.SNIs {
+			for _, wanted := range certificate.SNIs {
+				if *sni == *wanted {
+					return existing
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// samePlugin finds the Plugin with the ID of the plugin, or else with its name applied to the same Route or Service,
+// which Kong allows once.
+func samePlugin(plugin *kong.Plugin, existingPlugins []*kong.Plugin) *kong.Plugin {
+	for _, existing := range existingPlugins {
+		if *existing.ID == *plugin.ID {
+			return existing
+		}
+	}
+	for _, existing := range existingPlugins {
+		if pluginKey(existing) == pluginKey(plugin) {
+			return existing
+		}
+	}
+	return nil
+}
+
+func restorableTarget(target *kong.Target) *kong.Target {
+	kongTarget := *target
+	kongTarget.CreatedAt = nil
+	kongTarget.Upstream = nil
+	return &kongTarget
+}
+
+func resolveConflict(kind string, name string, exists bool, lookupErr error, strategy ConflictStrategy, overwrite func() error, create func() error) error {
+	if lookupErr != nil && !kong.IsNotFoundErr(lookupErr) {
+		return fmt.Errorf("error looking up %s '%s': %v", kind, name, lookupErr)
+	}
+
+	var err error
+	if !exists {
+		err = create()
+	} else {
+		switch strategy {
+		case ConflictSkip:
+			return nil
+		case ConflictOverwrite:
+			err = overwrite()
+		default:
+			return fmt.Errorf("%s '%s' already exists", kind, name)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("error restoring %s '%s': %v", kind, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package client
+
+import (
+	"errors"
+	"github.com/ciroque/kongo/client/kongotest"
+	"github.com/hbagdi/go-kong/kong"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshots(t *testing.T) {
+	state := &KongState{
+		Services:  []*kong.Service{{ID: kong.String("s-1"), Name: kong.String("kongo.svc.service")}},
+		Routes:    []*kong.Route{{ID: kong.String("r-1"), Name: kong.String("kongo.svc.route"), Service: &kong.Service{ID: kong.String("s-1")}}},
+		Upstreams: []*kong.Upstream{{ID: kong.String("u-1"), Name: kong.String("kongo.svc.upstream")}},
+		Targets:   []*kong.Target{{ID: kong.String("t-1"), Target: kong.String("10.0.0.1:80"), Upstream: &kong.Upstream{ID: kong.String("u-1")}}},
+		Plugins:   []*kong.Plugin{{ID: kong.String("p-1"), Name: kong.String("cors"), Route: &kong.Route{ID: kong.String("r-1")}}},
+		Certificates: []*kong.Certificate{{ID: kong.String("c-1"), Cert: kong.String("cert"), Key: kong.String("key"),
+			SNIs: kong.StringSlice("svc.example.com")}},
+	}
+
+	for _, compress := range []bool{false, true} {
+		dir, err := ioutil.TempDir("", "kongo-snapshot")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		err = WriteSnapshot(dir, state, compress)
+		if err != nil {
+			t.Fatalf("Error writing snapshot (compress: %v): %v", compress, err)
+		}
+
+		_, err = os.Stat(filepath.Join(dir, "services.json.gz"))
+		if compress != (err == nil) {
+			t.Fatalf("Snapshot files should be gzipped only when compressing")
+		}
+
+		read, err := ReadSnapshot(dir)
+		if err != nil {
+			t.Fatalf("Error reading snapshot (compress: %v): %v", compress, err)
+		}
+
+		if *read.Routes[0].Service.ID != "s-1" || *read.Targets[0].Upstream.ID != "u-1" || *read.Plugins[0].Route.ID != "r-1" {
+			t.Fatalf("References between entities should survive the snapshot")
+		}
+		if len(read.Certificates) != 1 || *read.Certificates[0].Key != "key" {
+			t.Fatalf("Certificates should survive the snapshot")
+		}
+
+		// Snapshots written before Plugins and Certificates were backed up still read.
+		for _, name := range []string{"plugins.json", "certificates.json"} {
+			path := filepath.Join(dir, name)
+			if compress {
+				path += ".gz"
+			}
+			os.Remove(path)
+		}
+		read, err = ReadSnapshot(dir)
+		if err != nil || len(read.Plugins) != 0 || len(read.Routes) != 1 {
+			t.Fatalf("Error reading snapshot without Plugins (compress: %v): %v", compress, err)
+		}
+	}
+}
+
+func TestNewSnapshotDir(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "kongo-backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(baseDir)
+
+	at := time.Now()
+	first, err := newSnapshotDir(baseDir, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := newSnapshotDir(baseDir, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second || !strings.HasPrefix(filepath.Base(second), filepath.Base(first)+"-") {
+		t.Fatalf("Expected backups taken within the same second to have directories of their own, got: %s and %s", first, second)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestSnapshotFileFlushFailure(t *testing.T) {
+	// The gzip writer buffers the few entities, failing only once flushed on close.
+	err := encodeSnapshotFile(failingWriter{}, []*kong.Service{{Name: kong.String("kongo.svc.service")}}, true)
+	if err == nil {
+		t.Fatalf("Expected a snapshot file that could not be flushed to fail")
+	}
+}
+
+func TestParseConflictStrategy(t *testing.T) {
+	strategy, err := ParseConflictStrategy("overwrite")
+	if err != nil || strategy != ConflictOverwrite {
+		t.Fatalf("'overwrite' should parse, got: %v, %v", strategy, err)
+	}
+
+	_, err = ParseConflictStrategy("merge")
+	if err == nil {
+		t.Fatalf("Unknown strategies should be rejected")
+	}
+}
+
+// backedUpKong is the state of a Kong with an entity of every kind, as backed up.
+func backedUpKong(t *testing.T) *KongState {
+	server := kongotest.NewServer()
+	defer server.Close()
+	kongo, err := NewKongo(&server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upstream, err := kongo.CreateUpstream(&UpstreamDef{Name: "kongo.restored.upstream"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = kongo.CreateTarget(NewTargetDef("10.0.0.1:80", upstream, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, err := kongo.CreateService(&ServiceDef{Name: "kongo.restored.service", Host: "kongo.restored.upstream", Path: "/", Port: 80, Protocol: "http"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	route, err := kongo.CreateRoute(&RouteDef{Name: "kongo.restored.route", Paths: kong.StringSlice("/restored"), Service: service})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = kongo.CreatePlugin(&PluginDef{Name: "cors", Route: route})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = kongo.CreateCertificate(&CertificateDef{Cert: "cert", Key: "key", SNIs: kong.StringSlice("restored.example.com")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := kongo.LoadKongState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func TestRestore(t *testing.T) {
+	state := backedUpKong(t)
+	collections := map[string]int{"upstreams": 1, "targets": 1, "services": 1, "routes": 1, "plugins": 1, "certificates": 1}
+
+	t.Run("empty Kong", func(t *testing.T) {
+		server := kongotest.NewServer()
+		defer server.Close()
+		kongo, err := NewKongo(&server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = kongo.RestoreKongState(state, ConflictFail)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for collection, count := range collections {
+			if server.Count(collection) != count {
+				t.Fatalf("Expected %d %s restored, got %d", count, collection, server.Count(collection))
+			}
+		}
+		service, err := kongo.GetService(*state.Services[0].ID)
+		if err != nil || *service.Name != "kongo.restored.service" {
+			t.Fatalf("Expected the Service to be restored with its ID: %v", err)
+		}
+
+		err = kongo.RestoreKongState(state, ConflictSkip)
+		if err != nil {
+			t.Fatalf("Expected restoring again to skip every entity: %v", err)
+		}
+	})
+
+	// A rebuilt Kong has the entities of the backup under the same names, with other IDs.
+	rebuiltKong := func(t *testing.T) (*kongotest.Server, *Kongo, *kong.Service) {
+		server := kongotest.NewServer()
+		t.Cleanup(server.Close)
+		kongo, err := NewKongo(&server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = kongo.CreateUpstream(&UpstreamDef{Name: "kongo.restored.upstream"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		service, err := kongo.CreateService(&ServiceDef{Name: "kongo.restored.service", Host: "rebuilt", Path: "/", Port: 80, Protocol: "http"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = kongo.CreateCertificate(&CertificateDef{Cert: "rebuilt", Key: "rebuilt", SNIs: kong.StringSlice("restored.example.com")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return server, kongo, service
+	}
+
+	t.Run("skip", func(t *testing.T) {
+		server, kongo, service := rebuiltKong(t)
+		err := kongo.RestoreKongState(state, ConflictSkip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for collection, count := range collections {
+			if server.Count(collection) != count {
+				t.Fatalf("Expected %d %s after skipping existing entities, got %d", count, collection, server.Count(collection))
+			}
+		}
+		existing, _ := kongo.GetService("kongo.restored.service")
+		if *existing.ID != *service.ID || *existing.Host != "rebuilt" {
+			t.Fatalf("Expected the existing Service to be left as it was, got: %+v", existing)
+		}
+		route, err := kongo.GetRoute("kongo.restored.route")
+		if err != nil || *route.Service.ID != *service.ID {
+			t.Fatalf("Expected the Route to be restored on the existing Service: %v", err)
+		}
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		server, kongo, service := rebuiltKong(t)
+		err := kongo.RestoreKongState(state, ConflictOverwrite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for collection, count := range collections {
+			if server.Count(collection) != count {
+				t.Fatalf("Expected %d %s after overwriting existing entities, got %d", count, collection, server.Count(collection))
+			}
+		}
+		existing, _ := kongo.GetService("kongo.restored.service")
+		if *existing.ID != *service.ID || *existing.Host != "kongo.restored.upstream" {
+			t.Fatalf("Expected the existing Service to be overwritten, got: %+v", existing)
+		}
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		_, kongo, _ := rebuiltKong(t)
+		err := kongo.RestoreKongState(state, ConflictFail)
+		if err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Fatalf("Expected restoring over existing entities to fail, got: %v", err)
+		}
+	})
+}
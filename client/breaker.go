@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/time/rate"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type BreakerState string
+
+const (
+	// BreakerClosed lets requests through.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen fails requests without sending them, until the open timeout elapses.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single request through, closing the breaker when it succeeds and opening it again otherwise.
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// ErrCircuitOpen fails the requests refused by an open circuit breaker. They are not retried.
+var ErrCircuitOpen = errors.New("circuit breaker open: the Kong Admin API failed too many times in a row")
+
+type CircuitBreakerSettings struct {
+	// FailureThreshold is the number of requests in a row that have to fail, with a connection error or 5xx, to open
+	// the breaker.
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before letting a request through to try Kong again.
+	OpenTimeout time.Duration
+}
+
+var DefaultCircuitBreakerSettings = CircuitBreakerSettings{
+	FailureThreshold: 5,
+	OpenTimeout:      30 * time.Second,
+}
+
+// BreakerStatus is the state of a circuit breaker at a point in time. OpenedAt is zero when closed.
+type BreakerStatus struct {
+	State               BreakerState
+	ConsecutiveFailures int
+	OpenedAt            time.Time
+}
+
+type CircuitBreaker struct {
+	settings CircuitBreakerSettings
+	mutex    sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	// probing is set while the single request of a half-open breaker is in flight.
+	probing bool
+	now     func() time.Time
+	logger  Logger
+}
+
+func NewCircuitBreaker(settings CircuitBreakerSettings) *CircuitBreaker {
+	return &CircuitBreaker{settings: settings, state: BreakerClosed, now: time.Now, logger: discardLogger{}}
+}
+
+// WithRateLimit bounds the requests to Kong to the rate, per second, with bursts of up to burst requests. Requests
+// wait for their turn, or for their context to be done.
+func WithRateLimit(requestsPerSecond float64, burst int) Option {
+	return func(settings *kongoSettings) error {
+		if requestsPerSecond <= 0 || burst < 1 {
+			return fmt.Errorf("invalid rate limit of %v requests per second in bursts of %d, both have to be positive", requestsPerSecond, burst)
+		}
+		settings.limiter = rate.NewLimiter(rate.Limit(requestsPerSecond), burst)
+		return nil
+	}
+}
+
+// WithCircuitBreaker fails requests fast while Kong keeps failing, rather than piling them up on it.
+func WithCircuitBreaker(breakerSettings CircuitBreakerSettings) Option {
+	return func(settings *kongoSettings) error {
+		settings.breaker = NewCircuitBreaker(breakerSettings)
+		return nil
+	}
+}
+
+// CircuitBreakerStatus gives the state of the circuit breaker, nil without one.
+func (kongo *Kongo) CircuitBreakerStatus() *BreakerStatus {
+	if kongo.breaker == nil {
+		return nil
+	}
+	status := kongo.breaker.Status()
+	return &status
+}
+
+func (breaker *CircuitBreaker) Status() BreakerStatus {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	breaker.halfOpenWhenDue()
+	return BreakerStatus{State: breaker.state, ConsecutiveFailures: breaker.failures, OpenedAt: breaker.openedAt}
+}
+
+// allow refuses requests while the breaker is open, and all but one while half-open. The request allowed while
+// half-open is the probe, which alone decides whether the breaker closes.
+func (breaker *CircuitBreaker) allow() (probe bool, err error) {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	breaker.halfOpenWhenDue()
+
+	switch breaker.state {
+	case BreakerOpen:
+		return false, ErrCircuitOpen
+	case BreakerHalfOpen:
+		if breaker.probing {
+			return false, ErrCircuitOpen
+		}
+		breaker.probing = true
+		return true, nil
+	}
+	return false, nil
+}
+
+// record counts the outcome of an allowed request, probe telling whether it was the probe. Requests given up by their
+// callers tell nothing about Kong, nor do those allowed before the breaker opened finishing while it is half-open.
+func (breaker *CircuitBreaker) record(probe bool, response *http.Response, err error) {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	if probe {
+		breaker.probing = false
+	} else if breaker.state == BreakerHalfOpen {
+		return
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	if err == nil && response.StatusCode < 500 {
+		if breaker.state != BreakerClosed {
+			breaker.logger.Info("circuit breaker closed, Kong is answering again")
+		}
+		breaker.state = BreakerClosed
+		breaker.failures = 0
+		breaker.openedAt = time.Time{}
+		return
+	}
+
+	breaker.failures++
+	if breaker.state == BreakerHalfOpen || breaker.failures >= breaker.settings.FailureThreshold {
+		if breaker.state != BreakerOpen {
+			breaker.logger.Warn("circuit breaker opened, failing requests to Kong fast", "failures", breaker.failures,
+				"openTimeout", breaker.settings.OpenTimeout)
+		}
+		breaker.state = BreakerOpen
+		breaker.openedAt = breaker.now()
+	}
+}
+
+func (breaker *CircuitBreaker) halfOpenWhenDue() {
+	if breaker.state == BreakerOpen && breaker.now().Sub(breaker.openedAt) >= breaker.settings.OpenTimeout {
+		breaker.state = BreakerHalfOpen
+	}
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(0, 0)
+	breaker := NewCircuitBreaker(CircuitBreakerSettings{FailureThreshold: 2, OpenTimeout: time.Minute})
+	breaker.now = func() time.Time { return now }
+
+	failed := &http.Response{StatusCode: http.StatusBadGateway}
+	succeeded := &http.Response{StatusCode: http.StatusNotFound}
+
+	expectState := func(state BreakerState, allowed bool) bool {
+		t.Helper()
+		if status := breaker.Status(); status.State != state {
+			t.Fatalf("Expected the breaker to be %s, got: %+v", state, status)
+		}
+		probe, err := breaker.allow()
+		if (err == nil) != allowed {
+			t.Fatalf("Expected the breaker %s to allow requests: %v, got: %v", state, allowed, err)
+		}
+		return probe
+	}
+
+	expectState(BreakerClosed, true)
+	breaker.record(false, failed, nil)
+	expectState(BreakerClosed, true)
+	breaker.record(false, succeeded, nil)
+	expectState(BreakerClosed, true)
+	breaker.record(false, failed, nil)
+	expectState(BreakerClosed, true)
+	breaker.record(false, nil, errors.New("connection refused"))
+	expectState(BreakerOpen, false)
+
+	now = now.Add(time.Minute)
+	probe := expectState(BreakerHalfOpen, true)
+	if !probe {
+		t.Fatalf("Expected the request allowed by a half-open breaker to be the probe")
+	}
+	if _, err := breaker.allow(); err == nil {
+		t.Fatalf("Expected a half-open breaker to allow a single request")
+	}
+	// A request sent before the breaker opened finishing leaves the probe in flight.
+	breaker.record(false, succeeded, nil)
+	if _, err := breaker.allow(); err == nil || breaker.Status().State != BreakerHalfOpen {
+		t.Fatalf("Expected only the probe to decide on a half-open breaker")
+	}
+	breaker.record(probe, failed, nil)
+	expectState(BreakerOpen, false)
+
+	now = now.Add(time.Minute)
+	probe = expectState(BreakerHalfOpen, true)
+	breaker.record(probe, succeeded, nil)
+	expectState(BreakerClosed, true)
+}
+
+func TestCircuitBreakerFailsFast(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests++
+		writer.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	kongo, err := NewKongo(&server.URL, WithCircuitBreaker(CircuitBreakerSettings{FailureThreshold: 3, OpenTimeout: time.Hour}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		kongo.ListUpstreams()
+	}
+	if requests != 3 {
+		t.Fatalf("Expected the breaker to open after 3 failures, Kong got %d requests", requests)
+	}
+	_, err = kongo.ListUpstreams()
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected the breaker to refuse requests, got: %v", err)
+	}
+	if status := kongo.CircuitBreakerStatus(); status == nil || status.State != BreakerOpen || status.ConsecutiveFailures != 3 {
+		t.Fatalf("Expected the breaker to be open after 3 failures, got: %+v", status)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	kongo, err := NewKongo(&server.URL, WithRateLimit(50, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		_, err := kongo.ListUpstreams()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("Expected 6 requests at 50 per second to take 100ms, took: %v", elapsed)
+	}
+	if kongo.CircuitBreakerStatus() != nil {
+		t.Fatalf("Expected no breaker status without a breaker")
+	}
+}
+
+func TestRateLimitValidation(t *testing.T) {
+	baseUrl := "http://localhost:8001"
+	for _, limit := range []struct {
+		requestsPerSecond float64
+		burst             int
+	}{{0, 1}, {-1, 1}, {10, 0}} {
+		_, err := NewKongo(&baseUrl, WithRateLimit(limit.requestsPerSecond, limit.burst))
+		if err == nil {
+			t.Fatalf("Expected a rate limit of %v per second in bursts of %d to be refused", limit.requestsPerSecond, limit.burst)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CassetteMode selects whether a CassetteRoundTripper records interactions with Kong or replays them.
+type CassetteMode string
+
+const (
+	// CassetteRecord forwards requests to Kong, writing every request and response to the cassette.
+	CassetteRecord CassetteMode = "record"
+	// CassetteReplay answers requests from the cassette without talking to Kong.
+	CassetteReplay CassetteMode = "replay"
+
+	scrubbedValue = "[SCRUBBED]"
+)
+
+var (
+	// ScrubbedHeaders are the request and response headers whose values are not written to cassettes.
+	ScrubbedHeaders = []string{"Authorization", "Kong-Admin-Token", "Apikey", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+	// ScrubbedFields are the fields of JSON bodies whose values are not written to cassettes, at any depth, e.g. the
+	// private key of Certificates.
+	ScrubbedFields = []string{"key", "password", "secret", "client_secret", "token", "apikey"}
+)
+
+// Cassette holds the interactions with Kong in the order they happened.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+	// replayed is set once the interaction has answered a request.
+	replayed bool
+}
+
+// CassetteRequest is a request to Kong, its Url being relative to the base Url of Kong so that a cassette can be
+// replayed whatever Kong was recorded.
+type CassetteRequest struct {
+	Method  string      `json:"method"`
+	Url     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+type CassetteResponse struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// CassetteRoundTripper records the requests to Kong and their responses to a cassette file, or replays them from one.
+// Secrets are scrubbed before being written, and scrubbed likewise before requests are matched with the interactions
+// of the cassette. A replayed request is answered by the first interaction not yet replayed with the same method, Url
+// and body; requests without one fail.
+type CassetteRoundTripper struct {
+	mode         CassetteMode
+	path         string
+	roundTripper http.RoundTripper
+	mutex        sync.Mutex
+	cassette     Cassette
+}
+
+// NewCassetteRoundTripper loads the cassette at the path when replaying, and starts an empty one when recording.
+func NewCassetteRoundTripper(mode CassetteMode, path string, roundTripper http.RoundTripper) (*CassetteRoundTripper, error) {
+	cassetteRoundTripper := &CassetteRoundTripper{mode: mode, path: path, roundTripper: roundTripper}
+	switch mode {
+	case CassetteRecord:
+		return cassetteRoundTripper, cassetteRoundTripper.save()
+	case CassetteReplay:
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading cassette '%s': %v", path, err)
+		}
+		err = jsoniter.Unmarshal(content, &cassetteRoundTripper.cassette)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing cassette '%s': %v", path, err)
+		}
+		return cassetteRoundTripper, nil
+	}
+	return nil, fmt.Errorf("unknown cassette mode '%s', expected %s or %s", mode, CassetteRecord, CassetteReplay)
+}
+
+func (crt *CassetteRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	request, err := newCassetteRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if crt.mode == CassetteReplay {
+		return crt.replay(req, request)
+	}
+
+	response, err := crt.roundTripper.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading the response to %s %s: %v", request.Method, request.Url, err)
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	crt.mutex.Lock()
+	defer crt.mutex.Unlock()
+	crt.cassette.Interactions = append(crt.cassette.Interactions, &Interaction{
+		Request: *request,
+		Response: CassetteResponse{
+			Status:  response.StatusCode,
+			Headers: scrubHeaders(response.Header),
+			Body:    scrubBody(body),
+		},
+	})
+	// The cassette is written after every interaction since nothing tells when the last one happened.
+	err = crt.save()
+	if err != nil {
+		response.Body.Close()
+		return nil, err
+	}
+	return response, nil
+}
+
+func (crt *CassetteRoundTripper) replay(req *http.Request, request *CassetteRequest) (*http.Response, error) {
+	crt.mutex.Lock()
+	defer crt.mutex.Unlock()
+
+	for _, interaction := range crt.cassette.Interactions {
+		if interaction.replayed || interaction.Request.Method != request.Method || interaction.Request.Url != request.Url || interaction.Request.Body != request.Body {
+			continue
+		}
+		interaction.replayed = true
+
+		headers := http.Header{}
+		for name, values := range interaction.Response.Headers {
+			headers[name] = append([]string(nil), values...)
+		}
+		return newResponse(req, interaction.Response.Status, headers, []byte(interaction.Response.Body)), nil
+	}
+	return nil, fmt.Errorf("unexpected request %s %s, not found in cassette '%s'", request.Method, request.Url, crt.path)
+}
+
+// newResponse answers the request without sending it.
+func newResponse(req *http.Request, status int, headers http.Header, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        headers,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func (crt *CassetteRoundTripper) save() error {
+	content, err := jsoniter.MarshalIndent(crt.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding cassette '%s': %v", crt.path, err)
+	}
+	err = os.MkdirAll(filepath.Dir(crt.path), 0755)
+	if err != nil {
+		return fmt.Errorf("error writing cassette '%s': %v", crt.path, err)
+	}
+	return ioutil.WriteFile(crt.path, content, 0644)
+}
+
+// newCassetteRequest reads the body of the request, leaving it to be read again.
+func newCassetteRequest(req *http.Request) (*CassetteRequest, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading the body of %s %s: %v", req.Method, req.URL, err)
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return &CassetteRequest{
+		Method:  req.Method,
+		Url:     req.URL.RequestURI(),
+		Headers: scrubHeaders(req.Header),
+		Body:    scrubBody(body),
+	}, nil
+}
+
+func scrubHeaders(headers http.Header) http.Header {
+	scrubbed := http.Header{}
+	for name, values := range headers {
+		scrubbed[name] = append([]string(nil), values...)
+	}
+	for _, name := range ScrubbedHeaders {
+		if scrubbed.Get(name) != "" {
+			scrubbed.Set(name, scrubbedValue)
+		}
+	}
+	return scrubbed
+}
+
+// scrubBody scrubs the ScrubbedFields of JSON bodies, other bodies being kept as they are.
+func scrubBody(body []byte) string {
+	var decoded interface{}
+	if len(body) == 0 || jsoniter.Unmarshal(body, &decoded) != nil {
+		return string(body)
+	}
+	// Keys are sorted for the bodies of requests to be matched whatever order they were encoded in.
+	content, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(scrubValue(decoded))
+	if err != nil {
+		return string(body)
+	}
+	return string(content)
+}
+
+func scrubValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for field, fieldValue := range value {
+			value[field] = scrubValue(fieldValue)
+			for _, scrubbed := range ScrubbedFields {
+				if field == scrubbed && fieldValue != nil {
+					value[field] = scrubbedValue
+				}
+			}
+		}
+	case []interface{}:
+		for i, element := range value {
+			value[i] = scrubValue(element)
+		}
+	}
+	return value
+}
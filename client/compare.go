@@ -0,0 +1,317 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/hbagdi/go-kong/kong"
+	jsoniter "github.com/json-iterator/go"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultIgnoredFields are the fields that differ between clusters holding the same configuration.
+var DefaultIgnoredFields = []string{"id", "created_at", "updated_at"}
+
+const (
+	SideLeft  = "left"
+	SideRight = "right"
+)
+
+// Difference is an entity missing on one side, or a field of an entity differing between both. Entities are named by
+// kind and name, Targets by their Upstream and target, e.g. `kongo.orders.upstream/10.0.0.1:80`.
+type Difference struct {
+	Kind string
+	Name string
+	// MissingOn is SideLeft or SideRight for entities found on one side only.
+	MissingOn string
+	// DuplicatedOn is SideLeft or SideRight for a name shared by several entities of one side, which are not compared.
+	DuplicatedOn string
+	Field        string
+	Left         interface{}
+	Right        interface{}
+}
+
+func (difference *Difference) String() string {
+	if difference.MissingOn != "" {
+		return fmt.Sprintf("%s '%s' is missing on the %s", difference.Kind, difference.Name, difference.MissingOn)
+	}
+	if difference.DuplicatedOn != "" {
+		return fmt.Sprintf("%s '%s' names several entities on the %s", difference.Kind, difference.Name, difference.DuplicatedOn)
+	}
+	left, _ := jsoniter.MarshalToString(difference.Left)
+	right, _ := jsoniter.MarshalToString(difference.Right)
+	return fmt.Sprintf("%s '%s' differs in %s: %s on the left, %s on the right", difference.Kind, difference.Name, difference.Field, left, right)
+}
+
+// CompareKongStates matches the entities of both states by name rather than ID, and compares them field by field, as
+// named in the Admin API. References to other entities are compared by the name of the entity referred to. An ignored
+// field is either a field of every kind, e.g. `created_at`, or of one kind, e.g. `route.regex_priority`. A name shared
+// by several entities of a side is a difference of its own, the entities it names not being compared.
+func CompareKongStates(left *KongState, right *KongState, ignoredFields []string) ([]*Difference, error) {
+	leftEntities, leftDuplicates, err := comparableEntities(left)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the left state: %v", err)
+	}
+	rightEntities, rightDuplicates, err := comparableEntities(right)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the right state: %v", err)
+	}
+
+	ignored := make(map[string]bool)
+	for _, field := range ignoredFields {
+		ignored[field] = true
+	}
+
+	differences := []*Difference{}
+	for key, leftEntity := range leftEntities {
+		rightEntity, found := rightEntities[key]
+		if !found {
+			differences = append(differences, &Difference{Kind: key.kind, Name: key.name, MissingOn: SideRight})
+			continue
+		}
+		if leftDuplicates[key] || rightDuplicates[key] {
+			continue
+		}
+
+		fields := make(map[string]bool)
+		for field := range leftEntity {
+			fields[field] = true
+		}
+		for field := range rightEntity {
+			fields[field] = true
+		}
+		for field := range fields {
+			if ignored[field] || ignored[key.kind+"."+field] {
+				continue
+			}
+			if !reflect.DeepEqual(leftEntity[field], rightEntity[field]) {
+				differences = append(differences, &Difference{
+					Kind:  key.kind,
+					Name:  key.name,
+					Field: field,
+					Left:  leftEntity[field],
+					Right: rightEntity[field],
+				})
+			}
+		}
+	}
+	for key := range rightEntities {
+		if _, found := leftEntities[key]; !found {
+			differences = append(differences, &Difference{Kind: key.kind, Name: key.name, MissingOn: SideLeft})
+		}
+	}
+	for key := range leftDuplicates {
+		differences = append(differences, &Difference{Kind: key.kind, Name: key.name, DuplicatedOn: SideLeft})
+	}
+	for key := range rightDuplicates {
+		differences = append(differences, &Difference{Kind: key.kind, Name: key.name, DuplicatedOn: SideRight})
+	}
+
+	sort.Slice(differences, func(i, j int) bool {
+		a, b := differences[i], differences[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Field != b.Field {
+			return a.Field < b.Field
+		}
+		return a.DuplicatedOn < b.DuplicatedOn
+	})
+	return differences, nil
+}
+
+type entityKey struct {
+	kind string
+	name string
+}
+
+// comparableEntities holds the fields of the entities of the state by kind and name, references replaced by names.
+// Routes without a name are named after their Service and paths, Services after their URL, and Upstreams after their
+// content. Certificates are named after their SNIs, or their content without any, and Plugins after the entity they
+// are applied to, e.g. `cors@route:kongo.orders.route`. The names shared by several entities are the duplicates, the
+// first entity of a name being kept.
+func comparableEntities(state *KongState) (map[entityKey]map[string]interface{}, map[entityKey]bool, error) {
+	certificateNames := make(map[string]string)
+	for _, certificate := range state.Certificates {
+		certificateNames[*certificate.ID] = comparableCertificateName(certificate)
+	}
+	serviceNames := make(map[string]string)
+	for _, service := range state.Services {
+		serviceNames[*service.ID] = comparableServiceName(service)
+	}
+	upstreamNames := make(map[string]string)
+	for _, upstream := range state.Upstreams {
+		upstreamNames[*upstream.ID] = comparableUpstreamName(upstream)
+	}
+	routeNames := make(map[string]string)
+	for _, route := range state.Routes {
+		routeNames[*route.ID] = comparableRouteName(route, serviceNames)
+	}
+
+	entities := make(map[entityKey]map[string]interface{})
+	duplicates := make(map[entityKey]bool)
+	add := func(kind string, name string, entity interface{}, references map[string]string) error {
+		fields, err := entityFields(entity)
+		if err != nil {
+			return fmt.Errorf("error reading %s '%s': %v", kind, name, err)
+		}
+		for reference, referenceName := range references {
+			fields[reference] = map[string]interface{}{"name": referenceName}
+		}
+		key := entityKey{kind, name}
+		if _, found := entities[key]; found {
+			duplicates[key] = true
+			return nil
+		}
+		entities[key] = fields
+		return nil
+	}
+
+	for _, certificate := range state.Certificates {
+		err := add(KindCertificate, certificateNames[*certificate.ID], certificate, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, service := range state.Services {
+		references := map[string]string{}
+		if service.ClientCertificate != nil && service.ClientCertificate.ID != nil {
+			references["client_certificate"] = certificateNames[*service.ClientCertificate.ID]
+		}
+		err := add(KindService, serviceNames[*service.ID], service, references)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, route := range state.Routes {
+		serviceName := ""
+		if route.Service != nil && route.Service.ID != nil {
+			serviceName = serviceNames[*route.Service.ID]
+		}
+		err := add(KindRoute, routeNames[*route.ID], route, map[string]string{"service": serviceName})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, upstream := range state.Upstreams {
+		err := add(KindUpstream, upstreamNames[*upstream.ID], upstream, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, target := range state.Targets {
+		upstreamName := ""
+		if target.Upstream != nil && target.Upstream.ID != nil {
+			upstreamName = upstreamNames[*target.Upstream.ID]
+		}
+		err := add(KindTarget, upstreamName+"/"+*target.Target, target, map[string]string{"upstream": upstreamName})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, plugin := range state.Plugins {
+		name := *plugin.Name
+		references := map[string]string{}
+		if plugin.Route != nil && plugin.Route.ID != nil {
+			references["route"] = routeNames[*plugin.Route.ID]
+			name += "@route:" + references["route"]
+		}
+		if plugin.Service != nil && plugin.Service.ID != nil {
+			references["service"] = serviceNames[*plugin.Service.ID]
+			name += "@service:" + references["service"]
+		}
+		if plugin.Consumer != nil && plugin.Consumer.ID != nil {
+			name += "@consumer:" + *plugin.Consumer.ID
+		}
+		err := add(KindPlugin, name, plugin, references)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return entities, duplicates, nil
+}
+
+func entityFields(entity interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	content, err := jsoniter.Marshal(entity)
+	if err == nil {
+		err = jsoniter.Unmarshal(content, &fields)
+	}
+	return fields, err
+}
+
+func comparableRouteName(route *kong.Route, serviceNames map[string]string) string {
+	if route.Name != nil {
+		return *route.Name
+	}
+	serviceName := ""
+	if route.Service != nil && route.Service.ID != nil {
+		serviceName = serviceNames[*route.Service.ID]
+	}
+	paths := []string{}
+	for _, path := range route.Paths {
+		paths = append(paths, *path)
+	}
+	return serviceName + ":" + strings.Join(paths, ",")
+}
+
+func comparableServiceName(service *kong.Service) string {
+	if service.Name != nil {
+		return *service.Name
+	}
+	value := func(field *string) string {
+		if field == nil {
+			return ""
+		}
+		return *field
+	}
+	port := ""
+	if service.Port != nil {
+		port = ":" + strconv.Itoa(*service.Port)
+	}
+	return value(service.Protocol) + "://" + value(service.Host) + port + value(service.Path)
+}
+
+func comparableUpstreamName(upstream *kong.Upstream) string {
+	if upstream.Name != nil {
+		return *upstream.Name
+	}
+	return contentName(upstream)
+}
+
+func comparableCertificateName(certificate *kong.Certificate) string {
+	snis := []string{}
+	for _, sni := range certificate.SNIs {
+		snis = append(snis, *sni)
+	}
+	if len(snis) == 0 {
+		return contentName(certificate)
+	}
+	sort.Strings(snis)
+	return strings.Join(snis, ",")
+}
+
+// contentName names an entity after a digest of its fields but those that differ between clusters.
+func contentName(entity interface{}) string {
+	fields, _ := entityFields(entity)
+	for _, field := range DefaultIgnoredFields {
+		delete(fields, field)
+	}
+	content, _ := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(fields)
+	digest := sha256.Sum256(content)
+	return "content:" + hex.EncodeToString(digest[:6])
+}
+
+func nameOf(name *string, id *string) string {
+	if name != nil {
+		return *name
+	}
+	return *id
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"github.com/hbagdi/go-kong/kong"
+	"testing"
+)
+
+func TestCompareKongStates(t *testing.T) {
+	left := &KongState{
+		Services: []*kong.Service{{ID: kong.String("s-1"), Name: kong.String("kongo.svc.service"), Port: kong.Int(80), CreatedAt: kong.Int(1)}},
+		Routes: []*kong.Route{
+			{ID: kong.String("r-1"), Name: kong.String("kongo.svc.route"), Paths: kong.StringSlice("/svc"), Service: &kong.Service{ID: kong.String("s-1")}},
+			{ID: kong.String("r-2"), Paths: kong.StringSlice("/legacy"), Service: &kong.Service{ID: kong.String("s-1")}},
+		},
+		Upstreams: []*kong.Upstream{{ID: kong.String("u-1"), Name: kong.String("kongo.svc.upstream"), Slots: kong.Int(100)}},
+		Targets:   []*kong.Target{{ID: kong.String("t-1"), Target: kong.String("10.0.0.1:80"), Weight: kong.Int(100), Upstream: &kong.Upstream{ID: kong.String("u-1")}}},
+	}
+	right := &KongState{
+		Services: []*kong.Service{{ID: kong.String("s-9"), Name: kong.String("kongo.svc.service"), Port: kong.Int(8080), CreatedAt: kong.Int(2)}},
+		Routes: []*kong.Route{
+			{ID: kong.String("r-9"), Name: kong.String("kongo.svc.route"), Paths: kong.StringSlice("/svc"), Service: &kong.Service{ID: kong.String("s-9")}},
+		},
+		Upstreams: []*kong.Upstream{
+			{ID: kong.String("u-9"), Name: kong.String("kongo.svc.upstream"), Slots: kong.Int(1000)},
+			{ID: kong.String("u-8"), Name: kong.String("kongo.other.upstream")},
+		},
+		Targets: []*kong.Target{{ID: kong.String("t-9"), Target: kong.String("10.0.0.1:80"), Weight: kong.Int(100), Upstream: &kong.Upstream{ID: kong.String("u-9")}}},
+	}
+
+	differences, err := CompareKongStates(left, right, append(DefaultIgnoredFields, "upstream.slots"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Difference{
+		{Kind: KindRoute, Name: "kongo.svc.service:/legacy", MissingOn: SideRight},
+		{Kind: KindService, Name: "kongo.svc.service", Field: "port"},
+		{Kind: KindUpstream, Name: "kongo.other.upstream", MissingOn: SideLeft},
+	}
+	if len(differences) != len(expected) {
+		t.Fatalf("Expected %d differences, got: %v", len(expected), differences)
+	}
+	for i, difference := range differences {
+		if difference.Kind != expected[i].Kind || difference.Name != expected[i].Name || difference.MissingOn != expected[i].MissingOn || difference.Field != expected[i].Field {
+			t.Fatalf("Unexpected difference %d: %v", i, difference)
+		}
+	}
+}
+
+func TestCompareKongStatesPluginsAndCertificates(t *testing.T) {
+	left := &KongState{
+		Services: []*kong.Service{
+			{ID: kong.String("s-1"), Name: kong.String("kongo.svc.service"), ClientCertificate: &kong.Certificate{ID: kong.String("c-1")}},
+			{ID: kong.String("s-2"), Host: kong.String("legacy.internal"), Port: kong.Int(80), Protocol: kong.String("http"), Retries: kong.Int(5)},
+		},
+		Routes: []*kong.Route{{ID: kong.String("r-1"), Name: kong.String("kongo.svc.route"), Service: &kong.Service{ID: kong.String("s-1")}}},
+		Plugins: []*kong.Plugin{
+			{ID: kong.String("p-1"), Name: kong.String("cors"), Route: &kong.Route{ID: kong.String("r-1")}},
+			{ID: kong.String("p-2"), Name: kong.String("rate-limiting"), Service: &kong.Service{ID: kong.String("s-1")}, Config: kong.Configuration{"minute": 20}},
+		},
+		Certificates: []*kong.Certificate{{ID: kong.String("c-1"), Cert: kong.String("cert"), SNIs: kong.StringSlice("a.example.com", "b.example.com")}},
+	}
+	right := &KongState{
+		Services: []*kong.Service{
+			{ID: kong.String("s-9"), Name: kong.String("kongo.svc.service"), ClientCertificate: &kong.Certificate{ID: kong.String("c-9")}},
+			{ID: kong.String("s-8"), Host: kong.String("legacy.internal"), Port: kong.Int(80), Protocol: kong.String("http"), Retries: kong.Int(3)},
+		},
+		Routes: []*kong.Route{{ID: kong.String("r-9"), Name: kong.String("kongo.svc.route"), Service: &kong.Service{ID: kong.String("s-9")}}},
+		Plugins: []*kong.Plugin{
+			{ID: kong.String("p-9"), Name: kong.String("cors"), Route: &kong.Route{ID: kong.String("r-9")}},
+			{ID: kong.String("p-8"), Name: kong.String("rate-limiting"), Service: &kong.Service{ID: kong.String("s-9")}, Config: kong.Configuration{"minute": 60}},
+			{ID: kong.String("p-7"), Name: kong.String("prometheus")},
+		},
+		Certificates: []*kong.Certificate{{ID: kong.String("c-9"), Cert: kong.String("renewed"), SNIs: kong.StringSlice("a.example.com", "b.example.com")}},
+	}
+
+	differences, err := CompareKongStates(left, right, DefaultIgnoredFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Difference{
+		{Kind: KindCertificate, Name: "a.example.com,b.example.com", Field: "cert"},
+		{Kind: KindPlugin, Name: "prometheus", MissingOn: SideLeft},
+		{Kind: KindPlugin, Name: "rate-limiting@service:kongo.svc.service", Field: "config"},
+		{Kind: KindService, Name: "http://legacy.internal:80", Field: "retries"},
+	}
+	if len(differences) != len(expected) {
+		t.Fatalf("Expected %d differences, got: %v", len(expected), differences)
+	}
+	for i, difference := range differences {
+		if difference.Kind != expected[i].Kind || difference.Name != expected[i].Name || difference.MissingOn != expected[i].MissingOn || difference.Field != expected[i].Field {
+			t.Fatalf("Unexpected difference %d: %v", i, difference)
+		}
+	}
+}
+
+func TestCompareKongStatesDuplicates(t *testing.T) {
+	// Two unnamed Services with the same URL on the left, told apart by nothing but their IDs.
+	left := &KongState{Services: []*kong.Service{
+		{ID: kong.String("s-1"), Host: kong.String("legacy.internal"), Port: kong.Int(80), Protocol: kong.String("http"), Retries: kong.Int(3)},
+		{ID: kong.String("s-2"), Host: kong.String("legacy.internal"), Port: kong.Int(80), Protocol: kong.String("http"), Retries: kong.Int(5)},
+	}}
+	right := &KongState{Services: []*kong.Service{
+		{ID: kong.String("s-9"), Host: kong.String("legacy.internal"), Port: kong.Int(80), Protocol: kong.String("http"), Retries: kong.Int(5)},
+	}}
+
+	differences, err := CompareKongStates(left, right, DefaultIgnoredFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(differences) != 1 || differences[0].DuplicatedOn != SideLeft || differences[0].Name != "http://legacy.internal:80" {
+		t.Fatalf("Expected the name shared by both Services on the left to be the only difference, got: %v", differences)
+	}
+}
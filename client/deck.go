@@ -0,0 +1,329 @@
+package client
+
+import (
+	"fmt"
+	"github.com/hbagdi/go-kong/kong"
+	jsoniter "github.com/json-iterator/go"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// DeckFormatVersion is the `_format_version` written to, and accepted from, decK files.
+const DeckFormatVersion = "1.1"
+
+type DeckFile struct {
+	FormatVersion string          `json:"_format_version"`
+	Info          *DeckInfo       `json:"_info,omitempty"`
+	Services      []*DeckService  `json:"services,omitempty"`
+	Routes        []*DeckRoute    `json:"routes,omitempty"`
+	Upstreams     []*DeckUpstream `json:"upstreams,omitempty"`
+}
+
+type DeckInfo struct {
+	SelectTags []string      `json:"select_tags,omitempty"`
+	Defaults   *DeckDefaults `json:"defaults,omitempty"`
+}
+
+type DeckDefaults struct {
+	Service  *kong.Service  `json:"service,omitempty"`
+	Route    *kong.Route    `json:"route,omitempty"`
+	Upstream *kong.Upstream `json:"upstream,omitempty"`
+	Target   *kong.Target   `json:"target,omitempty"`
+}
+
+type DeckService struct {
+	kong.Service
+	Routes []*DeckRoute `json:"routes,omitempty"`
+}
+
+type DeckRoute struct {
+	kong.Route
+}
+
+type DeckUpstream struct {
+	kong.Upstream
+	Targets []*DeckTarget `json:"targets,omitempty"`
+}
+
+type DeckTarget struct {
+	kong.Target
+}
+
+func ReadDeckFile(path string) (*DeckFile, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	deckFile := new(DeckFile)
+	err = yaml.Unmarshal(content, deckFile)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing decK file '%s': %v", path, err)
+	}
+
+	if deckFile.FormatVersion == "" {
+		return nil, fmt.Errorf("decK file '%s' has no _format_version", path)
+	}
+
+	return deckFile, nil
+}
+
+// WriteDeckFile writes JSON when the path ends in .json and YAML otherwise.
+func WriteDeckFile(path string, deckFile *DeckFile) error {
+	content, err := jsoniter.MarshalIndent(deckFile, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if strings.ToLower(filepath.Ext(path)) != ".json" {
+		content, err = yaml.JSONToYAML(content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+// NewDeckFile nests the Routes and Targets of the state under their Services and Upstreams.
+// Only entities carrying every one of the selectTags are included, and IDs and timestamps are left out,
+// as decK does by default. Fields matching the given defaults are omitted. Routes whose Service is left out refer to it
+// by name.
+func NewDeckFile(state *KongState, selectTags []string, defaults *DeckDefaults) *DeckFile {
+	deckFile := &DeckFile{FormatVersion: DeckFormatVersion}
+	if len(selectTags) > 0 || defaults != nil {
+		deckFile.Info = &DeckInfo{SelectTags: selectTags, Defaults: defaults}
+	}
+	if defaults == nil {
+		defaults = new(DeckDefaults)
+	}
+
+	servicesById := make(map[string]*DeckService)
+	serviceNames := make(map[string]*string)
+	for _, service := range state.Services {
+		serviceNames[*service.ID] = service.Name
+		if !hasAllTags(service.Tags, selectTags) {
+			continue
+		}
+		deckService := &DeckService{Service: *service}
+		servicesById[*service.ID] = deckService
+		deckFile.Services = append(deckFile.Services, deckService)
+	}
+
+	for _, route := range state.Routes {
+		if !hasAllTags(route.Tags, selectTags) {
+			continue
+		}
+		deckRoute := &DeckRoute{Route: *route}
+		deckRoute.Service = nil
+		if route.Service != nil && route.Service.ID != nil {
+			if deckService, found := servicesById[*route.Service.ID]; found {
+				deckService.Routes = append(deckService.Routes, deckRoute)
+				continue
+			}
+			if name := serviceNames[*route.Service.ID]; name != nil {
+				deckRoute.Service = &kong.Service{Name: name}
+			} else {
+				deckRoute.Service = &kong.Service{ID: route.Service.ID}
+			}
+		}
+		deckFile.Routes = append(deckFile.Routes, deckRoute)
+	}
+
+	upstreamsById := make(map[string]*DeckUpstream)
+	for _, upstream := range state.Upstreams {
+		if !hasAllTags(upstream.Tags, selectTags) {
+			continue
+		}
+		deckUpstream := &DeckUpstream{Upstream: *upstream}
+		upstreamsById[*upstream.ID] = deckUpstream
+		deckFile.Upstreams = append(deckFile.Upstreams, deckUpstream)
+	}
+
+	for _, target := range state.Targets {
+		if target.Upstream == nil || target.Upstream.ID == nil {
+			continue
+		}
+		deckUpstream, found := upstreamsById[*target.Upstream.ID]
+		if !found {
+			continue
+		}
+		deckTarget := &DeckTarget{Target: *target}
+		deckTarget.Upstream = nil
+		deckUpstream.Targets = append(deckUpstream.Targets, deckTarget)
+	}
+
+	for _, deckService := range deckFile.Services {
+		deckService.ID, deckService.CreatedAt, deckService.UpdatedAt = nil, nil, nil
+		stripDefaults(&deckService.Service, defaults.Service)
+		for _, deckRoute := range deckService.Routes {
+			deckRoute.ID, deckRoute.CreatedAt, deckRoute.UpdatedAt = nil, nil, nil
+			stripDefaults(&deckRoute.Route, defaults.Route)
+		}
+	}
+	for _, deckRoute := range deckFile.Routes {
+		deckRoute.ID, deckRoute.CreatedAt, deckRoute.UpdatedAt = nil, nil, nil
+		stripDefaults(&deckRoute.Route, defaults.Route)
+	}
+	for _, deckUpstream := range deckFile.Upstreams {
+		deckUpstream.ID, deckUpstream.CreatedAt = nil, nil
+		stripDefaults(&deckUpstream.Upstream, defaults.Upstream)
+		for _, deckTarget := range deckUpstream.Targets {
+			deckTarget.ID, deckTarget.CreatedAt = nil, nil
+			stripDefaults(&deckTarget.Target, defaults.Target)
+		}
+	}
+
+	return deckFile
+}
+
+// KongState flattens the file into entities ready for ApplyKongState, filling in `_info.defaults`
+// and tagging every entity with `_info.select_tags`.
+func (deckFile *DeckFile) KongState() (*KongState, error) {
+	info := deckFile.Info
+	if info == nil {
+		info = new(DeckInfo)
+	}
+	defaults := info.Defaults
+	if defaults == nil {
+		defaults = new(DeckDefaults)
+	}
+	selectTags := kong.StringSlice(info.SelectTags...)
+
+	state := new(KongState)
+
+	for _, deckService := range deckFile.Services {
+		if deckService.Name == nil {
+			return nil, fmt.Errorf("decK Services require a name")
+		}
+		service := deckService.Service
+		applyDefaults(&service, defaults.Service)
+		service.Tags = mergeTags(service.Tags, selectTags)
+		state.Services = append(state.Services, &service)
+
+		for _, deckRoute := range deckService.Routes {
+			route, err := deckRouteToKong(deckRoute, defaults.Route, selectTags)
+			if err != nil {
+				return nil, err
+			}
+			route.Service = &kong.Service{Name: service.Name}
+			state.Routes = append(state.Routes, route)
+		}
+	}
+
+	for _, deckRoute := range deckFile.Routes {
+		route, err := deckRouteToKong(deckRoute, defaults.Route, selectTags)
+		if err != nil {
+			return nil, err
+		}
+		state.Routes = append(state.Routes, route)
+	}
+
+	for _, deckUpstream := range deckFile.Upstreams {
+		if deckUpstream.Name == nil {
+			return nil, fmt.Errorf("decK Upstreams require a name")
+		}
+		upstream := deckUpstream.Upstream
+		applyDefaults(&upstream, defaults.Upstream)
+		upstream.Tags = mergeTags(upstream.Tags, selectTags)
+		state.Upstreams = append(state.Upstreams, &upstream)
+
+		for _, deckTarget := range deckUpstream.Targets {
+			if deckTarget.Target.Target == nil {
+				return nil, fmt.Errorf("Targets of Upstream '%s' require a target", *upstream.Name)
+			}
+			target := deckTarget.Target
+			applyDefaults(&target, defaults.Target)
+			target.Tags = mergeTags(target.Tags, selectTags)
+			target.Upstream = &kong.Upstream{Name: upstream.Name}
+			state.Targets = append(state.Targets, &target)
+		}
+	}
+
+	return state, nil
+}
+
+func deckRouteToKong(deckRoute *DeckRoute, defaults *kong.Route, selectTags []*string) (*kong.Route, error) {
+	if deckRoute.Name == nil {
+		return nil, fmt.Errorf("decK Routes require a name")
+	}
+	route := deckRoute.Route
+	applyDefaults(&route, defaults)
+	route.Tags = mergeTags(route.Tags, selectTags)
+	return &route, nil
+}
+
+// applyDefaults sets each nil field of entity to the corresponding field of defaults, both being pointers to the same struct type.
+func applyDefaults(entity interface{}, defaults interface{}) {
+	entityValue := reflect.ValueOf(entity).Elem()
+	defaultsValue := reflect.ValueOf(defaults)
+	if defaultsValue.IsNil() {
+		return
+	}
+	defaultsValue = defaultsValue.Elem()
+
+	for i := 0; i < entityValue.NumField(); i++ {
+		field := entityValue.Field(i)
+		if isNilable(field) && field.IsNil() {
+			field.Set(defaultsValue.Field(i))
+		}
+	}
+}
+
+// stripDefaults is the inverse of applyDefaults, clearing the fields of entity equal to those of defaults.
+func stripDefaults(entity interface{}, defaults interface{}) {
+	entityValue := reflect.ValueOf(entity).Elem()
+	defaultsValue := reflect.ValueOf(defaults)
+	if defaultsValue.IsNil() {
+		return
+	}
+	defaultsValue = defaultsValue.Elem()
+
+	for i := 0; i < entityValue.NumField(); i++ {
+		field := entityValue.Field(i)
+		defaultField := defaultsValue.Field(i)
+		if isNilable(field) && !defaultField.IsNil() && reflect.DeepEqual(field.Interface(), defaultField.Interface()) {
+			field.Set(reflect.Zero(field.Type()))
+		}
+	}
+}
+
+func isNilable(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		return true
+	}
+	return false
+}
+
+func hasAllTags(tags []*string, required []string) bool {
+	for _, requiredTag := range required {
+		found := false
+		for _, tag := range tags {
+			if tag != nil && *tag == requiredTag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func mergeTags(tags []*string, additional []*string) []*string {
+	merged := append([]*string{}, tags...)
+	for _, tag := range additional {
+		if !hasAllTags(merged, []string{*tag}) {
+			merged = append(merged, tag)
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"github.com/ciroque/kongo/client/kongotest"
+	"github.com/hbagdi/go-kong/kong"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeckFileRoundTrip(t *testing.T) {
+	state := &KongState{
+		Services: []*kong.Service{
+			{ID: kong.String("s-1"), Name: kong.String("kongo.svc.service"), Host: kong.String("kongo.svc.upstream"), Port: kong.Int(80), Tags: kong.StringSlice("kongo")},
+			{ID: kong.String("s-2"), Name: kong.String("untagged.service"), Host: kong.String("localhost"), Port: kong.Int(8080)},
+		},
+		Routes: []*kong.Route{
+			{ID: kong.String("r-1"), Name: kong.String("kongo.svc.route"), Paths: kong.StringSlice("/svc"), Service: &kong.Service{ID: kong.String("s-1")}, Tags: kong.StringSlice("kongo")},
+		},
+		Upstreams: []*kong.Upstream{
+			{ID: kong.String("u-1"), Name: kong.String("kongo.svc.upstream"), Tags: kong.StringSlice("kongo")},
+		},
+		Targets: []*kong.Target{
+			{ID: kong.String("t-1"), Target: kong.String("10.0.0.1:80"), Weight: kong.Int(100), Upstream: &kong.Upstream{ID: kong.String("u-1")}},
+		},
+	}
+	defaults := &DeckDefaults{Service: &kong.Service{Port: kong.Int(80)}}
+
+	deckFile := NewDeckFile(state, []string{"kongo"}, defaults)
+
+	if len(deckFile.Services) != 1 {
+		t.Fatalf("Only the tagged Service should have been exported, got %v", len(deckFile.Services))
+	}
+	if len(deckFile.Services[0].Routes) != 1 || len(deckFile.Upstreams[0].Targets) != 1 {
+		t.Fatalf("Routes and Targets should be nested under their Service and Upstream")
+	}
+	if deckFile.Services[0].ID != nil || deckFile.Services[0].Port != nil {
+		t.Fatalf("IDs and default values should not be exported")
+	}
+
+	dir, err := ioutil.TempDir("", "kongo-deck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "kong.yaml")
+	err = WriteDeckFile(path, deckFile)
+	if err != nil {
+		t.Fatalf("Error writing decK file: %v", err)
+	}
+
+	read, err := ReadDeckFile(path)
+	if err != nil {
+		t.Fatalf("Error reading decK file: %v", err)
+	}
+
+	readState, err := read.KongState()
+	if err != nil {
+		t.Fatalf("Error converting decK file: %v", err)
+	}
+
+	if len(readState.Services) != 1 || *readState.Services[0].Port != 80 {
+		t.Fatalf("The Service default port should have been applied")
+	}
+	if *readState.Routes[0].Service.Name != "kongo.svc.service" {
+		t.Fatalf("The nested Route should refer to its Service by name")
+	}
+	if *readState.Targets[0].Upstream.Name != "kongo.svc.upstream" || *readState.Targets[0].Weight != 100 {
+		t.Fatalf("The nested Target should refer to its Upstream by name")
+	}
+	if !hasAllTags(readState.Targets[0].Tags, []string{"kongo"}) {
+		t.Fatalf("The select tags should be applied to every entity")
+	}
+}
+
+func TestDeckFileKeepsServiceOfTopLevelRoute(t *testing.T) {
+	state := &KongState{
+		Services: []*kong.Service{{ID: kong.String("s-1"), Name: kong.String("untagged.service"), Host: kong.String("localhost")}},
+		Routes: []*kong.Route{
+			{ID: kong.String("r-1"), Name: kong.String("kongo.svc.route"), Paths: kong.StringSlice("/svc"), Service: &kong.Service{ID: kong.String("s-1")}, Tags: kong.StringSlice("kongo")},
+		},
+	}
+
+	deckFile := NewDeckFile(state, []string{"kongo"}, nil)
+	if len(deckFile.Services) != 0 || len(deckFile.Routes) != 1 {
+		t.Fatalf("Only the tagged Route should have been exported")
+	}
+	if deckFile.Routes[0].Service == nil || *deckFile.Routes[0].Service.Name != "untagged.service" {
+		t.Fatalf("The Route should refer to the Service left out by name, got: %+v", deckFile.Routes[0].Service)
+	}
+}
+
+func TestApplyKongStateTwice(t *testing.T) {
+	server := kongotest.NewServer()
+	defer server.Close()
+	kongo, err := NewKongo(&server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = kongo.CreateService(&ServiceDef{Name: "existing.service", Host: "localhost", Path: "/", Port: 80, Protocol: "http"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deckFile := &DeckFile{
+		FormatVersion: DeckFormatVersion,
+		Routes: []*DeckRoute{{Route: kong.Route{Name: kong.String("kongo.existing.route"), Paths: kong.StringSlice("/existing"),
+			Service: &kong.Service{Name: kong.String("existing.service")}}}},
+		Upstreams: []*DeckUpstream{{
+			Upstream: kong.Upstream{Name: kong.String("kongo.svc.upstream")},
+			Targets: []*DeckTarget{
+				{Target: kong.Target{Target: kong.String("10.0.0.1:80"), Weight: kong.Int(100)}},
+				{Target: kong.Target{Target: kong.String("10.0.0.2")}},
+			},
+		}},
+	}
+	state, err := deckFile.KongState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err = kongo.ApplyKongState(state)
+		if err != nil {
+			t.Fatalf("Error applying the state (%d): %v", i+1, err)
+		}
+		if server.Count("targets") != 2 || server.Count("routes") != 1 {
+			t.Fatalf("Expected applying the state again to leave the Targets and Route as they were, got %d Targets",
+				server.Count("targets"))
+		}
+	}
+
+	*state.Targets[0].Weight = 50
+	err = kongo.ApplyKongState(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream, _ := kongo.GetUpstream("kongo.svc.upstream")
+	targets, _ := kongo.ListTargets(*upstream.ID)
+	if len(targets) != 2 {
+		t.Fatalf("Expected the reweighted Target to be replaced, got %d Targets", len(targets))
+	}
+	for _, target := range targets {
+		if *target.Target == "10.0.0.1:80" && *target.Weight != 50 {
+			t.Fatalf("Expected the Target to be reweighted, got %d", *target.Weight)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package client
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DryRunIDPrefix starts the placeholder IDs a dry run gives the entities it would have created, e.g. `dry-run-1`.
+const DryRunIDPrefix = "dry-run-"
+
+// PlannedChange is a request to Kong a dry run did not send. Body is the entity of the request, with the ScrubbedFields
+// scrubbed.
+type PlannedChange struct {
+	Method string              `json:"method"`
+	Url    string              `json:"url"`
+	Kind   string              `json:"kind"`
+	ID     string              `json:"id,omitempty"`
+	Name   string              `json:"name,omitempty"`
+	Body   jsoniter.RawMessage `json:"body,omitempty"`
+}
+
+// Action is what the change does to its entity: create, update or delete.
+func (change *PlannedChange) Action() string {
+	switch change.Method {
+	case http.MethodPost:
+		return "create"
+	case http.MethodDelete:
+		return "delete"
+	}
+	return "update"
+}
+
+func (change *PlannedChange) String() string {
+	entity := change.Name
+	if entity == "" {
+		entity = change.ID
+	} else if change.ID != "" {
+		entity = fmt.Sprintf("%s (%s)", change.Name, change.ID)
+	}
+	return fmt.Sprintf("%s %s %s", change.Action(), change.Kind, entity)
+}
+
+// Plan holds the changes of a dry run in the order they were planned, which bulk operations make concurrently.
+type Plan struct {
+	mutex   sync.Mutex
+	changes []*PlannedChange
+	ids     int
+	// issued holds the placeholder IDs given so far.
+	issued map[string]bool
+}
+
+// WithDryRun plans the changes to Kong rather than making them. Changes are answered as Kong would, entities created
+// being given placeholder IDs, while reads still go to Kong; reads of entities created by the plan find nothing.
+func WithDryRun() Option {
+	return func(settings *kongoSettings) error {
+		settings.plan = &Plan{}
+		return nil
+	}
+}
+
+// Plan gives the changes planned by a dry run, nil unless the Kongo was created WithDryRun.
+func (kongo *Kongo) Plan() *Plan {
+	return kongo.plan
+}
+
+func (plan *Plan) Changes() []*PlannedChange {
+	plan.mutex.Lock()
+	defer plan.mutex.Unlock()
+	return append([]*PlannedChange(nil), plan.changes...)
+}
+
+// Write writes the changes of the plan, one per line.
+func (plan *Plan) Write(writer io.Writer) error {
+	changes := plan.Changes()
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(writer, "No changes planned.")
+		return err
+	}
+	_, err := fmt.Fprintf(writer, "%d changes planned:\n", len(changes))
+	if err != nil {
+		return err
+	}
+	for i, change := range changes {
+		_, err = fmt.Fprintf(writer, "%4d. %s\n", i+1, change)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// plan records the change of the request, answering it with the entity Kong would have: the entity of the request over
+// the one Kong has at the Url, given a placeholder ID when new.
+func (plan *Plan) plan(req *http.Request, read func(req *http.Request) (*http.Response, error)) (*PlannedChange, *http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading the body of %s %s: %v", req.Method, req.URL, err)
+		}
+	}
+
+	entity := map[string]interface{}{}
+	if req.Method != http.MethodPost {
+		existing := loadEntity(req, read)
+		if existing != nil {
+			jsoniter.Unmarshal(existing, &entity)
+		}
+	}
+	if len(body) > 0 {
+		err := jsoniter.Unmarshal(body, &entity)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error decoding the body of %s %s: %v", req.Method, req.URL, err)
+		}
+	}
+
+	plan.mutex.Lock()
+	defer plan.mutex.Unlock()
+	if _, found := entity["id"]; !found && req.Method != http.MethodDelete {
+		plan.ids++
+		id := fmt.Sprintf("%s%d", DryRunIDPrefix, plan.ids)
+		if plan.issued == nil {
+			plan.issued = map[string]bool{}
+		}
+		plan.issued[id] = true
+		entity["id"] = id
+	}
+	content, err := jsoniter.Marshal(entity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	change := &PlannedChange{
+		Method: req.Method,
+		Url:    req.URL.RequestURI(),
+		Kind:   strings.TrimSuffix(adminKind(req.URL.Path), "s"),
+		Body:   auditPayload(body),
+	}
+	change.ID, change.Name = identify(req, content)
+	plan.changes = append(plan.changes, change)
+
+	headers := http.Header{"Content-Type": []string{"application/json"}}
+	switch req.Method {
+	case http.MethodPost:
+		return change, newResponse(req, http.StatusCreated, headers, content), nil
+	case http.MethodDelete:
+		return change, newResponse(req, http.StatusNoContent, http.Header{}, nil), nil
+	}
+	return change, newResponse(req, http.StatusOK, headers, content), nil
+}
+
+// isPlaceholder tells whether the request reads an entity created by the plan, which Kong knows nothing of: a segment
+// of its path is one of the placeholder IDs the plan gave.
+func (plan *Plan) isPlaceholder(req *http.Request) bool {
+	plan.mutex.Lock()
+	defer plan.mutex.Unlock()
+	for _, segment := range strings.Split(req.URL.Path, "/") {
+		if plan.issued[segment] {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholderResponse answers the reads of entities created by the plan as Kong would have, had it just created them:
+// their nested collections are empty.
+func placeholderResponse(req *http.Request) *http.Response {
+	headers := http.Header{"Content-Type": []string{"application/json"}}
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if adminKinds[segments[len(segments)-1]] {
+		return newResponse(req, http.StatusOK, headers, []byte(`{"data":[],"next":null}`))
+	}
+	return newResponse(req, http.StatusNotFound, headers, []byte(`{"message":"Not found"}`))
+}
+
+// dryRun plans the changes, sending reads but those of entities created by the plan.
+func (krt *KongoRoundTripper) dryRun(newRequest *http.Request) (*http.Response, error) {
+	if !isMutating(newRequest.Method) {
+		return krt.read(newRequest)
+	}
+	change, response, err := krt.plan.plan(newRequest, krt.read)
+	if err != nil {
+		return nil, err
+	}
+	if krt.logger != nil {
+		krt.logger.Info("planned change", "action", change.Action(), "kind", change.Kind, "id", change.ID,
+			"name", change.Name)
+	}
+	return response, nil
+}
+
+func (krt *KongoRoundTripper) read(newRequest *http.Request) (*http.Response, error) {
+	if krt.plan.isPlaceholder(newRequest) {
+		return placeholderResponse(newRequest), nil
+	}
+	return krt.traced(newRequest)
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"bytes"
+	"github.com/ciroque/kongo/client/kongotest"
+	"github.com/hbagdi/go-kong/kong"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	server := kongotest.NewServer()
+	defer server.Close()
+
+	service := &K8sService{
+		Addresses: []*string{kong.String("10.0.0.1"), kong.String("10.0.0.2")},
+		Name:      "kongo.planned",
+		Path:      "/planned",
+		Port:      80,
+	}
+	collections := []string{"services", "routes", "upstreams", "targets"}
+
+	t.Run("registration", func(t *testing.T) {
+		kongo, err := NewKongo(&server.URL, WithDryRun())
+		if err != nil {
+			t.Fatal(err)
+		}
+		registered, err := kongo.RegisterK8sService(service)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, collection := range collections {
+			if server.Count(collection) != 0 {
+				t.Fatalf("Expected a dry run to leave Kong as it was, got %d %s", server.Count(collection), collection)
+			}
+		}
+		if !strings.HasPrefix(*registered.Services[0].ID, DryRunIDPrefix) || !strings.HasPrefix(*registered.Routes[0].ID, DryRunIDPrefix) {
+			t.Fatalf("Expected the entities of a dry run to have placeholder IDs, got: %s", String(*registered))
+		}
+
+		planned := map[string]int{}
+		for _, change := range kongo.Plan().Changes() {
+			if change.Action() != "create" {
+				t.Errorf("Expected a registration on an empty Kong to create entities only, got: %s", change)
+			}
+			planned[change.Kind]++
+		}
+		if planned[KindUpstream] != 1 || planned[KindTarget] != 2 || planned[KindService] != 1 || planned[KindRoute] != 1 {
+			t.Fatalf("Unexpected changes planned: %v", planned)
+		}
+	})
+
+	t.Run("deregistration", func(t *testing.T) {
+		live, err := NewKongo(&server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = live.RegisterK8sService(service)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := map[string]int{}
+		for _, collection := range collections {
+			counts[collection] = server.Count(collection)
+		}
+
+		kongo, err := NewKongo(&server.URL, WithDryRun())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = kongo.DeregisterK8sService(service.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, collection := range collections {
+			if server.Count(collection) != counts[collection] {
+				t.Fatalf("Expected a dry run to leave the %s in Kong", collection)
+			}
+		}
+		var plan bytes.Buffer
+		kongo.Plan().Write(&plan)
+		for _, expected := range []string{"changes planned", "delete route kongo.planned.route (", "delete service kongo.planned.service ("} {
+			if !strings.Contains(plan.String(), expected) {
+				t.Fatalf("Expected the plan to have '%s', got:\n%s", expected, plan.String())
+			}
+		}
+	})
+	t.Run("entity named like a placeholder", func(t *testing.T) {
+		live, err := NewKongo(&server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = live.CreateUpstream(&UpstreamDef{Name: DryRunIDPrefix + "upstream"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		kongo, err := NewKongo(&server.URL, WithDryRun())
+		if err != nil {
+			t.Fatal(err)
+		}
+		upstream, err := kongo.GetUpstream(DryRunIDPrefix + "upstream")
+		if err != nil || upstream == nil || *upstream.Name != DryRunIDPrefix+"upstream" {
+			t.Fatalf("Expected a dry run to read an entity of Kong named like a placeholder, got: %v", err)
+		}
+	})
+}
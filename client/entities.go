@@ -0,0 +1,87 @@
+package client
+
+import (
+	"fmt"
+	"github.com/hbagdi/go-kong/kong"
+)
+
+// KongEntity is an Upstream, Service or Route whose name parses with the naming strategy.
+type KongEntity struct {
+	Kind       string
+	ID         string
+	Name       string
+	Components NameComponents
+	// Owner is the owner tag of the entity, if any.
+	Owner string
+}
+
+// ListKongEntities lists the Routes, Services and Upstreams named by the naming strategy, in that order, so that
+// deleting them in order never leaves a Route without its Service.
+func (kongo *Kongo) ListKongEntities() ([]*KongEntity, error) {
+	entities := []*KongEntity{}
+	add := func(id *string, name *string, tags []*string) {
+		if name == nil {
+			return
+		}
+		components, ok := kongo.naming.ParseKongName(*name)
+		if ok {
+			owner, _ := OwnerOf(tags)
+			entities = append(entities, &KongEntity{Kind: components.Kind, ID: *id, Name: *name, Components: components, Owner: owner})
+		}
+	}
+
+	routes, err := kongo.ListRoutes()
+	if err != nil {
+		return nil, fmt.Errorf("error listing Routes: %v", err)
+	}
+	for _, route := range routes {
+		add(route.ID, route.Name, route.Tags)
+	}
+
+	services, err := kongo.ListServices()
+	if err != nil {
+		return nil, fmt.Errorf("error listing Services: %v", err)
+	}
+	for _, service := range services {
+		add(service.ID, service.Name, service.Tags)
+	}
+
+	upstreams, err := kongo.ListUpstreams()
+	if err != nil {
+		return nil, fmt.Errorf("error listing Upstreams: %v", err)
+	}
+	for _, upstream := range upstreams {
+		add(upstream.ID, upstream.Name, upstream.Tags)
+	}
+
+	return entities, nil
+}
+
+// DeleteKongEntity deletes the entity, a missing entity not being an error. Deleting an Upstream deletes its Targets.
+func (kongo *Kongo) DeleteKongEntity(entity *KongEntity) error {
+	var err error
+	switch entity.Kind {
+	case KindRoute:
+		_, err = kongo.DeleteRoute(entity.ID)
+	case KindService:
+		_, err = kongo.DeleteService(entity.ID)
+	case KindUpstream:
+		_, err = kongo.DeleteUpstream(entity.ID)
+	case KindPlugin:
+		_, err = kongo.DeletePlugin(entity.ID)
+	case KindCertificate:
+		_, err = kongo.DeleteCertificate(entity.ID)
+	default:
+		return fmt.Errorf("unknown kind '%s' of '%s'", entity.Kind, entity.Name)
+	}
+	if err != nil && !kong.IsNotFoundErr(err) {
+		return fmt.Errorf("error deleting %s '%s': %v", entity.Kind, entity.Name, err)
+	}
+	return nil
+}
+
+func (kongo *Kongo) deleteTask(entity *KongEntity) *Task {
+	return &Task{Name: entity.Kind + " " + entity.Name, Run: func() error {
+		return kongo.DeleteKongEntity(entity)
+	}}
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultParallelism bounds the requests a bulk operation has in flight at once.
+const DefaultParallelism = 8
+
+// Task is an item of a bulk operation, e.g. `Route kongo.orders.route`. Its errors name the item.
+type Task struct {
+	Name string
+	Run  func() error
+}
+
+type TaskError struct {
+	Name string
+	Err  error
+}
+
+func (taskError *TaskError) Error() string {
+	return fmt.Sprintf("%s: %v", taskError.Name, taskError.Err)
+}
+
+// BulkError holds the errors of the tasks of a bulk operation that failed, in the order of the tasks.
+type BulkError struct {
+	Tasks  int
+	Errors []*TaskError
+}
+
+func (bulkError *BulkError) Error() string {
+	messages := []string{}
+	for _, taskError := range bulkError.Errors {
+		messages = append(messages, taskError.Err.Error())
+	}
+	return fmt.Sprintf("%d of %d failed: %s", len(bulkError.Errors), bulkError.Tasks, strings.Join(messages, "; "))
+}
+
+// Executor runs the tasks of bulk operations on a bounded number of workers.
+type Executor struct {
+	parallelism int
+}
+
+func NewExecutor(parallelism int) *Executor {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	return &Executor{parallelism: parallelism}
+}
+
+// WithParallelism bounds the requests the bulk operations of a Kongo have in flight at once, DefaultParallelism
+// otherwise.
+func WithParallelism(parallelism int) Option {
+	return func(settings *kongoSettings) error {
+		if parallelism < 1 {
+			return fmt.Errorf("parallelism must be at least 1, got %d", parallelism)
+		}
+		settings.executor = NewExecutor(parallelism)
+		return nil
+	}
+}
+
+// Run runs every task, returning a BulkError when any failed. Once the context is done, the tasks not yet started fail
+// with its error rather than run.
+func (executor *Executor) Run(ctx context.Context, tasks []*Task) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	errors := make([]error, len(tasks))
+	indexes := make(chan int)
+	var waitGroup sync.WaitGroup
+	for worker := 0; worker < executor.parallelism && worker < len(tasks); worker++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for i := range indexes {
+				if ctx.Err() != nil {
+					errors[i] = ctx.Err()
+					continue
+				}
+				errors[i] = tasks[i].Run()
+			}
+		}()
+	}
+	for i := range tasks {
+		indexes <- i
+	}
+	close(indexes)
+	waitGroup.Wait()
+
+	bulkError := &BulkError{Tasks: len(tasks)}
+	for i, err := range errors {
+		if err != nil {
+			bulkError.Errors = append(bulkError.Errors, &TaskError{Name: tasks[i].Name, Err: err})
+		}
+	}
+	if len(bulkError.Errors) > 0 {
+		return bulkError
+	}
+	return nil
+}
+
+// RunPhases runs the phases one after the other, as entities have to be deleted before those they refer to. The phases
+// following one that failed are not run, their entities still being referred to.
+func (executor *Executor) RunPhases(ctx context.Context, phases ...[]*Task) error {
+	for _, tasks := range phases {
+		err := executor.Run(ctx, tasks)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithContext gives a copy of the Kongo making its requests with the context, for the operations of the copy to be
+// cancelled along with it.
+func (kongo *Kongo) WithContext(ctx context.Context) *Kongo {
+	withContext := *kongo
+	withContext.context = ctx
+	return &withContext
+}
+
+func (kongo *Kongo) bulk() *Executor {
+	if kongo.executor == nil {
+		return NewExecutor(DefaultParallelism)
+	}
+	return kongo.executor
+}
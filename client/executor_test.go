@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/ciroque/kongo/client/kongotest"
+	"github.com/hbagdi/go-kong/kong"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExecutor(t *testing.T) {
+	var mutex sync.Mutex
+	running, maxRunning := 0, 0
+
+	tasks := []*Task{}
+	for i := 0; i < 20; i++ {
+		i := i
+		tasks = append(tasks, &Task{Name: fmt.Sprintf("task %d", i), Run: func() error {
+			mutex.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mutex.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mutex.Lock()
+			running--
+			mutex.Unlock()
+			if i%5 == 0 {
+				return fmt.Errorf("error running task %d", i)
+			}
+			return nil
+		}})
+	}
+
+	err := NewExecutor(3).Run(context.Background(), tasks)
+	if maxRunning != 3 {
+		t.Fatalf("Expected 3 tasks to run at once, got: %d", maxRunning)
+	}
+	bulkError, ok := err.(*BulkError)
+	if !ok || bulkError.Tasks != 20 || len(bulkError.Errors) != 4 {
+		t.Fatalf("Expected 4 of 20 tasks to fail, got: %v", err)
+	}
+	for i, taskError := range bulkError.Errors {
+		if taskError.Name != fmt.Sprintf("task %d", i*5) {
+			t.Fatalf("Expected the errors in the order of the tasks, got: %v", err)
+		}
+	}
+}
+
+func TestExecutorCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ran := 0
+	tasks := []*Task{}
+	for i := 0; i < 5; i++ {
+		tasks = append(tasks, &Task{Name: fmt.Sprintf("task %d", i), Run: func() error {
+			ran++
+			cancel()
+			return nil
+		}})
+	}
+
+	err := NewExecutor(1).Run(ctx, tasks)
+	bulkError, ok := err.(*BulkError)
+	if ran != 1 || !ok || len(bulkError.Errors) != 4 || bulkError.Errors[0].Err != context.Canceled {
+		t.Fatalf("Expected the tasks after the cancellation not to run, %d ran: %v", ran, err)
+	}
+
+	later := []*Task{{Name: "later", Run: func() error {
+		t.Fatalf("Expected the phases after a failed one not to run")
+		return nil
+	}}}
+	failing := []*Task{{Name: "failing", Run: func() error { return fmt.Errorf("error") }}}
+	err = NewExecutor(2).RunPhases(context.Background(), failing, later)
+	if err == nil {
+		t.Fatalf("Expected the failed phase to fail")
+	}
+}
+
+func TestDeleteAllInParallel(t *testing.T) {
+	// Deleting everything is never run against a real Kong.
+	server := kongotest.NewServer()
+	defer server.Close()
+	kongo, err := NewKongo(&server.URL, WithParallelism(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream, err := kongo.CreateUpstream(&UpstreamDef{Name: "kongo-test-upstream"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 25; i++ {
+		_, err := kongo.CreateTarget(NewTargetDef(fmt.Sprintf("10.0.0.%d:80", i), upstream, 100))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = kongo.DeleteAllTargets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets, _ := kongo.ListTargets(*upstream.ID)
+	if len(targets) != 0 {
+		t.Fatalf("Expected every Target to be deleted, %d are left", len(targets))
+	}
+	err = kongo.DeleteAllUpstreams()
+	if err != nil || server.Count("upstreams") != 0 {
+		t.Fatalf("Expected every Upstream to be deleted: %v", err)
+	}
+
+	for i, name := range []string{"cors", "prometheus", "key-auth", "acl", "request-id"} {
+		_, err = kongo.CreatePlugin(&PluginDef{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = kongo.CreateCertificate(&CertificateDef{Cert: "cert", Key: "key", SNIs: kong.StringSlice(fmt.Sprintf("%d.example.com", i))})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = kongo.DeleteAllPlugins()
+	if err != nil || server.Count("plugins") != 0 {
+		t.Fatalf("Expected every Plugin to be deleted: %v", err)
+	}
+	err = kongo.DeleteAllCertificates()
+	if err != nil || server.Count("certificates") != 0 {
+		t.Fatalf("Expected every Certificate to be deleted: %v", err)
+	}
+}
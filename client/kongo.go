@@ -6,8 +6,14 @@ import (
 	"fmt"
 	"github.com/hbagdi/go-kong/kong"
 	jsoniter "github.com/json-iterator/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 type KongClient interface {
@@ -18,28 +24,107 @@ type Kongo struct {
 	context     context.Context
 	listOptions kong.ListOpt
 	tags        []*string
+	naming      NamingStrategy
+	breaker     *CircuitBreaker
+	executor    *Executor
+	tracer      trace.Tracer
+	logger      Logger
+	plan        *Plan
+}
+
+// Option configures a Kongo created by NewKongo.
+type Option func(settings *kongoSettings) error
+
+type kongoSettings struct {
+	cassetteMode   CassetteMode
+	cassettePath   string
+	retryPolicy    *RetryPolicy
+	limiter        *rate.Limiter
+	breaker        *CircuitBreaker
+	executor       *Executor
+	tracerProvider trace.TracerProvider
+	logger         Logger
+	auditor        *auditor
+	plan           *Plan
+}
+
+// WithCassette records the interactions with Kong to the cassette at the path, or replays them from it without
+// talking to Kong, depending on the mode.
+func WithCassette(mode CassetteMode, path string) Option {
+	return func(settings *kongoSettings) error {
+		if mode != CassetteRecord && mode != CassetteReplay {
+			return fmt.Errorf("unknown cassette mode '%s', expected %s or %s", mode, CassetteRecord, CassetteReplay)
+		}
+		settings.cassetteMode = mode
+		settings.cassettePath = path
+		return nil
+	}
 }
 
-func NewKongo(baseUrl *string) (*Kongo, error) {
+func NewKongo(baseUrl *string, options ...Option) (*Kongo, error) {
 	headers := []string{"Content-Type: application/json", "Accept: application/json"}
 
+	var settings kongoSettings
+	for _, option := range options {
+		err := option(&settings)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	kongo := new(Kongo)
+	kongo.naming = DefaultNamingStrategy
+	kongo.tracer = newTracer(settings.tracerProvider)
+	kongo.logger = settings.logger
+	if kongo.logger == nil {
+		kongo.logger = discardLogger{}
+	}
 
 	var tlsConfig tls.Config
 	tlsConfig.InsecureSkipVerify = true
 
-	defaultTransport := http.DefaultTransport.(*http.Transport)
-
-	defaultTransport.TLSClientConfig = &tlsConfig
+	// Each Kongo has a transport of its own rather than changing the one every client of the process shares.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tlsConfig
 
-	httpClient := http.DefaultClient
-	httpClient.Transport = &KongoRoundTripper{
+	var roundTripper http.RoundTripper = transport
+	if settings.cassetteMode != "" {
+		cassetteRoundTripper, err := NewCassetteRoundTripper(settings.cassetteMode, settings.cassettePath, roundTripper)
+		if err != nil {
+			return nil, err
+		}
+		roundTripper = cassetteRoundTripper
+	}
+	// Every attempt of a retried request waits for the rate limiter, counts for the circuit breaker and is audited.
+	roundTripper = &KongoRoundTripper{
 		headers:      headers,
-		roundTripper: defaultTransport,
+		roundTripper: roundTripper,
+		limiter:      settings.limiter,
+		breaker:      settings.breaker,
+		tracer:       kongo.tracer,
+		logger:       kongo.logger,
+		auditor:      settings.auditor,
+		plan:         settings.plan,
+	}
+	if settings.retryPolicy != nil {
+		retryRoundTripper := NewRetryRoundTripper(*settings.retryPolicy, roundTripper)
+		retryRoundTripper.logger = kongo.logger
+		roundTripper = retryRoundTripper
 	}
+	if settings.breaker != nil {
+		settings.breaker.logger = kongo.logger
+	}
+	if settings.auditor != nil {
+		settings.auditor.logger = kongo.logger
+	}
+	kongo.breaker = settings.breaker
+	kongo.executor = settings.executor
+	kongo.plan = settings.plan
+
+	httpClient := &http.Client{Transport: roundTripper}
 	kongClient, err := kong.NewClient(baseUrl, httpClient)
 	if err != nil {
-		fmt.Errorf("Something went horribly horribly wrong: %s", err)
+		return nil, fmt.Errorf("error creating the Kong client: %v", err)
 	}
 
 	kongo.Kong = kongClient
@@ -52,19 +137,22 @@ type RouteDef struct {
 	Paths     []*string
 	Service   *kong.Service
 	StripPath bool
+	Hosts     []*string
+	Methods   []*string
+	Protocols []*string
 }
 
 func (kongo *Kongo) CreateRoute(routeDef *RouteDef) (*kong.Route, error) {
 	kongRoute := kong.Route{
 		CreatedAt:               nil,
-		Hosts:                   nil,
+		Hosts:                   routeDef.Hosts,
 		Headers:                 nil,
 		ID:                      nil,
 		Name:                    kong.String(routeDef.Name),
-		Methods:                 nil,
+		Methods:                 routeDef.Methods,
 		Paths:                   routeDef.Paths,
 		PreserveHost:            nil,
-		Protocols:               nil,
+		Protocols:               routeDef.Protocols,
 		RegexPriority:           nil,
 		Service:                 routeDef.Service,
 		StripPath:               kong.Bool(routeDef.StripPath),
@@ -129,17 +217,18 @@ func (kongo *Kongo) CreateTarget(targetDef *TargetDef) (*kong.Target, error) {
 }
 
 type UpstreamDef struct {
-	Name string
-	// TODO: Add Healthchecks configuration
+	Name         string
+	Algorithm    string
+	Healthchecks *kong.Healthcheck
 }
 
 func (kongo *Kongo) CreateUpstream(upstreamDef *UpstreamDef) (*kong.Upstream, error) {
 	kongUpstream := kong.Upstream{
 		ID:                 nil,
 		Name:               kong.String(upstreamDef.Name),
-		Algorithm:          nil,
+		Algorithm:          optionalString(upstreamDef.Algorithm),
 		Slots:              nil,
-		Healthchecks:       nil,
+		Healthchecks:       upstreamDef.Healthchecks,
 		CreatedAt:          nil,
 		HashOn:             nil,
 		HashFallback:       nil,
@@ -152,6 +241,51 @@ func (kongo *Kongo) CreateUpstream(upstreamDef *UpstreamDef) (*kong.Upstream, er
 	return kongo.Kong.Upstreams.Create(kongo.context, &kongUpstream)
 }
 
+type CertificateDef struct {
+	Cert string
+	Key  string
+	SNIs []*string
+}
+
+func (kongo *Kongo) CreateCertificate(certificateDef *CertificateDef) (*kong.Certificate, error) {
+	kongCertificate := kong.Certificate{
+		Cert: kong.String(certificateDef.Cert),
+		Key:  kong.String(certificateDef.Key),
+		SNIs: certificateDef.SNIs,
+		Tags: kongo.tags,
+	}
+	return kongo.Kong.Certificates.Create(kongo.context, &kongCertificate)
+}
+
+func (kongo *Kongo) DeleteCertificate(id string) (*kong.Certificate, error) {
+	return nil, kongo.Kong.Certificates.Delete(kongo.context, kong.String(id))
+}
+
+type PluginDef struct {
+	Name     string
+	Config   kong.Configuration
+	Service  *kong.Service
+	Route    *kong.Route
+	Consumer *kong.Consumer
+}
+
+func (kongo *Kongo) CreatePlugin(pluginDef *PluginDef) (*kong.Plugin, error) {
+	kongPlugin := kong.Plugin{
+		Name:     kong.String(pluginDef.Name),
+		Config:   pluginDef.Config,
+		Service:  pluginDef.Service,
+		Route:    pluginDef.Route,
+		Consumer: pluginDef.Consumer,
+		Enabled:  kong.Bool(true),
+		Tags:     kongo.tags,
+	}
+	return kongo.Kong.Plugins.Create(kongo.context, &kongPlugin)
+}
+
+func (kongo *Kongo) DeletePlugin(id string) (*kong.Plugin, error) {
+	return nil, kongo.Kong.Plugins.Delete(kongo.context, kong.String(id))
+}
+
 func (kongo *Kongo) DeleteRoute(idOrName string) (*kong.Route, error) {
 	return nil, kongo.Kong.Routes.Delete(kongo.context, kong.String(idOrName))
 }
@@ -178,6 +312,14 @@ func (kongo *Kongo) GetRoute(idOrName string) (*kong.Route, error) {
 	return kongo.Kong.Routes.Get(kongo.context, kong.String(idOrName))
 }
 
+func (kongo *Kongo) GetCertificate(id string) (*kong.Certificate, error) {
+	return kongo.Kong.Certificates.Get(kongo.context, kong.String(id))
+}
+
+func (kongo *Kongo) GetPlugin(id string) (*kong.Plugin, error) {
+	return kongo.Kong.Plugins.Get(kongo.context, kong.String(id))
+}
+
 func (kongo *Kongo) GetService(idOrName string) (*kong.Service, error) {
 	return kongo.Kong.Services.Get(kongo.context, kong.String(idOrName))
 }
@@ -186,38 +328,290 @@ func (kongo *Kongo) GetUpstream(idOrName string) (*kong.Upstream, error) {
 	return kongo.Kong.Upstreams.Get(kongo.context, kong.String(idOrName))
 }
 
+// The List methods page through every entity, Kong giving 100 at most per request.
+
+func (kongo *Kongo) ListCertificates() ([]*kong.Certificate, error) {
+	certificates := []*kong.Certificate{}
+	for listOptions := kongo.firstPage(); listOptions != nil; {
+		page, next, err := kongo.Kong.Certificates.List(kongo.context, listOptions)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, page...)
+		listOptions = next
+	}
+	return certificates, nil
+}
+
+func (kongo *Kongo) ListPlugins() ([]*kong.Plugin, error) {
+	plugins := []*kong.Plugin{}
+	for listOptions := kongo.firstPage(); listOptions != nil; {
+		page, next, err := kongo.Kong.Plugins.List(kongo.context, listOptions)
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, page...)
+		listOptions = next
+	}
+	return plugins, nil
+}
+
 func (kongo *Kongo) ListRoutes() ([]*kong.Route, error) {
-	services, _, err := kongo.Kong.Routes.List(kongo.context, &kongo.listOptions)
-	return services, err
+	routes := []*kong.Route{}
+	for listOptions := kongo.firstPage(); listOptions != nil; {
+		page, next, err := kongo.Kong.Routes.List(kongo.context, listOptions)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, page...)
+		listOptions = next
+	}
+	return routes, nil
 }
 
 func (kongo *Kongo) ListServices() ([]*kong.Service, error) {
-	services, _, err := kongo.Kong.Services.List(kongo.context, &kongo.listOptions)
-	return services, err
+	services := []*kong.Service{}
+	for listOptions := kongo.firstPage(); listOptions != nil; {
+		page, next, err := kongo.Kong.Services.List(kongo.context, listOptions)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, page...)
+		listOptions = next
+	}
+	return services, nil
 }
 
 func (kongo *Kongo) ListTargets(upstreamId string) ([]*kong.Target, error) {
-	targets, _, err := kongo.Kong.Targets.List(kongo.context, kong.String(upstreamId), &kongo.listOptions)
-	return targets, err
+	targets := []*kong.Target{}
+	for listOptions := kongo.firstPage(); listOptions != nil; {
+		page, next, err := kongo.Kong.Targets.List(kongo.context, kong.String(upstreamId), listOptions)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, page...)
+		listOptions = next
+	}
+	return targets, nil
 }
 
 func (kongo *Kongo) ListUpstreams() ([]*kong.Upstream, error) {
-	upstreams, _, err := kongo.Kong.Upstreams.List(kongo.context, &kongo.listOptions)
-	return upstreams, err
+	upstreams := []*kong.Upstream{}
+	for listOptions := kongo.firstPage(); listOptions != nil; {
+		page, next, err := kongo.Kong.Upstreams.List(kongo.context, listOptions)
+		if err != nil {
+			return nil, err
+		}
+		upstreams = append(upstreams, page...)
+		listOptions = next
+	}
+	return upstreams, nil
+}
+
+// firstPage copies the list options of the Kongo, for paging not to change them.
+func (kongo *Kongo) firstPage() *kong.ListOpt {
+	listOptions := kongo.listOptions
+	return &listOptions
 }
 
+// K8sService describes a Kubernetes Service to register with Kong. Its fields describe a single port with a single
+// Route, unless Ports are given.
 type K8sService struct {
-	Addresses []*string
+	Addresses    []*string
+	Endpoints    []*K8sEndpoint
+	Name         string
+	Path         string
+	Port         int
+	StripPath    bool
+	Hosts        []*string
+	Methods      []*string
+	Protocols    []*string
+	Plugins      []*PluginDef
+	Algorithm    string
+	Healthchecks *kong.Healthcheck
+	// Ports replace the single port described by the fields above, each getting an Upstream and Service of its own.
+	Ports []*K8sServicePort
+}
+
+// K8sServicePort is a port of a K8sService, reached through its own Upstream and Service. Name tells the Kong names of
+// the ports apart, and may be left empty for one of them.
+type K8sServicePort struct {
+	Name         string
+	Port         int
+	Path         string
+	Addresses    []*string
+	Endpoints    []*K8sEndpoint
+	Routes       []*K8sRoute
+	Plugins      []*PluginDef
+	Algorithm    string
+	Healthchecks *kong.Healthcheck
+}
+
+// K8sRoute is a Route to a K8sServicePort. Name tells the Kong names of the Routes of a port apart, and may be left
+// empty for one of them.
+type K8sRoute struct {
 	Name      string
-	Path      string
-	Port      int
+	Paths     []*string
+	StripPath bool
+	Hosts     []*string
+	Methods   []*string
+	Protocols []*string
+}
+
+// K8sEndpoint is a Target along with its weight, a weight of 0 keeping Kong from sending it new requests.
+type K8sEndpoint struct {
+	Address string
+	Port    int
+	Weight  int
+}
+
+// Target joins the address and port, bracketing IPv6 addresses.
+func (endpoint *K8sEndpoint) Target() string {
+	return net.JoinHostPort(endpoint.Address, strconv.Itoa(endpoint.Port))
+}
+
+// TargetDefs are the Targets for the Addresses, weighted 1, followed by those for the Endpoints.
+func (k8sService *K8sService) TargetDefs(upstream *kong.Upstream) []*TargetDef {
+	return targetDefs(k8sService.Addresses, k8sService.Endpoints, upstream)
+}
+
+// TargetDefs are the Targets for the Addresses, weighted 1, followed by those for the Endpoints.
+func (port *K8sServicePort) TargetDefs(upstream *kong.Upstream) []*TargetDef {
+	return targetDefs(port.Addresses, port.Endpoints, upstream)
+}
+
+func targetDefs(addresses []*string, endpoints []*K8sEndpoint, upstream *kong.Upstream) []*TargetDef {
+	targetDefs := []*TargetDef{}
+	for _, address := range addresses {
+		targetDefs = append(targetDefs, NewTargetDef(*address, upstream, 1))
+	}
+	for _, endpoint := range endpoints {
+		targetDefs = append(targetDefs, NewTargetDef(endpoint.Target(), upstream, endpoint.Weight))
+	}
+	return targetDefs
+}
+
+// ServicePorts gives the Ports, or the single port the other fields describe.
+func (k8sService *K8sService) ServicePorts() []*K8sServicePort {
+	if len(k8sService.Ports) > 0 {
+		return k8sService.Ports
+	}
+	return []*K8sServicePort{{
+		Port:      k8sService.Port,
+		Path:      k8sService.Path,
+		Addresses: k8sService.Addresses,
+		Endpoints: k8sService.Endpoints,
+		Routes: []*K8sRoute{{
+			Paths:     kong.StringSlice(k8sService.Path),
+			StripPath: k8sService.StripPath,
+			Hosts:     k8sService.Hosts,
+			Methods:   k8sService.Methods,
+			Protocols: k8sService.Protocols,
+		}},
+		Plugins:      k8sService.Plugins,
+		Algorithm:    k8sService.Algorithm,
+		Healthchecks: k8sService.Healthchecks,
+	}}
+}
+
+// K8sServiceOwner is the tag marking the Kong entities registered for a K8sService.
+func K8sServiceOwner(baseName string) string {
+	return "kongo-service:" + baseName
+}
+
+// k8sServiceResourceSet names the entities of every port with the naming strategy, the port and Route names being
+// appended to the name of the Kubernetes Service when given.
+func (kongo *Kongo) k8sServiceResourceSet(k8sService *K8sService) (*ResourceSet, error) {
+	namespace, name := SplitBaseName(k8sService.Name)
+	resourceSet := new(ResourceSet)
+
+	for _, port := range k8sService.ServicePorts() {
+		portComponents := NameComponents{Namespace: namespace, Name: joinNonEmpty(name, port.Name)}
+		kongNames, err := NamesFor(kongo.naming, portComponents)
+		if err != nil {
+			return nil, err
+		}
+
+		upstream := &kong.Upstream{Name: kong.String(kongNames.UpstreamName)}
+		service := &kong.Service{Name: kong.String(kongNames.ServiceName)}
+		resourceSet.Upstreams = append(resourceSet.Upstreams, &UpstreamDef{
+			Name:         kongNames.UpstreamName,
+			Algorithm:    port.Algorithm,
+			Healthchecks: port.Healthchecks,
+		})
+		resourceSet.Targets = append(resourceSet.Targets, port.TargetDefs(upstream)...)
+		resourceSet.Services = append(resourceSet.Services, &ServiceDef{
+			Name: kongNames.ServiceName,
+			Host: kongNames.UpstreamName,
+			Path: port.Path,
+			Port: port.Port,
+		})
+
+		for _, route := range port.Routes {
+			routeName, err := kongo.naming.KongName(NameComponents{
+				Namespace: namespace,
+				Name:      joinNonEmpty(portComponents.Name, route.Name),
+				Kind:      KindRoute,
+			})
+			if err != nil {
+				return nil, err
+			}
+			resourceSet.Routes = append(resourceSet.Routes, &RouteDef{
+				Name:      routeName,
+				Paths:     route.Paths,
+				Service:   service,
+				StripPath: route.StripPath,
+				Hosts:     route.Hosts,
+				Methods:   route.Methods,
+				Protocols: route.Protocols,
+			})
+		}
+
+		for _, plugin := range port.Plugins {
+			pluginDef := *plugin
+			pluginDef.Service = service
+			resourceSet.Plugins = append(resourceSet.Plugins, &pluginDef)
+		}
+	}
+
+	return resourceSet, nil
 }
 
+func joinNonEmpty(name string, suffix string) string {
+	if suffix == "" {
+		return name
+	}
+	return name + "." + suffix
+}
+
+// RegisteredKongResources holds the entities of every port of a K8sService, in the order of the ports.
 type RegisteredKongResources struct {
-	Service  *kong.Service
-	Targets  []*kong.Target
-	Route    *kong.Route
-	Upstream *kong.Upstream
+	Services  []*kong.Service
+	Targets   []*kong.Target
+	Routes    []*kong.Route
+	Upstreams []*kong.Upstream
+	Plugins   []*kong.Plugin
+}
+
+func newRegisteredKongResources(resourceSet *ResourceSet, applied *AppliedResourceSet) *RegisteredKongResources {
+	registered := &RegisteredKongResources{Plugins: applied.Plugins}
+	for _, upstreamDef := range resourceSet.Upstreams {
+		if upstream, found := applied.Upstreams[upstreamDef.Name]; found {
+			registered.Upstreams = append(registered.Upstreams, upstream)
+			registered.Targets = append(registered.Targets, applied.Targets[upstreamDef.Name]...)
+		}
+	}
+	for _, serviceDef := range resourceSet.Services {
+		if service, found := applied.Services[serviceDef.Name]; found {
+			registered.Services = append(registered.Services, service)
+		}
+	}
+	for _, routeDef := range resourceSet.Routes {
+		if route, found := applied.Routes[routeDef.Name]; found {
+			registered.Routes = append(registered.Routes, route)
+		}
+	}
+	return registered
 }
 
 func String(resources RegisteredKongResources) string {
@@ -225,127 +619,295 @@ func String(resources RegisteredKongResources) string {
 	return string(json)
 }
 
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return kong.String(value)
+}
+
 type KongNames struct {
 	UpstreamName string
 	ServiceName  string
 	RouteName    string
 }
 
+const (
+	KindRoute       = "route"
+	KindService     = "service"
+	KindUpstream    = "upstream"
+	KindTarget      = "target"
+	KindPlugin      = "plugin"
+	KindCertificate = "certificate"
+)
+
+// NewKongNames gives the names of the DefaultNamingStrategy, without validating them.
 func NewKongNames(baseName string) *KongNames {
 	separator := "."
 	kongNames := new(KongNames)
-	kongNames.RouteName = strings.Join([]string{baseName, "route"}, separator)
-	kongNames.ServiceName = strings.Join([]string{baseName, "service"}, separator)
-	kongNames.UpstreamName = strings.Join([]string{baseName, "upstream"}, separator)
+	kongNames.RouteName = strings.Join([]string{baseName, KindRoute}, separator)
+	kongNames.ServiceName = strings.Join([]string{baseName, KindService}, separator)
+	kongNames.UpstreamName = strings.Join([]string{baseName, KindUpstream}, separator)
 	return kongNames
 }
 
+// ParseKongName reverses NewKongNames, returning the base name and the kind of entity the name is for.
+func ParseKongName(name string) (baseName string, kind string, ok bool) {
+	separator := strings.LastIndex(name, ".")
+	if separator < 1 {
+		return "", "", false
+	}
+	baseName, kind = name[:separator], name[separator+1:]
+	switch kind {
+	case KindRoute, KindService, KindUpstream:
+		return baseName, kind, true
+	}
+	return "", "", false
+}
+
+// DeleteAllRoutes deletes the Routes in parallel, returning a BulkError listing those that could not be.
 func (kongo *Kongo) DeleteAllRoutes() error {
+	return kongo.traced("DeleteAllRoutes", nil, func(kongo *Kongo) error {
+		return kongo.deleteAllRoutes()
+	})
+}
+
+func (kongo *Kongo) deleteAllRoutes() error {
 	routes, err := kongo.ListRoutes()
 	if err != nil {
 		return err
 	}
 
+	tasks := []*Task{}
 	for _, route := range routes {
-		_, err = kongo.DeleteRoute(*route.ID)
-		if err != nil {
-			fmt.Println("Error deleting Route: ", *route.Name, " - ", err)
-		}
+		entity := &KongEntity{Kind: KindRoute, ID: *route.ID, Name: nameOf(route.Name, route.ID)}
+		tasks = append(tasks, kongo.deleteTask(entity))
 	}
-
-	return nil
+	kongo.log().Info("deleting all", "kind", KindRoute, "count", len(tasks))
+	return kongo.bulk().Run(kongo.context, tasks)
 }
 
 func (kongo *Kongo) DeleteAllServices() error {
+	return kongo.traced("DeleteAllServices", nil, func(kongo *Kongo) error {
+		return kongo.deleteAllServices()
+	})
+}
+
+func (kongo *Kongo) deleteAllServices() error {
 	services, err := kongo.ListServices()
 	if err != nil {
 		return err
 	}
 
+	tasks := []*Task{}
 	for _, service := range services {
-		_, err = kongo.DeleteService(*service.ID)
-		if err != nil {
-			fmt.Println("Error deleting Service: ", *service.Name, " - ", err)
-		}
+		entity := &KongEntity{Kind: KindService, ID: *service.ID, Name: nameOf(service.Name, service.ID)}
+		tasks = append(tasks, kongo.deleteTask(entity))
 	}
-
-	return nil
+	kongo.log().Info("deleting all", "kind", KindService, "count", len(tasks))
+	return kongo.bulk().Run(kongo.context, tasks)
 }
 
+// DeleteAllTargets lists the Targets of the Upstreams, then deletes them, in parallel.
 func (kongo *Kongo) DeleteAllTargets() error {
+	return kongo.traced("DeleteAllTargets", nil, func(kongo *Kongo) error {
+		return kongo.deleteAllTargets()
+	})
+}
+
+func (kongo *Kongo) deleteAllTargets() error {
 	upstreams, err := kongo.ListUpstreams()
 	if err != nil {
 		return err
 	}
 
+	var mutex sync.Mutex
+	tasks := []*Task{}
+	listTasks := []*Task{}
 	for _, upstream := range upstreams {
-		targets, err := kongo.ListTargets(*upstream.ID)
-		if err != nil {
-			return err
-		}
-		for _, target := range targets {
-			targetDef := NewTargetDef(*target.Target, upstream, 0)
-			_, err := kongo.DeleteTarget(targetDef)
+		upstream := upstream
+		listTasks = append(listTasks, &Task{Name: "Upstream " + nameOf(upstream.Name, upstream.ID), Run: func() error {
+			targets, err := kongo.ListTargets(*upstream.ID)
 			if err != nil {
-				fmt.Println("Error deleting target:", upstream.Name, " : ", *target.Target)
+				return fmt.Errorf("error listing Targets for Upstream '%s': %v", *upstream.Name, err)
 			}
-		}
+			mutex.Lock()
+			defer mutex.Unlock()
+			for _, target := range targets {
+				targetDef := NewTargetDef(*target.Target, upstream, 0)
+				tasks = append(tasks, &Task{Name: "Target " + *upstream.Name + "/" + *target.Target, Run: func() error {
+					_, err := kongo.DeleteTarget(targetDef)
+					if err != nil {
+						return fmt.Errorf("error deleting Target '%s' of Upstream '%s': %v", targetDef.Target, *upstream.Name, err)
+					}
+					return nil
+				}})
+			}
+			return nil
+		}})
 	}
-
-	return nil
+	err = kongo.bulk().Run(kongo.context, listTasks)
+	if err != nil {
+		return err
+	}
+	kongo.log().Info("deleting all", "kind", KindTarget, "count", len(tasks))
+	return kongo.bulk().Run(kongo.context, tasks)
 }
 
 func (kongo *Kongo) DeleteAllUpstreams() error {
+	return kongo.traced("DeleteAllUpstreams", nil, func(kongo *Kongo) error {
+		return kongo.deleteAllUpstreams()
+	})
+}
+
+func (kongo *Kongo) deleteAllUpstreams() error {
 	upstreams, err := kongo.ListUpstreams()
 	if err != nil {
 		return err
 	}
 
+	tasks := []*Task{}
 	for _, upstream := range upstreams {
-		_, err = kongo.DeleteUpstream(*upstream.ID)
-		if err != nil {
-			fmt.Println("Error deleting Upstream: ", *upstream.Name, " - ", err)
-		}
+		entity := &KongEntity{Kind: KindUpstream, ID: *upstream.ID, Name: nameOf(upstream.Name, upstream.ID)}
+		tasks = append(tasks, kongo.deleteTask(entity))
 	}
+	kongo.log().Info("deleting all", "kind", KindUpstream, "count", len(tasks))
+	return kongo.bulk().Run(kongo.context, tasks)
+}
 
-	return nil
+// DeleteAllPlugins deletes the Plugins in parallel, those of Routes and Services as well as the global ones.
+func (kongo *Kongo) DeleteAllPlugins() error {
+	return kongo.traced("DeleteAllPlugins", nil, func(kongo *Kongo) error {
+		return kongo.deleteAllPlugins()
+	})
 }
 
-func (kongo *Kongo) DeregisterK8sService(baseName string) error {
-	kongNames := NewKongNames(baseName)
-	fmt.Println(kongNames)
+func (kongo *Kongo) deleteAllPlugins() error {
+	plugins, err := kongo.ListPlugins()
+	if err != nil {
+		return err
+	}
+
+	tasks := []*Task{}
+	for _, plugin := range plugins {
+		entity := &KongEntity{Kind: KindPlugin, ID: *plugin.ID, Name: nameOf(plugin.Name, plugin.ID)}
+		tasks = append(tasks, kongo.deleteTask(entity))
+	}
+	kongo.log().Info("deleting all", "kind", KindPlugin, "count", len(tasks))
+	return kongo.bulk().Run(kongo.context, tasks)
+}
 
-	var gerr error
+// DeleteAllCertificates deletes the Certificates, and with them their SNIs, in parallel. Services and Upstreams using a
+// Certificate as their client certificate are to be deleted first.
+func (kongo *Kongo) DeleteAllCertificates() error {
+	return kongo.traced("DeleteAllCertificates", nil, func(kongo *Kongo) error {
+		return kongo.deleteAllCertificates()
+	})
+}
 
-	registeredKongResources, err := kongo.LoadRegisteredKongResources(kongNames)
+func (kongo *Kongo) deleteAllCertificates() error {
+	certificates, err := kongo.ListCertificates()
 	if err != nil {
-		return fmt.Errorf("error loading registered Kong resources, %v", err)
+		return err
 	}
 
-	for _, target := range registeredKongResources.Targets {
-		targetDef := NewTargetDef(*target.Target, registeredKongResources.Upstream, 0)
-		_, err := kongo.DeleteTarget(targetDef)
-		if err != nil {
-			gerr = fmt.Errorf("error deleting Target '%s' %v", targetDef.Target, err)
-		}
+	tasks := []*Task{}
+	for _, certificate := range certificates {
+		entity := &KongEntity{Kind: KindCertificate, ID: *certificate.ID, Name: *certificate.ID}
+		tasks = append(tasks, kongo.deleteTask(entity))
 	}
+	kongo.log().Info("deleting all", "kind", KindCertificate, "count", len(tasks))
+	return kongo.bulk().Run(kongo.context, tasks)
+}
+
+// SetNamingStrategy changes how the entities of K8sServices are named, DefaultNamingStrategy being used otherwise.
+func (kongo *Kongo) SetNamingStrategy(naming NamingStrategy) {
+	kongo.naming = naming
+}
 
-	_, err = kongo.DeleteUpstream(*registeredKongResources.Upstream.Name)
+// KongNames names the entities of a K8sService with the naming strategy, the base name being `namespace.name`.
+func (kongo *Kongo) KongNames(baseName string) (*KongNames, error) {
+	namespace, name := SplitBaseName(baseName)
+	return NamesFor(kongo.naming, NameComponents{Namespace: namespace, Name: name})
+}
+
+// DeregisterK8sService deletes the entities of every port. Everything to delete is looked up before anything is
+// deleted; should a deletion fail, calling it again deletes what is left. Entities registered before they were tagged
+// with their owner are found by the names of a single port.
+func (kongo *Kongo) DeregisterK8sService(baseName string) error {
+	err := kongo.traced("DeregisterK8sService", serviceAttributes(baseName), func(kongo *Kongo) error {
+		return kongo.deregisterK8sService(baseName)
+	})
+	deregistrationsTotal.WithLabelValues(resultLabel(err)).Inc()
+	return err
+}
+
+func (kongo *Kongo) deregisterK8sService(baseName string) error {
+	owner := K8sServiceOwner(baseName)
+	kongNames, err := kongo.KongNames(baseName)
 	if err != nil {
-		gerr = fmt.Errorf("error deleting Upstream '%s': %v", kongNames.UpstreamName, err)
+		return err
 	}
 
-	_, err = kongo.DeleteRoute(*registeredKongResources.Route.Name)
+	routes, err := kongo.listRoutesTagged(owner)
 	if err != nil {
-		gerr = fmt.Errorf("error deleting Route '%s': %v", kongNames.RouteName, err)
+		return fmt.Errorf("error listing Routes of '%s': %v", baseName, err)
 	}
-
-	_, err = kongo.DeleteService(*registeredKongResources.Service.Name)
+	services, err := kongo.listServicesTagged(owner)
+	if err != nil {
+		return fmt.Errorf("error listing Services of '%s': %v", baseName, err)
+	}
+	upstreams, err := kongo.listUpstreamsTagged(owner)
 	if err != nil {
-		gerr = fmt.Errorf("error deleting Service '%s': %v", kongNames.ServiceName, err)
+		return fmt.Errorf("error listing Upstreams of '%s': %v", baseName, err)
+	}
+
+	entities := []*KongEntity{}
+	seen := make(map[string]bool)
+	add := func(kind string, id *string, name *string) {
+		if !seen[*id] {
+			seen[*id] = true
+			entities = append(entities, &KongEntity{Kind: kind, ID: *id, Name: *name})
+		}
+	}
+	for _, route := range routes {
+		add(KindRoute, route.ID, route.Name)
+	}
+	if route, err := kongo.GetRoute(kongNames.RouteName); err == nil {
+		add(KindRoute, route.ID, route.Name)
+	} else if !kong.IsNotFoundErr(err) {
+		return fmt.Errorf("error loading Route '%s': %v", kongNames.RouteName, err)
+	}
+	for _, service := range services {
+		add(KindService, service.ID, service.Name)
+	}
+	if service, err := kongo.GetService(kongNames.ServiceName); err == nil {
+		add(KindService, service.ID, service.Name)
+	} else if !kong.IsNotFoundErr(err) {
+		return fmt.Errorf("error loading Service '%s': %v", kongNames.ServiceName, err)
+	}
+	for _, upstream := range upstreams {
+		add(KindUpstream, upstream.ID, upstream.Name)
+	}
+	if upstream, err := kongo.GetUpstream(kongNames.UpstreamName); err == nil {
+		add(KindUpstream, upstream.ID, upstream.Name)
+	} else if !kong.IsNotFoundErr(err) {
+		return fmt.Errorf("error loading Upstream '%s': %v", kongNames.UpstreamName, err)
 	}
 
-	return gerr
+	kongo.log().Info("deregistering Kubernetes Service", "service", baseName, "entities", len(entities))
+
+	// Kong deletes the Plugins of Routes and Services, and the Targets of Upstreams, along with them. Routes go before
+	// the Services they refer to.
+	routeTasks, otherTasks := []*Task{}, []*Task{}
+	for _, entity := range entities {
+		if entity.Kind == KindRoute {
+			routeTasks = append(routeTasks, kongo.deleteTask(entity))
+		} else {
+			otherTasks = append(otherTasks, kongo.deleteTask(entity))
+		}
+	}
+	return kongo.bulk().RunPhases(kongo.context, routeTasks, otherTasks)
 }
 
 func (kongo *Kongo) LoadRegisteredKongResources(kongNames *KongNames) (*RegisteredKongResources, error) {
@@ -356,7 +918,7 @@ func (kongo *Kongo) LoadRegisteredKongResources(kongNames *KongNames) (*Register
 		return registeredKongResources, fmt.Errorf("error loading Upstream: '%s", kongNames.UpstreamName)
 	}
 
-	registeredKongResources.Upstream = upstream
+	registeredKongResources.Upstreams = []*kong.Upstream{upstream}
 
 	targets, err := kongo.ListTargets(*upstream.ID)
 	if err != nil {
@@ -364,80 +926,81 @@ func (kongo *Kongo) LoadRegisteredKongResources(kongNames *KongNames) (*Register
 	}
 	registeredKongResources.Targets = targets
 
-	registeredKongResources.Service, err = kongo.GetService(kongNames.ServiceName)
+	service, err := kongo.GetService(kongNames.ServiceName)
 	if err != nil {
 		return registeredKongResources, fmt.Errorf("error loading Service: '%s", kongNames.ServiceName)
 	}
+	registeredKongResources.Services = []*kong.Service{service}
 
-	registeredKongResources.Route, err = kongo.GetRoute(kongNames.RouteName)
+	route, err := kongo.GetRoute(kongNames.RouteName)
 	if err != nil {
 		return registeredKongResources, fmt.Errorf("error loading Route: '%s", kongNames.RouteName)
 	}
+	registeredKongResources.Routes = []*kong.Route{route}
 
 	return registeredKongResources, nil
 }
 
+// RegisterK8sService creates the Upstream, Targets, Service, Routes and Plugins of every port. Nothing is created when
+// any of the Upstreams, Services or Routes already exists, and what was created is deleted again when a step fails.
 func (kongo *Kongo) RegisterK8sService(k8sService *K8sService) (*RegisteredKongResources, error) {
-	kongNames := NewKongNames(k8sService.Name)
+	var registered *RegisteredKongResources
+	err := kongo.traced("RegisterK8sService", serviceAttributes(k8sService.Name), func(kongo *Kongo) error {
+		var err error
+		registered, err = kongo.registerK8sService(k8sService)
+		return err
+	})
+	registrationsTotal.WithLabelValues(resultLabel(err)).Inc()
+	return registered, err
+}
 
-	// 1 - Create Upstream
-	upstreamName := kongNames.UpstreamName
-	upstreamDef := UpstreamDef{Name: upstreamName}
-	kongUpstream, err := kongo.CreateUpstream(&upstreamDef)
+func (kongo *Kongo) registerK8sService(k8sService *K8sService) (*RegisteredKongResources, error) {
+	resourceSet, err := kongo.k8sServiceResourceSet(k8sService)
 	if err != nil {
-		return nil, fmt.Errorf("error creating Upstream: %s", err)
+		return nil, err
 	}
 
-	// retval
-	var registeredK8sService RegisteredKongResources
-	registeredK8sService.Upstream = kongUpstream
-
-	// 2 - Create Target(s)
-	targets := []*kong.Target{}
-	for _, target := range k8sService.Addresses {
-		targetDef := TargetDef{
-			Target:   *target,
-			Upstream: kongUpstream,
-			Weight:   1,
-		}
-		kongTarget, err := kongo.CreateTarget(&targetDef)
-		if err != nil {
-			return &registeredK8sService, fmt.Errorf("error creating Target (%s): %s", *target, err)
+	for _, upstreamDef := range resourceSet.Upstreams {
+		_, err := kongo.GetUpstream(upstreamDef.Name)
+		if err == nil || !kong.IsNotFoundErr(err) {
+			return nil, conflictError("Upstream", upstreamDef.Name, err)
 		}
-		targets = append(targets, kongTarget)
 	}
-
-	registeredK8sService.Targets = targets
-
-	// 3 - Create Service
-	serviceName := kongNames.ServiceName
-	serviceDef := ServiceDef{
-		Name: serviceName,
-		Host: upstreamName,
-		Path: k8sService.Path,
-		Port: k8sService.Port,
+	for _, serviceDef := range resourceSet.Services {
+		_, err := kongo.GetService(serviceDef.Name)
+		if err == nil || !kong.IsNotFoundErr(err) {
+			return nil, conflictError("Service", serviceDef.Name, err)
+		}
 	}
-	kongService, err := kongo.CreateService(&serviceDef)
-	if err != nil {
-		return &registeredK8sService, fmt.Errorf("error creating Service: %s", err)
+	for _, routeDef := range resourceSet.Routes {
+		_, err := kongo.GetRoute(routeDef.Name)
+		if err == nil || !kong.IsNotFoundErr(err) {
+			return nil, conflictError("Route", routeDef.Name, err)
+		}
 	}
 
-	registeredK8sService.Service = kongService
-
-	// 4 - Create Route
-	routeName := kongNames.RouteName
-	routeDef := RouteDef{
-		Name:      routeName,
-		Paths:     kong.StringSlice(k8sService.Path),
-		Service:   kongService,
-		StripPath: false,
-	}
-	kongRoute, err := kongo.CreateRoute(&routeDef)
+	owner := K8sServiceOwner(k8sService.Name)
+	applied, err := kongo.ApplyResourceSet(owner, resourceSet)
 	if err != nil {
-		return &registeredK8sService, fmt.Errorf("error creating Route: %s", err)
+		kongo.log().Warn("registration failed, rolling back", "service", k8sService.Name, "error", err)
+		rollbackErr := kongo.DeleteResourceSet(owner)
+		if rollbackErr != nil {
+			return nil, fmt.Errorf("error registering '%s': %v, rolling back failed: %v", k8sService.Name, err, rollbackErr)
+		}
+		return nil, fmt.Errorf("error registering '%s', rolled back: %v", k8sService.Name, err)
 	}
 
-	registeredK8sService.Route = kongRoute
+	kongo.log().Info("registered Kubernetes Service", "service", k8sService.Name)
+	return newRegisteredKongResources(resourceSet, applied), nil
+}
+
+func serviceAttributes(baseName string) []attribute.KeyValue {
+	return []attribute.KeyValue{attribute.String("kongo.service", baseName)}
+}
 
-	return &registeredK8sService, nil
+func conflictError(kind string, name string, err error) error {
+	if err == nil {
+		return fmt.Errorf("error creating %s: '%s' already exists", kind, name)
+	}
+	return fmt.Errorf("error loading %s '%s': %v", kind, name, err)
 }
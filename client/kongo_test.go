@@ -2,15 +2,35 @@ package client
 
 import (
 	"fmt"
+	"github.com/ciroque/kongo/client/kongotest"
 	"github.com/hbagdi/go-kong/kong"
+	"os"
+	"strings"
 	"testing"
 )
 
+// TestKongUrlVariable names the environment variable pointing the tests at a real Kong rather than the fake one.
+const TestKongUrlVariable = "KONGO_TEST_KONG_URL"
+
+// newTestKongo talks to the Kong named by TestKongUrlVariable, or else to a fake Kong closed along with the test.
+func newTestKongo(t *testing.T) *Kongo {
+	baseUrl := os.Getenv(TestKongUrlVariable)
+	if baseUrl == "" {
+		server := kongotest.NewServer()
+		t.Cleanup(server.Close)
+		baseUrl = server.URL
+	}
+	kongo, err := NewKongo(&baseUrl)
+	if err != nil {
+		t.Fatalf("Creation of Kongo failed: %s", err)
+	}
+	return kongo
+}
+
 
 func TestUpstreams(t *testing.T) {
 	upstreamName := "kongo-test-upstream"
-	baseUrl := "http://localhost:8001"
-	kongo, _ := NewKongo(&baseUrl)
+	kongo := newTestKongo(t)
 
 	kongo.DeleteUpstream(upstreamName)
 
@@ -50,8 +70,7 @@ func TestUpstreams(t *testing.T) {
 func TestServices(t *testing.T) {
 	serviceName := "kongo-test-service"
 	serviceHost := "kongo-test-service-host"
-	baseUrl := "http://localhost:8001"
-	kongo, _ := NewKongo(&baseUrl)
+	kongo := newTestKongo(t)
 
 	kongo.DeleteService(serviceName)
 
@@ -94,8 +113,7 @@ func TestServices(t *testing.T) {
 }
 
 func TestTargets(t *testing.T) {
-	baseUrl := "http://localhost:8001"
-	kongo, _ := NewKongo(&baseUrl)
+	kongo := newTestKongo(t)
 
 	upstreamDef := UpstreamDef{Name: "kongo-test-target-upstream"}
 
@@ -149,8 +167,7 @@ func TestTargets(t *testing.T) {
 }
 
 func TestRoutes(t *testing.T) {
-	baseUrl := "http://localhost:8001"
-	kongo, _ := NewKongo(&baseUrl)
+	kongo := newTestKongo(t)
 
 	routeName := "kongo-routes-test-route"
 
@@ -207,3 +224,141 @@ func TestRoutes(t *testing.T) {
 		t.Fatalf("A route should have been deleted.")
 	}
 }
+
+func TestListPagesThroughEveryEntity(t *testing.T) {
+	// Deleting everything is never run against a real Kong.
+	server := kongotest.NewServer()
+	defer server.Close()
+	kongo, err := NewKongo(&server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 150; i++ {
+		_, err := kongo.CreateUpstream(&UpstreamDef{Name: fmt.Sprintf("kongo-test-upstream-%d", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	upstreams, err := kongo.ListUpstreams()
+	if err != nil || len(upstreams) != 150 {
+		t.Fatalf("Expected every Upstream past the first page to be listed, got %d: %v", len(upstreams), err)
+	}
+	err = kongo.DeleteAllUpstreams()
+	if err != nil || server.Count("upstreams") != 0 {
+		t.Fatalf("Expected every Upstream to be deleted, %d are left: %v", server.Count("upstreams"), err)
+	}
+}
+
+// multiPortService has an http port with two Routes and a grpc port with a Plugin.
+func multiPortService() *K8sService {
+	return &K8sService{
+		Name: "kongo.multi",
+		Ports: []*K8sServicePort{
+			{
+				Port:      80,
+				Path:      "/",
+				Addresses: kong.StringSlice("10.0.0.1", "10.0.0.2"),
+				Routes: []*K8sRoute{
+					{Paths: kong.StringSlice("/multi")},
+					{Name: "api", Paths: kong.StringSlice("/multi/api"), StripPath: true},
+				},
+			},
+			{
+				Name:      "grpc",
+				Port:      9090,
+				Path:      "/",
+				Endpoints: []*K8sEndpoint{{Address: "10.0.0.3", Port: 9090, Weight: 100}},
+				Routes:    []*K8sRoute{{Paths: kong.StringSlice("/multi.Grpc")}},
+				Plugins:   []*PluginDef{{Name: "cors"}},
+			},
+		},
+	}
+}
+
+func expectCounts(t *testing.T, server *kongotest.Server, counts map[string]int) {
+	t.Helper()
+	for collection, count := range counts {
+		if server.Count(collection) != count {
+			t.Fatalf("Expected %d %s, got %d", count, collection, server.Count(collection))
+		}
+	}
+}
+
+func TestRegisterK8sServicePorts(t *testing.T) {
+	server := kongotest.NewServer()
+	defer server.Close()
+	kongo, err := NewKongo(&server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registered, err := kongo.RegisterK8sService(multiPortService())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectCounts(t, server, map[string]int{"upstreams": 2, "targets": 3, "services": 2, "routes": 3, "plugins": 1})
+	if len(registered.Services) != 2 || *registered.Services[1].Name != "kongo.multi.grpc.service" {
+		t.Fatalf("Expected the Services in the order of the ports, got: %s", String(*registered))
+	}
+	route, err := kongo.GetRoute("kongo.multi.api.route")
+	if err != nil || !*route.StripPath || *route.Service.ID != *registered.Services[0].ID {
+		t.Fatalf("Expected the named Route on the Service of its port: %v", err)
+	}
+
+	_, err = kongo.RegisterK8sService(multiPortService())
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Expected registering again to fail, got: %v", err)
+	}
+	expectCounts(t, server, map[string]int{"upstreams": 2, "services": 2, "routes": 3})
+}
+
+func TestRegisterK8sServicePortsRollsBack(t *testing.T) {
+	server := kongotest.NewServer()
+	defer server.Close()
+	kongo, err := NewKongo(&server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Kong rejects the Service of the second port, once the Upstreams and the first Service were created.
+	k8sService := multiPortService()
+	k8sService.Ports[1].Path = "relative"
+	_, err = kongo.RegisterK8sService(k8sService)
+	if err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("Expected the registration to fail and be rolled back, got: %v", err)
+	}
+	expectCounts(t, server, map[string]int{"upstreams": 0, "targets": 0, "services": 0, "routes": 0, "plugins": 0})
+}
+
+func TestDeregisterK8sServicePorts(t *testing.T) {
+	server := kongotest.NewServer()
+	defer server.Close()
+	kongo, err := NewKongo(&server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = kongo.RegisterK8sService(multiPortService())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &K8sService{Name: "kongo.other", Path: "/other", Port: 80}
+	_, err = kongo.RegisterK8sService(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = kongo.DeregisterK8sService("kongo.multi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectCounts(t, server, map[string]int{"upstreams": 1, "targets": 0, "services": 1, "routes": 1, "plugins": 0})
+	if _, err := kongo.GetService("kongo.other.service"); err != nil {
+		t.Fatalf("Expected the entities of other Services to be left: %v", err)
+	}
+
+	err = kongo.DeregisterK8sService("kongo.multi")
+	if err != nil {
+		t.Fatalf("Expected deregistering again to find nothing to delete: %v", err)
+	}
+}
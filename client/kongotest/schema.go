@@ -0,0 +1,335 @@
+package kongotest
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+type fieldType int
+
+const (
+	fieldString fieldType = iota
+	fieldInteger
+	fieldNumber
+	fieldBoolean
+	fieldStrings
+	fieldArray
+	fieldRecord
+	fieldForeign
+)
+
+// foreignKey is a field referring to an entity of another collection, e.g. the service of a route.
+type foreignKey struct {
+	collection string
+	// cascade deletes the referring entities along with the entity referred to, which otherwise cannot be deleted while
+	// referred to.
+	cascade bool
+}
+
+// schema describes a collection of the Admin API the way Kong's schemas do, to the extent kongo relies on.
+type schema struct {
+	name string
+	// key is the field that may be used in place of the ID in paths, e.g. `/services/{name}`.
+	key     string
+	fields  map[string]fieldType
+	foreign map[string]foreignKey
+	// unique lists the sets of fields whose values must be unique together.
+	unique [][]string
+	// uniqueElements lists the fields of sets whose elements must be unique across the collection.
+	uniqueElements []string
+	defaults       func() entity
+	// validate adds the errors of the fields, by field, the types of the fields having been checked.
+	validate func(entity entity, errors map[string]interface{})
+	// nested collections are only reachable through the entity they belong to, as targets are through their upstream.
+	nested    bool
+	updatedAt bool
+}
+
+var (
+	namePattern     = regexp.MustCompile(`^[0-9A-Za-z.\-_~]+$`)
+	hostnamePattern = regexp.MustCompile(`^[0-9A-Za-z_]([0-9A-Za-z\-_]*[0-9A-Za-z_])?(\.[0-9A-Za-z_]([0-9A-Za-z\-_]*[0-9A-Za-z_])?)*$`)
+)
+
+var schemas = []*schema{
+	{
+		name: "services",
+		key:  "name",
+		fields: map[string]fieldType{
+			"id": fieldString, "name": fieldString, "host": fieldString, "path": fieldString, "port": fieldInteger,
+			"protocol": fieldString, "retries": fieldInteger, "connect_timeout": fieldInteger,
+			"read_timeout": fieldInteger, "write_timeout": fieldInteger, "client_certificate": fieldForeign,
+			"created_at": fieldInteger, "updated_at": fieldInteger, "tags": fieldStrings,
+		},
+		foreign: map[string]foreignKey{"client_certificate": {collection: "certificates"}},
+		unique:  [][]string{{"name"}},
+		defaults: func() entity {
+			return entity{
+				"protocol": "http", "port": 80.0, "retries": 5.0,
+				"connect_timeout": 60000.0, "read_timeout": 60000.0, "write_timeout": 60000.0,
+			}
+		},
+		validate: func(service entity, errors map[string]interface{}) {
+			required(service, errors, "host")
+			validateName(service, errors)
+			oneOf(service, errors, "protocol", "grpc", "grpcs", "http", "https", "tcp", "tls", "udp")
+			between(service, errors, "port", 0, 65535)
+			between(service, errors, "retries", 0, 32767)
+			if path, ok := service["path"].(string); ok && !strings.HasPrefix(path, "/") {
+				errors["path"] = "should start with: /"
+			}
+		},
+		updatedAt: true,
+	},
+	{
+		name: "routes",
+		key:  "name",
+		fields: map[string]fieldType{
+			"id": fieldString, "name": fieldString, "hosts": fieldStrings, "paths": fieldStrings,
+			"methods": fieldStrings, "headers": fieldRecord, "protocols": fieldStrings, "snis": fieldStrings,
+			"sources": fieldArray, "destinations": fieldArray, "strip_path": fieldBoolean,
+			"preserve_host": fieldBoolean, "regex_priority": fieldInteger, "https_redirect_status_code": fieldInteger,
+			"service": fieldForeign, "created_at": fieldInteger, "updated_at": fieldInteger, "tags": fieldStrings,
+		},
+		foreign: map[string]foreignKey{"service": {collection: "services"}},
+		unique:  [][]string{{"name"}},
+		defaults: func() entity {
+			return entity{
+				"protocols": []interface{}{"http", "https"}, "strip_path": true, "preserve_host": false,
+				"regex_priority": 0.0, "https_redirect_status_code": 426.0,
+			}
+		},
+		validate: func(route entity, errors map[string]interface{}) {
+			validateName(route, errors)
+			for _, path := range stringsOf(route["paths"]) {
+				if !strings.HasPrefix(path, "/") {
+					errors["paths"] = "should start with: /"
+				}
+			}
+			protocols := stringsOf(route["protocols"])
+			for _, protocol := range protocols {
+				if protocol == "http" || protocol == "https" {
+					if isEmpty(route["methods"]) && isEmpty(route["hosts"]) && isEmpty(route["headers"]) && isEmpty(route["paths"]) {
+						errors["@entity"] = []interface{}{fmt.Sprintf("must set one of 'methods', 'hosts', 'headers', 'paths' when 'protocols' is '%s'", protocol)}
+					}
+					break
+				}
+			}
+		},
+		updatedAt: true,
+	},
+	{
+		name: "upstreams",
+		key:  "name",
+		fields: map[string]fieldType{
+			"id": fieldString, "name": fieldString, "algorithm": fieldString, "slots": fieldInteger,
+			"healthchecks": fieldRecord, "hash_on": fieldString, "hash_fallback": fieldString,
+			"hash_on_header": fieldString, "hash_fallback_header": fieldString, "hash_on_cookie": fieldString,
+			"hash_on_cookie_path": fieldString, "created_at": fieldInteger, "tags": fieldStrings,
+		},
+		unique: [][]string{{"name"}},
+		defaults: func() entity {
+			return entity{"algorithm": "round-robin", "slots": 10000.0, "hash_on": "none", "hash_fallback": "none", "hash_on_cookie_path": "/"}
+		},
+		validate: func(upstream entity, errors map[string]interface{}) {
+			required(upstream, errors, "name")
+			if name, ok := upstream["name"].(string); ok && (len(name) > 253 || !hostnamePattern.MatchString(name)) {
+				errors["name"] = "Invalid name; must be a valid hostname"
+			}
+			oneOf(upstream, errors, "algorithm", "consistent-hashing", "least-connections", "round-robin")
+			between(upstream, errors, "slots", 10, 65536)
+		},
+	},
+	{
+		name: "targets",
+		key:  "target",
+		fields: map[string]fieldType{
+			"id": fieldString, "target": fieldString, "weight": fieldInteger, "upstream": fieldForeign,
+			"created_at": fieldNumber, "tags": fieldStrings,
+		},
+		foreign: map[string]foreignKey{"upstream": {collection: "upstreams", cascade: true}},
+		unique:  [][]string{{"upstream", "target"}},
+		defaults: func() entity {
+			return entity{"weight": 100.0}
+		},
+		validate: func(target entity, errors map[string]interface{}) {
+			required(target, errors, "target")
+			between(target, errors, "weight", 0, 65535)
+			if value, ok := target["target"].(string); ok {
+				normalized, valid := normalizeTarget(value)
+				if !valid {
+					errors["target"] = "Invalid target; not a valid hostname or ip address"
+				}
+				target["target"] = normalized
+			}
+		},
+		nested: true,
+	},
+	{
+		name: "consumers",
+		key:  "username",
+		fields: map[string]fieldType{
+			"id": fieldString, "username": fieldString, "custom_id": fieldString, "created_at": fieldInteger,
+			"tags": fieldStrings,
+		},
+		unique: [][]string{{"username"}, {"custom_id"}},
+		validate: func(consumer entity, errors map[string]interface{}) {
+			if isEmpty(consumer["username"]) && isEmpty(consumer["custom_id"]) {
+				errors["@entity"] = []interface{}{"at least one of these fields must be non-empty: 'custom_id', 'username'"}
+			}
+		},
+	},
+	{
+		name: "plugins",
+		fields: map[string]fieldType{
+			"id": fieldString, "name": fieldString, "config": fieldRecord, "enabled": fieldBoolean,
+			"protocols": fieldStrings, "run_on": fieldString, "route": fieldForeign, "service": fieldForeign,
+			"consumer": fieldForeign, "created_at": fieldInteger, "tags": fieldStrings,
+		},
+		foreign: map[string]foreignKey{
+			"route":    {collection: "routes", cascade: true},
+			"service":  {collection: "services", cascade: true},
+			"consumer": {collection: "consumers", cascade: true},
+		},
+		unique: [][]string{{"name", "route", "service", "consumer"}},
+		defaults: func() entity {
+			return entity{"enabled": true, "config": map[string]interface{}{}, "protocols": []interface{}{"grpc", "grpcs", "http", "https"}}
+		},
+		validate: func(plugin entity, errors map[string]interface{}) {
+			required(plugin, errors, "name")
+		},
+	},
+	{
+		name: "certificates",
+		fields: map[string]fieldType{
+			"id": fieldString, "cert": fieldString, "key": fieldString, "snis": fieldStrings,
+			"created_at": fieldInteger, "tags": fieldStrings,
+		},
+		uniqueElements: []string{"snis"},
+		validate: func(certificate entity, errors map[string]interface{}) {
+			required(certificate, errors, "cert")
+			required(certificate, errors, "key")
+		},
+	},
+}
+
+// checkTypes reports unknown fields and fields of the wrong type, nulls standing for unset fields.
+func (schema *schema) checkTypes(entity entity, errors map[string]interface{}) {
+	for field, value := range entity {
+		fieldType, known := schema.fields[field]
+		if !known {
+			errors[field] = "unknown field"
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		switch fieldType {
+		case fieldString:
+			if _, ok := value.(string); !ok {
+				errors[field] = "expected a string"
+			}
+		case fieldInteger:
+			if number, ok := value.(float64); !ok || number != float64(int64(number)) {
+				errors[field] = "expected an integer"
+			}
+		case fieldNumber:
+			if _, ok := value.(float64); !ok {
+				errors[field] = "expected a number"
+			}
+		case fieldBoolean:
+			if _, ok := value.(bool); !ok {
+				errors[field] = "expected a boolean"
+			}
+		case fieldStrings:
+			elements, ok := value.([]interface{})
+			if !ok {
+				errors[field] = "expected a set"
+				continue
+			}
+			for _, element := range elements {
+				if _, ok := element.(string); !ok {
+					errors[field] = "expected a string"
+				}
+			}
+		case fieldArray:
+			if _, ok := value.([]interface{}); !ok {
+				errors[field] = "expected an array"
+			}
+		case fieldRecord, fieldForeign:
+			if _, ok := value.(map[string]interface{}); !ok {
+				errors[field] = "expected a record"
+			}
+		}
+	}
+}
+
+func required(entity entity, errors map[string]interface{}, field string) {
+	if isEmpty(entity[field]) {
+		errors[field] = "required field missing"
+	}
+}
+
+func validateName(entity entity, errors map[string]interface{}) {
+	if name, ok := entity["name"].(string); ok && !namePattern.MatchString(name) {
+		errors["name"] = fmt.Sprintf("invalid value '%s': it must only contain alphanumeric and '., -, _, ~' characters", name)
+	}
+}
+
+func oneOf(entity entity, errors map[string]interface{}, field string, values ...string) {
+	value, ok := entity[field].(string)
+	if !ok {
+		return
+	}
+	for _, allowed := range values {
+		if value == allowed {
+			return
+		}
+	}
+	errors[field] = "expected one of: " + strings.Join(values, ", ")
+}
+
+func between(entity entity, errors map[string]interface{}, field string, min float64, max float64) {
+	if value, ok := entity[field].(float64); ok && (value < min || value > max) {
+		errors[field] = fmt.Sprintf("value should be between %d and %d", int(min), int(max))
+	}
+}
+
+func isEmpty(value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case []interface{}:
+		return len(value) == 0
+	case map[string]interface{}:
+		return len(value) == 0
+	}
+	return false
+}
+
+// stringsOf gives the elements of a set of strings.
+func stringsOf(value interface{}) []string {
+	elements, _ := value.([]interface{})
+	values := []string{}
+	for _, element := range elements {
+		if text, ok := element.(string); ok {
+			values = append(values, text)
+		}
+	}
+	return values
+}
+
+// normalizeTarget adds Kong's default port of 8000 to targets without one.
+func normalizeTarget(target string) (string, bool) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		host, port = strings.Trim(target, "[]"), "8000"
+	}
+	if host == "" || (net.ParseIP(host) == nil && !hostnamePattern.MatchString(host)) {
+		return target, false
+	}
+	return net.JoinHostPort(host, port), true
+}
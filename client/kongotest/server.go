@@ -0,0 +1,570 @@
+// Package kongotest provides an in-memory stand-in for the Kong Admin API, for tests that would otherwise need a Kong.
+package kongotest
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	Version         = "2.8.1"
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// Kong's error codes, as found in the bodies of its errors.
+const (
+	codeSchemaViolation   = 2
+	codeForeignKey        = 4
+	codeUniqueViolation   = 5
+	codeInvalidOffset     = 7
+	codeInvalidSize       = 9
+	codeInvalidOptions    = 11
+	codePrimaryKeyInvalid = 1
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+type entity map[string]interface{}
+
+type collection struct {
+	schema *schema
+	// entities are kept in the order they were created, which is the order they are listed in.
+	entities []entity
+}
+
+// Server serves services, routes, upstreams and their targets, consumers, plugins and certificates from memory. It
+// validates entities against simplified versions of Kong's schemas, enforces uniqueness and foreign keys, paginates
+// and filters lists by tags, and answers errors with bodies shaped like Kong's.
+type Server struct {
+	*httptest.Server
+	mutex       sync.Mutex
+	collections map[string]*collection
+}
+
+// NewServer starts a Server, to be closed when done.
+func NewServer() *Server {
+	server := &Server{collections: make(map[string]*collection)}
+	server.Reset()
+	server.Server = httptest.NewServer(server)
+	return server
+}
+
+// Reset deletes every entity.
+func (server *Server) Reset() {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	for _, schema := range schemas {
+		server.collections[schema.name] = &collection{schema: schema}
+	}
+}
+
+// Count gives the number of entities of a collection, e.g. `services`.
+func (server *Server) Count(name string) int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return len(server.collections[name].entities)
+}
+
+// apiError is an error answered with Kong's error body.
+type apiError struct {
+	status int
+	body   map[string]interface{}
+}
+
+func notFound() *apiError {
+	return &apiError{http.StatusNotFound, map[string]interface{}{"message": "Not found"}}
+}
+
+func badRequest(message string) *apiError {
+	return &apiError{http.StatusBadRequest, map[string]interface{}{"message": message}}
+}
+
+func schemaViolation(errors map[string]interface{}) *apiError {
+	descriptions := []string{}
+	for field, description := range errors {
+		if messages, ok := description.([]interface{}); ok && len(messages) > 0 {
+			description = messages[0]
+		}
+		descriptions = append(descriptions, fmt.Sprintf("%s: %v", field, description))
+	}
+	sort.Strings(descriptions)
+
+	message := fmt.Sprintf("schema violation (%s)", strings.Join(descriptions, "; "))
+	if len(descriptions) > 1 {
+		message = fmt.Sprintf("%d schema violations (%s)", len(descriptions), strings.Join(descriptions, "; "))
+	}
+	return &apiError{http.StatusBadRequest, map[string]interface{}{
+		"code":    codeSchemaViolation,
+		"name":    "schema violation",
+		"message": message,
+		"fields":  errors,
+	}}
+}
+
+func (server *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	status, body, err := server.handle(request)
+	if err != nil {
+		status, body = err.status, err.body
+	}
+
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writer.Header().Set("Server", "kong/"+Version)
+	writer.WriteHeader(status)
+	if body != nil {
+		json.NewEncoder(writer).Encode(body)
+	}
+}
+
+func (server *Server) handle(request *http.Request) (int, interface{}, *apiError) {
+	segments := strings.Split(strings.Trim(request.URL.Path, "/"), "/")
+	if len(segments) == 1 && segments[0] == "" {
+		if request.Method != http.MethodGet {
+			return methodNotAllowed()
+		}
+		return http.StatusOK, map[string]interface{}{"version": Version, "tagline": "Welcome to kong"}, nil
+	}
+
+	collection, found := server.collections[segments[0]]
+	if !found || len(segments) > 4 {
+		return 0, nil, notFound()
+	}
+
+	// Nested paths, e.g. /upstreams/{upstream}/targets/{target}, scope the collection to the entity they start with.
+	var parentField string
+	var parent entity
+	if len(segments) >= 3 {
+		parent = server.find(collection, segments[1], "", nil)
+		if parent == nil {
+			return 0, nil, notFound()
+		}
+		child, found := server.collections[segments[2]]
+		if !found {
+			return 0, nil, notFound()
+		}
+		for field, foreignKey := range child.schema.foreign {
+			if foreignKey.collection == collection.schema.name {
+				parentField = field
+			}
+		}
+		if parentField == "" {
+			return 0, nil, notFound()
+		}
+		collection, segments = child, segments[2:]
+	} else if collection.schema.nested {
+		return 0, nil, notFound()
+	}
+
+	if len(segments) == 1 {
+		switch request.Method {
+		case http.MethodGet:
+			return server.list(collection, request, parentField, parent)
+		case http.MethodPost:
+			return server.create(collection, request, parentField, parent)
+		}
+		return methodNotAllowed()
+	}
+
+	existing := server.find(collection, segments[1], parentField, parent)
+	switch request.Method {
+	case http.MethodGet:
+		if existing == nil {
+			return 0, nil, notFound()
+		}
+		return http.StatusOK, existing, nil
+	case http.MethodPatch:
+		if existing == nil {
+			return 0, nil, notFound()
+		}
+		return server.update(collection, request, existing)
+	case http.MethodDelete:
+		if existing == nil {
+			// Kong answers the deletion of a missing entity as a success.
+			return http.StatusNoContent, nil, nil
+		}
+		return server.delete(collection, existing)
+	}
+	return methodNotAllowed()
+}
+
+func methodNotAllowed() (int, interface{}, *apiError) {
+	return 0, nil, &apiError{http.StatusMethodNotAllowed, map[string]interface{}{"message": "Method not allowed"}}
+}
+
+// find looks an entity up by ID, or else by the key of the collection, within the entities referring to the parent
+// when given.
+func (server *Server) find(collection *collection, idOrKey string, parentField string, parent entity) entity {
+	field := "id"
+	if !uuidPattern.MatchString(idOrKey) {
+		if collection.schema.key == "" {
+			return nil
+		}
+		field = collection.schema.key
+	}
+	for _, existing := range collection.entities {
+		if existing[field] != idOrKey {
+			continue
+		}
+		if parent != nil && foreignID(existing[parentField]) != parent["id"] {
+			continue
+		}
+		return existing
+	}
+	return nil
+}
+
+func (server *Server) list(collection *collection, request *http.Request, parentField string, parent entity) (int, interface{}, *apiError) {
+	query := request.URL.Query()
+
+	size := defaultPageSize
+	if value := query.Get("size"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxPageSize {
+			return 0, nil, &apiError{http.StatusBadRequest, map[string]interface{}{
+				"code":    codeInvalidSize,
+				"name":    "invalid size",
+				"message": fmt.Sprintf("size must be an integer between 1 and %d", maxPageSize),
+			}}
+		}
+		size = parsed
+	}
+
+	start := 0
+	if value := query.Get("offset"); value != "" {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err == nil {
+			start, err = strconv.Atoi(string(decoded))
+		}
+		if err != nil || start < 0 {
+			return 0, nil, &apiError{http.StatusBadRequest, map[string]interface{}{
+				"code":    codeInvalidOffset,
+				"name":    "invalid offset",
+				"message": fmt.Sprintf("'%s' is not a valid offset: bad base64 encoding", value),
+			}}
+		}
+	}
+
+	matchesTags, err := tagFilter(query.Get("tags"))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	matching := []entity{}
+	for _, existing := range collection.entities {
+		if parent != nil && foreignID(existing[parentField]) != parent["id"] {
+			continue
+		}
+		if matchesTags(existing) {
+			matching = append(matching, existing)
+		}
+	}
+
+	page := map[string]interface{}{"data": []entity{}, "next": nil}
+	if start < len(matching) {
+		end := start + size
+		if end > len(matching) {
+			end = len(matching)
+		}
+		page["data"] = matching[start:end]
+		if end < len(matching) {
+			offset := base64.StdEncoding.EncodeToString([]byte(strconv.Itoa(end)))
+			page["offset"] = offset
+			page["next"] = request.URL.Path + "?offset=" + offset
+		}
+	}
+	return http.StatusOK, page, nil
+}
+
+// tagFilter parses Kong's tag filters, tags separated by `,` all having to be present and tags separated by `/` any.
+func tagFilter(tags string) (func(entity entity) bool, *apiError) {
+	if tags == "" {
+		return func(entity) bool { return true }, nil
+	}
+	if strings.Contains(tags, ",") && strings.Contains(tags, "/") {
+		return nil, &apiError{http.StatusBadRequest, map[string]interface{}{
+			"code":    codeInvalidOptions,
+			"name":    "invalid options",
+			"message": "invalid option (tags: invalid filter syntax)",
+			"fields":  map[string]interface{}{"tags": "invalid filter syntax"},
+		}}
+	}
+
+	all := !strings.Contains(tags, "/")
+	wanted := strings.FieldsFunc(tags, func(r rune) bool { return r == ',' || r == '/' })
+	return func(entity entity) bool {
+		present := make(map[string]bool)
+		for _, tag := range stringsOf(entity["tags"]) {
+			present[tag] = true
+		}
+		for _, tag := range wanted {
+			if present[tag] && !all {
+				return true
+			}
+			if !present[tag] && all {
+				return false
+			}
+		}
+		return all
+	}, nil
+}
+
+func (server *Server) create(collection *collection, request *http.Request, parentField string, parent entity) (int, interface{}, *apiError) {
+	created, err := decodeEntity(request)
+	if err != nil {
+		return 0, nil, err
+	}
+	if parent != nil {
+		created[parentField] = map[string]interface{}{"id": parent["id"]}
+	}
+
+	if id, found := created["id"]; found && id != nil {
+		text, ok := id.(string)
+		if !ok || !uuidPattern.MatchString(text) {
+			return 0, nil, &apiError{http.StatusBadRequest, map[string]interface{}{
+				"code":    codePrimaryKeyInvalid,
+				"name":    "invalid primary key",
+				"message": fmt.Sprintf("invalid primary key: '{id=\"%v\"}'", id),
+			}}
+		}
+	} else {
+		created["id"] = newID()
+	}
+
+	if collection.schema.defaults != nil {
+		for field, value := range collection.schema.defaults() {
+			if _, found := created[field]; !found {
+				created[field] = value
+			}
+		}
+	}
+	if created["created_at"] == nil {
+		created["created_at"] = float64(time.Now().Unix())
+	}
+	if collection.schema.updatedAt && created["updated_at"] == nil {
+		created["updated_at"] = created["created_at"]
+	}
+
+	err = server.check(collection, created, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	collection.entities = append(collection.entities, created)
+	return http.StatusCreated, created, nil
+}
+
+// update applies the fields of the body over those of the entity, nulls unsetting fields.
+func (server *Server) update(collection *collection, request *http.Request, existing entity) (int, interface{}, *apiError) {
+	changes, err := decodeEntity(request)
+	if err != nil {
+		return 0, nil, err
+	}
+	if id, found := changes["id"]; found && id != existing["id"] {
+		return 0, nil, schemaViolation(map[string]interface{}{"id": "cannot be changed"})
+	}
+
+	updated := entity{}
+	for field, value := range existing {
+		updated[field] = value
+	}
+	for field, value := range changes {
+		updated[field] = value
+	}
+	if collection.schema.updatedAt {
+		updated["updated_at"] = float64(time.Now().Unix())
+	}
+
+	err = server.check(collection, updated, existing)
+	if err != nil {
+		return 0, nil, err
+	}
+	for i, candidate := range collection.entities {
+		if candidate["id"] == existing["id"] {
+			collection.entities[i] = updated
+		}
+	}
+	return http.StatusOK, updated, nil
+}
+
+// delete cascades to the entities referring to the entity when their schema says so, and is refused otherwise.
+func (server *Server) delete(collection *collection, existing entity) (int, interface{}, *apiError) {
+	for _, other := range server.collections {
+		for field, foreignKey := range other.schema.foreign {
+			if foreignKey.collection != collection.schema.name || foreignKey.cascade {
+				continue
+			}
+			for _, referring := range other.entities {
+				if foreignID(referring[field]) == existing["id"] {
+					return 0, nil, &apiError{http.StatusBadRequest, map[string]interface{}{
+						"code":    codeForeignKey,
+						"name":    "foreign key violation",
+						"message": fmt.Sprintf("an existing '%s' entity references this '%s' entity", other.schema.name, collection.schema.name),
+						"fields":  map[string]interface{}{"@referenced_by": other.schema.name},
+					}}
+				}
+			}
+		}
+	}
+
+	server.remove(collection, existing["id"])
+	return http.StatusNoContent, nil, nil
+}
+
+func (server *Server) remove(collection *collection, id interface{}) {
+	kept := []entity{}
+	for _, candidate := range collection.entities {
+		if candidate["id"] != id {
+			kept = append(kept, candidate)
+		}
+	}
+	collection.entities = kept
+
+	for _, other := range server.collections {
+		for field, foreignKey := range other.schema.foreign {
+			if foreignKey.collection != collection.schema.name || !foreignKey.cascade {
+				continue
+			}
+			for _, referring := range other.entities {
+				if foreignID(referring[field]) == id {
+					server.remove(other, referring["id"])
+				}
+			}
+		}
+	}
+}
+
+// check validates the entity, resolves its foreign keys to IDs and enforces uniqueness against the other entities of
+// the collection, the entity replacing the previous version given.
+func (server *Server) check(collection *collection, candidate entity, previous entity) *apiError {
+	errors := make(map[string]interface{})
+	collection.schema.checkTypes(candidate, errors)
+	if len(errors) == 0 && collection.schema.validate != nil {
+		collection.schema.validate(candidate, errors)
+	}
+	if len(errors) > 0 {
+		return schemaViolation(errors)
+	}
+
+	for field, foreignKey := range collection.schema.foreign {
+		reference, ok := candidate[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		referenced := server.collections[foreignKey.collection]
+		var target entity
+		if id, ok := reference["id"].(string); ok {
+			target = server.find(referenced, id, "", nil)
+		} else if key, ok := reference[referenced.schema.key].(string); ok && referenced.schema.key != "" {
+			target = server.find(referenced, key, "", nil)
+		}
+		if target == nil {
+			encoded, _ := json.Marshal(reference)
+			return &apiError{http.StatusBadRequest, map[string]interface{}{
+				"code":    codeForeignKey,
+				"name":    "foreign key violation",
+				"message": fmt.Sprintf("the foreign key '%s' does not reference an existing '%s' entity.", encoded, foreignKey.collection),
+				"fields":  map[string]interface{}{field: reference},
+			}}
+		}
+		candidate[field] = map[string]interface{}{"id": target["id"]}
+	}
+
+	for _, existing := range collection.entities {
+		if existing["id"] == candidate["id"] && previous == nil {
+			return uniqueViolation(map[string]interface{}{"id": candidate["id"]})
+		}
+		if previous != nil && existing["id"] == previous["id"] {
+			continue
+		}
+		for _, fields := range collection.schema.unique {
+			if sameValues(existing, candidate, fields) {
+				values := make(map[string]interface{})
+				for _, field := range fields {
+					values[field] = candidate[field]
+				}
+				return uniqueViolation(values)
+			}
+		}
+		for _, field := range collection.schema.uniqueElements {
+			for _, element := range stringsOf(candidate[field]) {
+				for _, other := range stringsOf(existing[field]) {
+					if element == other {
+						return uniqueViolation(map[string]interface{}{field: element})
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sameValues compares the fields of both entities, a unique set being violated only when one of its fields is set.
+func sameValues(a entity, b entity, fields []string) bool {
+	set := false
+	for _, field := range fields {
+		if normalized(a[field]) != normalized(b[field]) {
+			return false
+		}
+		set = set || b[field] != nil
+	}
+	return set
+}
+
+func normalized(value interface{}) string {
+	if reference, ok := value.(map[string]interface{}); ok {
+		value = reference["id"]
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+func uniqueViolation(values map[string]interface{}) *apiError {
+	descriptions := []string{}
+	for field, value := range values {
+		encoded, _ := json.Marshal(value)
+		descriptions = append(descriptions, fmt.Sprintf("%s=%s", field, encoded))
+	}
+	sort.Strings(descriptions)
+	return &apiError{http.StatusConflict, map[string]interface{}{
+		"code":    codeUniqueViolation,
+		"name":    "unique constraint violation",
+		"message": fmt.Sprintf("UNIQUE violation detected on '{%s}'", strings.Join(descriptions, ",")),
+		"fields":  values,
+	}}
+}
+
+func decodeEntity(request *http.Request) (entity, *apiError) {
+	decoded := entity{}
+	err := json.NewDecoder(request.Body).Decode(&decoded)
+	if err != nil {
+		return nil, badRequest("Cannot parse JSON body")
+	}
+	return decoded, nil
+}
+
+func foreignID(reference interface{}) interface{} {
+	if reference, ok := reference.(map[string]interface{}); ok {
+		return reference["id"]
+	}
+	return nil
+}
+
+func newID() string {
+	var bytes [16]byte
+	rand.Read(bytes[:])
+	bytes[6] = (bytes[6] & 0x0f) | 0x40
+	bytes[8] = (bytes[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", bytes[0:4], bytes[4:6], bytes[6:8], bytes[8:10], bytes[10:16])
+}
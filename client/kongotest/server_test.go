@@ -0,0 +1,204 @@
+package kongotest
+
+import (
+	"context"
+	"fmt"
+	"github.com/hbagdi/go-kong/kong"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newTestClient(t *testing.T) (*Server, *kong.Client) {
+	server := NewServer()
+	t.Cleanup(server.Close)
+	client, err := kong.NewClient(kong.String(server.URL), &http.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, client
+}
+
+func expectStatus(t *testing.T, err error, status int) {
+	t.Helper()
+	apiError, ok := err.(*kong.APIError)
+	if !ok {
+		t.Fatalf("Expected an error with status %d, got: %v", status, err)
+	}
+	if apiError.Code() != status {
+		t.Fatalf("Expected status %d, got: %v", status, err)
+	}
+}
+
+func TestServerUniqueness(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := client.Services.Create(ctx, &kong.Service{Name: kong.String("orders"), Host: kong.String("orders.local")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Services.Create(ctx, &kong.Service{Name: kong.String("orders"), Host: kong.String("other.local")})
+	expectStatus(t, err, http.StatusConflict)
+
+	upstream, err := client.Upstreams.Create(ctx, &kong.Upstream{Name: kong.String("orders.upstream")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := client.Targets.Create(ctx, upstream.Name, &kong.Target{Target: kong.String("10.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *target.Target != "10.0.0.1:8000" || *target.Weight != 100 {
+		t.Fatalf("Expected the target to be defaulted, got: %s weighing %d", *target.Target, *target.Weight)
+	}
+	_, err = client.Targets.Create(ctx, upstream.ID, &kong.Target{Target: kong.String("10.0.0.1:8000")})
+	expectStatus(t, err, http.StatusConflict)
+}
+
+func TestServerValidation(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := context.Background()
+
+	invalid := []*kong.Service{
+		{Name: kong.String("no-host")},
+		{Name: kong.String("bad name"), Host: kong.String("orders.local")},
+		{Host: kong.String("orders.local"), Path: kong.String("relative")},
+		{Host: kong.String("orders.local"), Port: kong.Int(70000)},
+	}
+	for _, service := range invalid {
+		_, err := client.Services.Create(ctx, service)
+		expectStatus(t, err, http.StatusBadRequest)
+	}
+
+	_, err := client.Routes.Create(ctx, &kong.Route{Service: &kong.Service{Name: kong.String("missing")}, Paths: kong.StringSlice("/")})
+	expectStatus(t, err, http.StatusBadRequest)
+
+	_, err = client.Upstreams.Create(ctx, &kong.Upstream{Name: kong.String("orders"), Algorithm: kong.String("random")})
+	expectStatus(t, err, http.StatusBadRequest)
+}
+
+func TestServerPagination(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		_, err := client.Upstreams.Create(ctx, &kong.Upstream{Name: kong.String(fmt.Sprintf("upstream-%d", i))})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := []string{}
+	options := &kong.ListOpt{Size: 2}
+	for pages := 0; options != nil; pages++ {
+		if pages > 3 {
+			t.Fatalf("Expected 3 pages")
+		}
+		upstreams, next, err := client.Upstreams.List(ctx, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, upstream := range upstreams {
+			names = append(names, *upstream.Name)
+		}
+		options = next
+	}
+	if strings.Join(names, ",") != "upstream-0,upstream-1,upstream-2,upstream-3,upstream-4" {
+		t.Fatalf("Expected the upstreams in creation order, got: %v", names)
+	}
+
+	_, _, err := client.Upstreams.List(ctx, &kong.ListOpt{Size: 1001})
+	expectStatus(t, err, http.StatusBadRequest)
+	_, _, err = client.Upstreams.List(ctx, &kong.ListOpt{Offset: "not an offset"})
+	expectStatus(t, err, http.StatusBadRequest)
+}
+
+func TestServerTags(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := context.Background()
+
+	tags := [][]string{{"a", "b"}, {"a"}, {"b"}, {}}
+	for i, tags := range tags {
+		_, err := client.Consumers.Create(ctx, &kong.Consumer{Username: kong.String(fmt.Sprintf("consumer-%d", i)), Tags: kong.StringSlice(tags...)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		tags     []string
+		matchAll bool
+		expected int
+	}{
+		{[]string{"a"}, true, 2},
+		{[]string{"a", "b"}, true, 1},
+		{[]string{"a", "b"}, false, 3},
+		{[]string{"c"}, false, 0},
+	}
+	for _, test := range tests {
+		consumers, _, err := client.Consumers.List(ctx, &kong.ListOpt{Tags: kong.StringSlice(test.tags...), MatchAllTags: test.matchAll})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(consumers) != test.expected {
+			t.Fatalf("Expected %d consumers tagged %v (all: %v), got: %d", test.expected, test.tags, test.matchAll, len(consumers))
+		}
+	}
+}
+
+func TestServerDeletion(t *testing.T) {
+	server, client := newTestClient(t)
+	ctx := context.Background()
+
+	service, err := client.Services.Create(ctx, &kong.Service{Name: kong.String("orders"), Host: kong.String("orders.local")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	route, err := client.Routes.Create(ctx, &kong.Route{Service: &kong.Service{Name: service.Name}, Paths: kong.StringSlice("/orders")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Plugins.Create(ctx, &kong.Plugin{Name: kong.String("cors"), Route: &kong.Route{ID: route.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Plugins.Create(ctx, &kong.Plugin{Name: kong.String("cors"), Route: &kong.Route{ID: route.ID}})
+	expectStatus(t, err, http.StatusConflict)
+
+	err = client.Services.Delete(ctx, service.ID)
+	expectStatus(t, err, http.StatusBadRequest)
+
+	err = client.Routes.Delete(ctx, route.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.Count("plugins") != 0 {
+		t.Fatalf("Expected the plugins of the route to be deleted along with it")
+	}
+	err = client.Services.Delete(ctx, service.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upstream, err := client.Upstreams.Create(ctx, &kong.Upstream{Name: kong.String("orders.upstream")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Targets.Create(ctx, upstream.ID, &kong.Target{Target: kong.String("10.0.0.1:80")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Upstreams.Delete(ctx, upstream.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.Count("targets") != 0 {
+		t.Fatalf("Expected the targets of the upstream to be deleted along with it")
+	}
+
+	_, err = client.Services.Get(ctx, service.ID)
+	if !kong.IsNotFoundErr(err) {
+		t.Fatalf("Expected the service to be gone, got: %v", err)
+	}
+}
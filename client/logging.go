@@ -0,0 +1,31 @@
+package client
+
+// Logger is the part of *slog.Logger kongo logs with, key-value pairs following each message. A Kongo logs nothing
+// unless given one with WithLogger.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+func WithLogger(logger Logger) Option {
+	return func(settings *kongoSettings) error {
+		settings.logger = logger
+		return nil
+	}
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Debug(msg string, args ...interface{}) {}
+func (discardLogger) Info(msg string, args ...interface{})  {}
+func (discardLogger) Warn(msg string, args ...interface{})  {}
+func (discardLogger) Error(msg string, args ...interface{}) {}
+
+func (kongo *Kongo) log() Logger {
+	if kongo.logger == nil {
+		return discardLogger{}
+	}
+	return kongo.logger
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"bytes"
+	"github.com/ciroque/kongo/client/kongotest"
+	"github.com/hbagdi/go-kong/kong"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogging(t *testing.T) {
+	server := kongotest.NewServer()
+	defer server.Close()
+
+	service := &K8sService{
+		Addresses: []*string{kong.String("10.0.0.1")},
+		Name:      "kongo.logged",
+		Path:      "/logged",
+		Port:      80,
+	}
+
+	t.Run("silent by default", func(t *testing.T) {
+		var output bytes.Buffer
+		defaultLogger := slog.Default()
+		slog.SetDefault(slog.New(slog.NewTextHandler(&output, &slog.HandlerOptions{Level: slog.LevelDebug})))
+		defer slog.SetDefault(defaultLogger)
+
+		kongo, err := NewKongo(&server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = kongo.RegisterK8sService(service)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output.Len() > 0 {
+			t.Fatalf("Expected a Kongo without a logger to log nothing, got: %s", output.String())
+		}
+	})
+
+	t.Run("through the logger", func(t *testing.T) {
+		var output bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&output, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+		kongo, err := NewKongo(&server.URL, WithLogger(logger))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = kongo.DeregisterK8sService(service.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		logs := output.String()
+		if !strings.Contains(logs, `"msg":"deregistering Kubernetes Service"`) || !strings.Contains(logs, `"service":"kongo.logged"`) {
+			t.Fatalf("Expected the deregistration to be logged, got: %s", logs)
+		}
+		if strings.Contains(logs, "Kong Admin API request") {
+			t.Fatalf("Expected the requests, logged at debug, to be left out at info, got: %s", logs)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	retriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kongo_admin_retries_total",
+		Help: "Requests to the Kong Admin API retried, by method and reason, connection or the status code.",
+	}, []string{"method", "reason"})
+
+	adminRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kongo_admin_requests_total",
+		Help: "Requests to the Kong Admin API, by method, entity kind and status code, error for connection errors.",
+	}, []string{"method", "kind", "code"})
+
+	adminRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kongo_admin_request_duration_seconds",
+		Help:    "Latency of the requests to the Kong Admin API, by method, entity kind and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "kind", "code"})
+
+	registrationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kongo_registrations_total",
+		Help: "Registrations and syncs of Kubernetes Services with Kong, by result.",
+	}, []string{"result"})
+
+	deregistrationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kongo_deregistrations_total",
+		Help: "Deregistrations of Kubernetes Services from Kong, by result.",
+	}, []string{"result"})
+
+	driftDetectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kongo_drift_detected_total",
+		Help: "Kong entities a sync found differing from the wanted state, and created, changed or deleted, by kind.",
+	}, []string{"kind"})
+)
+
+// RegisterMetrics registers the metrics of the requests to Kong and of the registrations with the registerer, for
+// programs using kongo as a library to expose them as they see fit.
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{retriesTotal, adminRequestsTotal, adminRequestDuration, registrationsTotal, deregistrationsTotal, driftDetectedTotal} {
+		err := registerer.Register(collector)
+		if err != nil {
+			return fmt.Errorf("error registering the kongo metrics: %v", err)
+		}
+	}
+	return nil
+}
+
+// adminKinds are the collections of the Admin API requests are labelled with.
+var adminKinds = map[string]bool{
+	"services": true, "routes": true, "upstreams": true, "targets": true, "consumers": true, "plugins": true,
+	"certificates": true, "snis": true,
+}
+
+// adminKind is the last collection of the path of a request, e.g. `targets` for `/upstreams/{upstream}/targets`, `root`
+// for the root of the Admin API and `other` for anything else.
+func adminKind(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if adminKinds[segments[i]] {
+			return segments[i]
+		}
+	}
+	if len(segments) == 1 && segments[0] == "" {
+		return "root"
+	}
+	return "other"
+}
+
+func observeAdminRequest(req *http.Request, response *http.Response, err error, start time.Time) {
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(response.StatusCode)
+	}
+	kind := adminKind(req.URL.Path)
+	adminRequestsTotal.WithLabelValues(req.Method, kind, code).Inc()
+	adminRequestDuration.WithLabelValues(req.Method, kind, code).Observe(time.Since(start).Seconds())
+}
+
+func resultLabel(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// recordDrift counts an entity a sync created, when existing is nil, or changed. Timestamps are not compared.
+func recordDrift(kind string, existing interface{}, updated interface{}) {
+	if existing == nil || reflect.ValueOf(existing).IsNil() || !sameFields(existing, updated) {
+		driftDetectedTotal.WithLabelValues(kind).Inc()
+	}
+}
+
+func sameFields(a interface{}, b interface{}) bool {
+	fields := [2]map[string]interface{}{}
+	for i, entity := range []interface{}{a, b} {
+		content, err := jsoniter.Marshal(entity)
+		if err != nil || jsoniter.Unmarshal(content, &fields[i]) != nil {
+			return false
+		}
+		delete(fields[i], "created_at")
+		delete(fields[i], "updated_at")
+	}
+	return reflect.DeepEqual(fields[0], fields[1])
+}
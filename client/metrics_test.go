@@ -0,0 +1,67 @@
+package client
+
+import (
+	"github.com/hbagdi/go-kong/kong"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"testing"
+)
+
+func TestAdminKind(t *testing.T) {
+	tests := map[string]string{
+		"/":                                "root",
+		"/services":                        "services",
+		"/services/kongo.orders.service":   "services",
+		"/upstreams/orders/targets":        "targets",
+		"/upstreams/orders/targets/10.0.0": "targets",
+		"/kong-admin/routes/r-1/plugins":   "plugins",
+		"/status":                          "other",
+	}
+	for path, expected := range tests {
+		if kind := adminKind(path); kind != expected {
+			t.Errorf("Expected '%s' to be labelled %s, got: %s", path, expected, kind)
+		}
+	}
+}
+
+func TestDriftMetrics(t *testing.T) {
+	kongo := newTestKongo(t)
+	drift := func() float64 {
+		return testutil.ToFloat64(driftDetectedTotal.WithLabelValues(KindService))
+	}
+	service := &kong.Service{Name: kong.String("kongo-test-drift"), Host: kong.String("orders.local"), Port: kong.Int(80)}
+
+	before := drift()
+	_, err := kongo.upsertService(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = kongo.upsertService(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drift()-before != 1 {
+		t.Fatalf("Expected the creation to be the only drift, got: %v", drift()-before)
+	}
+
+	service.Port = kong.Int(8080)
+	_, err = kongo.upsertService(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drift()-before != 2 {
+		t.Fatalf("Expected the change of port to be drift, got: %v", drift()-before)
+	}
+	kongo.DeleteService("kongo-test-drift")
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	err := RegisterMetrics(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if RegisterMetrics(registry) == nil {
+		t.Fatalf("Expected registering the metrics twice with a registry to fail")
+	}
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FanOutPolicy decides whether an operation applied to several Kong clusters succeeded.
+type FanOutPolicy string
+
+const (
+	// FanOutAll requires every cluster to succeed.
+	FanOutAll FanOutPolicy = "all"
+	// FanOutBestEffort requires a single cluster to succeed.
+	FanOutBestEffort FanOutPolicy = "best-effort"
+	// FanOutQuorum requires more than half of the clusters to succeed.
+	FanOutQuorum FanOutPolicy = "quorum"
+)
+
+func ParseFanOutPolicy(value string) (FanOutPolicy, error) {
+	switch policy := FanOutPolicy(value); policy {
+	case FanOutAll, FanOutBestEffort, FanOutQuorum:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown fan-out policy '%s', expected one of %s, %s or %s", value, FanOutAll, FanOutBestEffort, FanOutQuorum)
+}
+
+// ClusterResult is the outcome of an operation in one cluster. Resources is nil for deregistrations.
+type ClusterResult struct {
+	Cluster   string
+	Resources *RegisteredKongResources
+	Err       error
+}
+
+// FanOutError reports the clusters an operation failed in, when too many did for the policy.
+type FanOutError struct {
+	Policy  FanOutPolicy
+	Results []*ClusterResult
+}
+
+func (fanOutError *FanOutError) Error() string {
+	failures := []string{}
+	for _, result := range fanOutError.Results {
+		if result.Err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", result.Cluster, result.Err))
+		}
+	}
+	return fmt.Sprintf("failed in %d of %d clusters with policy %s: %s", len(failures), len(fanOutError.Results), fanOutError.Policy, strings.Join(failures, "; "))
+}
+
+// MultiKongo applies registrations to several Kong clusters concurrently, each Kongo being named after its cluster.
+type MultiKongo struct {
+	clusters map[string]*Kongo
+	policy   FanOutPolicy
+}
+
+func NewMultiKongo(policy FanOutPolicy, clusters map[string]*Kongo) (*MultiKongo, error) {
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("a MultiKongo needs at least one cluster")
+	}
+	policy, err := ParseFanOutPolicy(string(policy))
+	if err != nil {
+		return nil, err
+	}
+	return &MultiKongo{clusters: clusters, policy: policy}, nil
+}
+
+// Clusters lists the names of the clusters, sorted.
+func (multi *MultiKongo) Clusters() []string {
+	names := []string{}
+	for name := range multi.clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Kongo gives the Kongo of a cluster, nil for unknown clusters.
+func (multi *MultiKongo) Kongo(cluster string) *Kongo {
+	return multi.clusters[cluster]
+}
+
+// RegisterK8sService registers the service in every cluster, each registration being atomic within its cluster. With
+// FanOutAll, a failure in one cluster does not undo the registrations in the others.
+func (multi *MultiKongo) RegisterK8sService(k8sService *K8sService) ([]*ClusterResult, error) {
+	return multi.fanOut(func(kongo *Kongo) (*RegisteredKongResources, error) {
+		return kongo.RegisterK8sService(k8sService)
+	})
+}
+
+func (multi *MultiKongo) SyncK8sService(k8sService *K8sService) ([]*ClusterResult, error) {
+	return multi.fanOut(func(kongo *Kongo) (*RegisteredKongResources, error) {
+		return kongo.SyncK8sService(k8sService)
+	})
+}
+
+func (multi *MultiKongo) DeregisterK8sService(baseName string) ([]*ClusterResult, error) {
+	return multi.fanOut(func(kongo *Kongo) (*RegisteredKongResources, error) {
+		return nil, kongo.DeregisterK8sService(baseName)
+	})
+}
+
+// fanOut runs the operation in every cluster concurrently, returning the results sorted by cluster along with a
+// FanOutError when the policy is not met.
+func (multi *MultiKongo) fanOut(operation func(kongo *Kongo) (*RegisteredKongResources, error)) ([]*ClusterResult, error) {
+	clusters := multi.Clusters()
+	results := make([]*ClusterResult, len(clusters))
+
+	var waitGroup sync.WaitGroup
+	for i, cluster := range clusters {
+		waitGroup.Add(1)
+		go func(i int, cluster string) {
+			defer waitGroup.Done()
+			resources, err := operation(multi.clusters[cluster])
+			results[i] = &ClusterResult{Cluster: cluster, Resources: resources, Err: err}
+		}(i, cluster)
+	}
+	waitGroup.Wait()
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Err == nil {
+			succeeded++
+		}
+	}
+
+	var met bool
+	switch multi.policy {
+	case FanOutAll:
+		met = succeeded == len(results)
+	case FanOutBestEffort:
+		met = succeeded > 0
+	case FanOutQuorum:
+		met = succeeded > len(results)/2
+	}
+	if !met {
+		return results, &FanOutError{Policy: multi.policy, Results: results}
+	}
+	return results, nil
+}
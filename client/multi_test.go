@@ -0,0 +1,55 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFanOutPolicies(t *testing.T) {
+	clusters := map[string]*Kongo{"east": new(Kongo), "west": new(Kongo), "north": new(Kongo)}
+	failing := map[*Kongo]bool{clusters["east"]: true, clusters["west"]: true}
+	operation := func(kongo *Kongo) (*RegisteredKongResources, error) {
+		if failing[kongo] {
+			return nil, fmt.Errorf("unavailable")
+		}
+		return &RegisteredKongResources{}, nil
+	}
+
+	cases := []struct {
+		policy  FanOutPolicy
+		failing int
+		met     bool
+	}{
+		{FanOutAll, 0, true},
+		{FanOutAll, 1, false},
+		{FanOutQuorum, 1, true},
+		{FanOutQuorum, 2, false},
+		{FanOutBestEffort, 2, true},
+	}
+
+	for _, c := range cases {
+		failing[clusters["west"]] = c.failing > 1
+		failing[clusters["east"]] = c.failing > 0
+		multi, err := NewMultiKongo(c.policy, clusters)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		results, err := multi.fanOut(operation)
+		if (err == nil) != c.met {
+			t.Fatalf("Policy %s with %d failing clusters should be met: %v, got %v", c.policy, c.failing, c.met, err)
+		}
+		if len(results) != 3 || results[0].Cluster != "east" || (results[0].Err != nil) != (c.failing > 0) {
+			t.Fatalf("Every cluster should have a result, sorted by cluster: %+v", results)
+		}
+	}
+}
+
+func TestParseFanOutPolicy(t *testing.T) {
+	if _, err := ParseFanOutPolicy("most"); err == nil {
+		t.Fatalf("Unknown policies should be rejected")
+	}
+	if _, err := NewMultiKongo(FanOutAll, map[string]*Kongo{}); err == nil {
+		t.Fatalf("A MultiKongo without clusters should be rejected")
+	}
+}
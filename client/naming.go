@@ -0,0 +1,206 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// NameComponents are what the name of a Kong entity created by kongo is made of. Name may itself contain dots, as do
+// the names given to Ingress backends.
+type NameComponents struct {
+	Cluster   string
+	Namespace string
+	Name      string
+	// Kind is one of KindRoute, KindService or KindUpstream.
+	Kind string
+}
+
+// NamingStrategy names the Upstreams, Services and Routes kongo creates and maps such names back to their components.
+type NamingStrategy interface {
+	KongName(components NameComponents) (string, error)
+	// ParseKongName reports false for names the strategy would not have produced.
+	ParseKongName(name string) (NameComponents, bool)
+}
+
+// DefaultNamingStrategy joins the namespace, name and kind with dots, as NewKongNames does. It has no notion of cluster.
+var DefaultNamingStrategy NamingStrategy = defaultNamingStrategy{}
+
+type defaultNamingStrategy struct{}
+
+func (defaultNamingStrategy) KongName(components NameComponents) (string, error) {
+	baseName := components.Name
+	if components.Namespace != "" {
+		baseName = components.Namespace + "." + components.Name
+	}
+	name := baseName + "." + components.Kind
+	return name, ValidateKongName(components.Kind, name)
+}
+
+func (defaultNamingStrategy) ParseKongName(name string) (NameComponents, bool) {
+	baseName, kind, ok := ParseKongName(name)
+	if !ok {
+		return NameComponents{}, false
+	}
+	namespace, baseName := SplitBaseName(baseName)
+	return NameComponents{Namespace: namespace, Name: baseName, Kind: kind}, true
+}
+
+// TemplateNamingStrategy renders names with a text/template over NameComponents, e.g.
+// `{{.Cluster}}-{{.Namespace}}-{{.Name}}-{{.Kind}}`, the cluster being fixed for the strategy.
+type TemplateNamingStrategy struct {
+	cluster  string
+	template *template.Template
+	pattern  *regexp.Regexp
+}
+
+// Placeholders rendered in place of the components to derive the pattern parsing names back.
+const (
+	clusterPlaceholder   = "\x00cluster\x00"
+	namespacePlaceholder = "\x00namespace\x00"
+	namePlaceholder      = "\x00name\x00"
+	kindPlaceholder      = "\x00kind\x00"
+)
+
+// NewTemplateNamingStrategy requires the template to use both .Name and .Kind, so that no two entities share a name.
+// Parsing a name back is ambiguous when the text between components can also appear within them, as a dash can within
+// a namespace; the shortest namespace is then assumed.
+func NewTemplateNamingStrategy(text string, cluster string) (*TemplateNamingStrategy, error) {
+	parsed, err := template.New("kong-name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid naming template: %v", err)
+	}
+
+	var rendered bytes.Buffer
+	err = parsed.Execute(&rendered, NameComponents{
+		Cluster:   clusterPlaceholder,
+		Namespace: namespacePlaceholder,
+		Name:      namePlaceholder,
+		Kind:      kindPlaceholder,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid naming template: %v", err)
+	}
+	if !strings.Contains(rendered.String(), namePlaceholder) || !strings.Contains(rendered.String(), kindPlaceholder) {
+		return nil, fmt.Errorf("invalid naming template '%s': it must use both .Name and .Kind", text)
+	}
+
+	pattern := regexp.QuoteMeta(rendered.String())
+	pattern = strings.ReplaceAll(pattern, clusterPlaceholder, regexp.QuoteMeta(cluster))
+	pattern = replaceFirst(pattern, namespacePlaceholder, `(?P<namespace>.*?)`, `.*?`)
+	pattern = replaceFirst(pattern, namePlaceholder, `(?P<name>.+)`, `.+`)
+	pattern = replaceFirst(pattern, kindPlaceholder, `(?P<kind>route|service|upstream)`, `(?:route|service|upstream)`)
+
+	return &TemplateNamingStrategy{
+		cluster:  cluster,
+		template: parsed,
+		pattern:  regexp.MustCompile("^" + pattern + "$"),
+	}, nil
+}
+
+func (strategy *TemplateNamingStrategy) KongName(components NameComponents) (string, error) {
+	components.Cluster = strategy.cluster
+	var rendered bytes.Buffer
+	err := strategy.template.Execute(&rendered, components)
+	if err != nil {
+		return "", fmt.Errorf("error rendering the %s name of '%s': %v", components.Kind, components.Name, err)
+	}
+	return rendered.String(), ValidateKongName(components.Kind, rendered.String())
+}
+
+// ParseKongName only accepts names rendered for the cluster of the strategy.
+func (strategy *TemplateNamingStrategy) ParseKongName(name string) (NameComponents, bool) {
+	match := strategy.pattern.FindStringSubmatch(name)
+	if match == nil {
+		return NameComponents{}, false
+	}
+
+	components := NameComponents{Cluster: strategy.cluster}
+	for i, group := range strategy.pattern.SubexpNames() {
+		switch group {
+		case "namespace":
+			components.Namespace = match[i]
+		case "name":
+			components.Name = match[i]
+		case "kind":
+			components.Kind = match[i]
+		}
+	}
+
+	rendered, err := strategy.KongName(components)
+	if err != nil || rendered != name {
+		return NameComponents{}, false
+	}
+	return components, true
+}
+
+// replaceFirst lets only the first occurrence of a placeholder capture, the pattern being matched once.
+func replaceFirst(pattern string, placeholder string, capture string, repeat string) string {
+	pattern = strings.Replace(pattern, placeholder, capture, 1)
+	return strings.ReplaceAll(pattern, placeholder, repeat)
+}
+
+var (
+	routeNamePattern     = regexp.MustCompile(`^[0-9A-Za-z.\-_~]+$`)
+	hostnameLabelPattern = regexp.MustCompile(`^[0-9A-Za-z_]([0-9A-Za-z\-_]*[0-9A-Za-z_])?$`)
+)
+
+const (
+	maxHostnameLength      = 253
+	maxHostnameLabelLength = 63
+)
+
+// ValidateKongName applies Kong's rules: Service and Route names are made of letters, digits and `.-_~`, while Upstream
+// names are hostnames, since Services address their Upstream through their host.
+func ValidateKongName(kind string, name string) error {
+	switch kind {
+	case KindRoute, KindService:
+		if !routeNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid %s name '%s': only letters, digits and '.', '-', '_' or '~' are allowed", kind, name)
+		}
+	case KindUpstream:
+		if len(name) == 0 || len(name) > maxHostnameLength {
+			return fmt.Errorf("invalid upstream name '%s': it must be a hostname of 1 to %d characters", name, maxHostnameLength)
+		}
+		for _, label := range strings.Split(name, ".") {
+			if len(label) > maxHostnameLabelLength || !hostnameLabelPattern.MatchString(label) {
+				return fmt.Errorf("invalid upstream name '%s': label '%s' is not a hostname label of at most %d characters", name, label, maxHostnameLabelLength)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown kind '%s' for name '%s'", kind, name)
+	}
+	return nil
+}
+
+// NamesFor names the Upstream, Service and Route of the components with the strategy.
+func NamesFor(strategy NamingStrategy, components NameComponents) (*KongNames, error) {
+	kongNames := new(KongNames)
+	for _, name := range []struct {
+		kind string
+		into *string
+	}{
+		{KindUpstream, &kongNames.UpstreamName},
+		{KindService, &kongNames.ServiceName},
+		{KindRoute, &kongNames.RouteName},
+	} {
+		components.Kind = name.kind
+		kongName, err := strategy.KongName(components)
+		if err != nil {
+			return nil, err
+		}
+		*name.into = kongName
+	}
+	return kongNames, nil
+}
+
+// SplitBaseName splits a base name such as `namespace.name` at its first dot, Kubernetes namespaces having none.
+func SplitBaseName(baseName string) (namespace string, name string) {
+	separator := strings.Index(baseName, ".")
+	if separator < 0 {
+		return "", baseName
+	}
+	return baseName[:separator], baseName[separator+1:]
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultNamingStrategy(t *testing.T) {
+	kongNames, err := NamesFor(DefaultNamingStrategy, NameComponents{Namespace: "kongo", Name: "orders"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *kongNames != *NewKongNames("kongo.orders") {
+		t.Fatalf("The default strategy should keep the names of NewKongNames: %+v", kongNames)
+	}
+
+	components, ok := DefaultNamingStrategy.ParseKongName("shop.storefront.cart.8080.service")
+	if !ok || components.Namespace != "shop" || components.Name != "storefront.cart.8080" || components.Kind != KindService {
+		t.Fatalf("The name should parse back to its components: %+v", components)
+	}
+	if _, ok := DefaultNamingStrategy.ParseKongName("kongo.orders.consumer"); ok {
+		t.Fatalf("Names of other kinds should not parse")
+	}
+}
+
+func TestTemplateNamingStrategy(t *testing.T) {
+	strategy, err := NewTemplateNamingStrategy("{{.Cluster}}-{{.Namespace}}-{{.Name}}-{{.Kind}}", "east")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kongNames, err := NamesFor(strategy, NameComponents{Namespace: "kongo", Name: "orders"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kongNames.UpstreamName != "east-kongo-orders-upstream" || kongNames.RouteName != "east-kongo-orders-route" {
+		t.Fatalf("The template should be rendered with the cluster: %+v", kongNames)
+	}
+
+	components, ok := strategy.ParseKongName("east-kongo-orders-upstream")
+	if !ok || components != (NameComponents{Cluster: "east", Namespace: "kongo", Name: "orders", Kind: KindUpstream}) {
+		t.Fatalf("The name should parse back to its components: %+v", components)
+	}
+	components, ok = strategy.ParseKongName("east-kongo-order-history-route")
+	if !ok || components.Namespace != "kongo" || components.Name != "order-history" {
+		t.Fatalf("The shortest namespace should be assumed: %+v", components)
+	}
+
+	for _, name := range []string{"west-kongo-orders-upstream", "east-kongo-orders-consumer", "kongo.orders.upstream"} {
+		if _, ok := strategy.ParseKongName(name); ok {
+			t.Fatalf("'%s' should not parse", name)
+		}
+	}
+}
+
+func TestTemplateNamingStrategyRequiresNameAndKind(t *testing.T) {
+	for _, text := range []string{"{{.Namespace}}-{{.Name}}", "{{.Namespace}}-{{.Kind}}", "{{.Missing}}", "{{.Name"} {
+		if _, err := NewTemplateNamingStrategy(text, "east"); err == nil {
+			t.Fatalf("'%s' should be rejected", text)
+		}
+	}
+}
+
+func TestValidateKongName(t *testing.T) {
+	cases := []struct {
+		kind  string
+		name  string
+		valid bool
+	}{
+		{KindRoute, "kongo.orders.route", true},
+		{KindService, "kongo_orders~v1", true},
+		{KindService, "kongo/orders", false},
+		{KindRoute, "", false},
+		{KindUpstream, "kongo.orders.upstream", true},
+		{KindUpstream, "kongo-orders_v1", true},
+		{KindUpstream, "kongo..orders", false},
+		{KindUpstream, "-kongo.orders", false},
+		{KindUpstream, "kongo~orders", false},
+		{KindUpstream, strings.Repeat("a", 64) + ".upstream", false},
+		{KindUpstream, strings.Repeat(strings.Repeat("a", 60)+".", 5) + "upstream", false},
+		{"consumer", "kongo.orders.consumer", false},
+	}
+
+	for _, c := range cases {
+		err := ValidateKongName(c.kind, c.name)
+		if (err == nil) != c.valid {
+			t.Fatalf("%s name '%s' validity should be %v: %v", c.kind, c.name, c.valid, err)
+		}
+	}
+}
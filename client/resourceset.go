@@ -0,0 +1,422 @@
+package client
+
+import (
+	"fmt"
+	"github.com/hbagdi/go-kong/kong"
+	"sort"
+	"strings"
+)
+
+// ResourceSet is a group of Kong entities kept in Kong as a whole by SyncResourceSet. Entities refer to each other by
+// name: Routes and Plugins to their Service, e.g. `Service: &kong.Service{Name: kong.String(serviceDef.Name)}`, Plugins
+// to their Route, and Targets to their Upstream.
+type ResourceSet struct {
+	Upstreams    []*UpstreamDef
+	Targets      []*TargetDef
+	Services     []*ServiceDef
+	Routes       []*RouteDef
+	Plugins      []*PluginDef
+	Certificates []*CertificateDef
+}
+
+// AppliedResourceSet holds the entities ApplyResourceSet left in Kong, by name, Targets by the name of their Upstream.
+// Plugins are in the order of their definitions.
+type AppliedResourceSet struct {
+	Upstreams map[string]*kong.Upstream
+	Targets   map[string][]*kong.Target
+	Services  map[string]*kong.Service
+	Routes    map[string]*kong.Route
+	Plugins   []*kong.Plugin
+}
+
+// SyncResourceSet creates or updates, by name, the entities of the set, tagging them with the owner. Entities tagged with
+// the owner that are no longer part of the set are deleted. Certificates are matched on their SNIs.
+func (kongo *Kongo) SyncResourceSet(owner string, resourceSet *ResourceSet) error {
+	_, err := kongo.ApplyResourceSet(owner, resourceSet)
+	return err
+}
+
+// ApplyResourceSet is SyncResourceSet, also returning the entities now in Kong.
+func (kongo *Kongo) ApplyResourceSet(owner string, resourceSet *ResourceSet) (*AppliedResourceSet, error) {
+	ownerTags := mergeTags(kongo.tags, kong.StringSlice(owner))
+	applied := &AppliedResourceSet{
+		Upstreams: make(map[string]*kong.Upstream),
+		Targets:   make(map[string][]*kong.Target),
+		Services:  make(map[string]*kong.Service),
+		Routes:    make(map[string]*kong.Route),
+		Plugins:   []*kong.Plugin{},
+	}
+
+	for _, upstreamDef := range resourceSet.Upstreams {
+		kongUpstream, err := kongo.upsertUpstream(&kong.Upstream{
+			Name:         kong.String(upstreamDef.Name),
+			Algorithm:    optionalString(upstreamDef.Algorithm),
+			Healthchecks: upstreamDef.Healthchecks,
+			Tags:         ownerTags,
+		})
+		if err != nil {
+			return applied, fmt.Errorf("error syncing Upstream '%s': %v", upstreamDef.Name, err)
+		}
+		applied.Upstreams[upstreamDef.Name] = kongUpstream
+	}
+
+	targetDefs := make(map[string][]*TargetDef)
+	for _, targetDef := range resourceSet.Targets {
+		if _, found := applied.Upstreams[*targetDef.Upstream.Name]; !found {
+			return applied, fmt.Errorf("Target '%s' refers to Upstream '%s' outside of the set", targetDef.Target, *targetDef.Upstream.Name)
+		}
+		targetDefs[*targetDef.Upstream.Name] = append(targetDefs[*targetDef.Upstream.Name], targetDef)
+	}
+	for name, kongUpstream := range applied.Upstreams {
+		targets, err := kongo.SyncTargets(kongUpstream, targetDefs[name])
+		applied.Targets[name] = targets
+		if err != nil {
+			return applied, err
+		}
+	}
+
+	for _, serviceDef := range resourceSet.Services {
+		kongService, err := kongo.upsertService(&kong.Service{
+			Name:     kong.String(serviceDef.Name),
+			Host:     kong.String(serviceDef.Host),
+			Path:     optionalString(serviceDef.Path),
+			Port:     kong.Int(serviceDef.Port),
+			Protocol: kong.String("http"),
+			Tags:     ownerTags,
+		})
+		if err != nil {
+			return applied, fmt.Errorf("error syncing Service '%s': %v", serviceDef.Name, err)
+		}
+		applied.Services[serviceDef.Name] = kongService
+	}
+
+	for _, routeDef := range resourceSet.Routes {
+		kongService, found := applied.Services[*routeDef.Service.Name]
+		if !found {
+			return applied, fmt.Errorf("Route '%s' refers to Service '%s' outside of the set", routeDef.Name, *routeDef.Service.Name)
+		}
+		kongRoute, err := kongo.upsertRoute(&kong.Route{
+			Name:      kong.String(routeDef.Name),
+			Paths:     routeDef.Paths,
+			Hosts:     routeDef.Hosts,
+			Methods:   routeDef.Methods,
+			Protocols: routeDef.Protocols,
+			StripPath: kong.Bool(routeDef.StripPath),
+			Service:   &kong.Service{ID: kongService.ID},
+			Tags:      ownerTags,
+		})
+		if err != nil {
+			return applied, fmt.Errorf("error syncing Route '%s': %v", routeDef.Name, err)
+		}
+		applied.Routes[routeDef.Name] = kongRoute
+	}
+
+	err := kongo.syncOwnedPlugins(owner, ownerTags, resourceSet.Plugins, applied)
+	if err != nil {
+		return applied, err
+	}
+
+	err = kongo.syncOwnedCertificates(owner, ownerTags, resourceSet.Certificates)
+	if err != nil {
+		return applied, err
+	}
+
+	routes, err := kongo.listRoutesTagged(owner)
+	if err != nil {
+		return applied, fmt.Errorf("error listing Routes of '%s': %v", owner, err)
+	}
+	routeTasks := []*Task{}
+	for _, route := range routes {
+		if _, found := applied.Routes[*route.Name]; !found {
+			routeTasks = append(routeTasks, kongo.staleTask(&KongEntity{Kind: KindRoute, ID: *route.ID, Name: *route.Name}))
+		}
+	}
+
+	services, err := kongo.listServicesTagged(owner)
+	if err != nil {
+		return applied, fmt.Errorf("error listing Services of '%s': %v", owner, err)
+	}
+	otherTasks := []*Task{}
+	for _, service := range services {
+		if _, found := applied.Services[*service.Name]; !found {
+			otherTasks = append(otherTasks, kongo.staleTask(&KongEntity{Kind: KindService, ID: *service.ID, Name: *service.Name}))
+		}
+	}
+
+	upstreams, err := kongo.listUpstreamsTagged(owner)
+	if err != nil {
+		return applied, fmt.Errorf("error listing Upstreams of '%s': %v", owner, err)
+	}
+	for _, upstream := range upstreams {
+		if _, found := applied.Upstreams[*upstream.Name]; !found {
+			otherTasks = append(otherTasks, kongo.staleTask(&KongEntity{Kind: KindUpstream, ID: *upstream.ID, Name: *upstream.Name}))
+		}
+	}
+
+	// Stale Routes go before the Services they refer to.
+	err = kongo.bulk().RunPhases(kongo.context, routeTasks, otherTasks)
+	if err != nil {
+		return applied, err
+	}
+
+	return applied, nil
+}
+
+// staleTask deletes an entity no longer part of a set, counting it as drift.
+func (kongo *Kongo) staleTask(entity *KongEntity) *Task {
+	return &Task{Name: entity.Kind + " " + entity.Name, Run: func() error {
+		err := kongo.DeleteKongEntity(entity)
+		if err == nil {
+			kongo.log().Info("deleted stale entity", "kind", entity.Kind, "name", entity.Name)
+			driftDetectedTotal.WithLabelValues(entity.Kind).Inc()
+		}
+		return err
+	}}
+}
+
+// DeleteResourceSet deletes every entity SyncResourceSet tagged with the owner.
+func (kongo *Kongo) DeleteResourceSet(owner string) error {
+	return kongo.SyncResourceSet(owner, &ResourceSet{})
+}
+
+// syncOwnedPlugins matches Plugins on their name and the Route or Service they are applied to. Stale Plugins are deleted
+// before their Route or Service would be. Untagged Plugins already applied to a Route or Service of the set, as created
+// before entities were tagged with their owner, are adopted rather than duplicated.
+func (kongo *Kongo) syncOwnedPlugins(owner string, ownerTags []*string, pluginDefs []*PluginDef, applied *AppliedResourceSet) error {
+	existingPlugins, err := kongo.listPluginsTagged(owner)
+	if err != nil {
+		return fmt.Errorf("error listing Plugins of '%s': %v", owner, err)
+	}
+
+	existingByKey := make(map[string]*kong.Plugin)
+	for _, existing := range existingPlugins {
+		existingByKey[pluginKey(existing)] = existing
+	}
+
+	adoptable, err := kongo.listAdoptablePlugins(applied)
+	if err != nil {
+		return err
+	}
+
+	for _, pluginDef := range pluginDefs {
+		kongPlugin := &kong.Plugin{
+			Name:    kong.String(pluginDef.Name),
+			Config:  pluginDef.Config,
+			Enabled: kong.Bool(true),
+			Tags:    ownerTags,
+		}
+		if pluginDef.Route != nil {
+			kongRoute, found := applied.Routes[*pluginDef.Route.Name]
+			if !found {
+				return fmt.Errorf("Plugin '%s' refers to Route '%s' outside of the set", pluginDef.Name, *pluginDef.Route.Name)
+			}
+			kongPlugin.Route = &kong.Route{ID: kongRoute.ID}
+		} else if pluginDef.Service != nil {
+			kongService, found := applied.Services[*pluginDef.Service.Name]
+			if !found {
+				return fmt.Errorf("Plugin '%s' refers to Service '%s' outside of the set", pluginDef.Name, *pluginDef.Service.Name)
+			}
+			kongPlugin.Service = &kong.Service{ID: kongService.ID}
+		}
+
+		key := pluginKey(kongPlugin)
+		existing, found := existingByKey[key]
+		delete(existingByKey, key)
+		if !found {
+			existing, found = adoptable[key]
+		}
+
+		switch {
+		case !found:
+			kongPlugin, err = kongo.Kong.Plugins.Create(kongo.context, kongPlugin)
+		case !configContains(existing.Config, pluginDef.Config) || !hasAllTags(existing.Tags, []string{owner}):
+			kongPlugin.ID = existing.ID
+			kongPlugin, err = kongo.Kong.Plugins.Update(kongo.context, kongPlugin)
+		default:
+			kongPlugin = existing
+		}
+		if err != nil {
+			return fmt.Errorf("error syncing Plugin '%s': %v", key, err)
+		}
+		applied.Plugins = append(applied.Plugins, kongPlugin)
+	}
+
+	for key, stale := range existingByKey {
+		_, err := kongo.DeletePlugin(*stale.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting Plugin '%s': %v", key, err)
+		}
+	}
+
+	return nil
+}
+
+// OwnerOf finds the owner tag among the tags of an entity, owner tags being of the form `kongo-<kind>:<name>`.
+func OwnerOf(tags []*string) (string, bool) {
+	for _, tag := range tags {
+		if strings.HasPrefix(*tag, "kongo-") && strings.Contains(*tag, ":") {
+			return *tag, true
+		}
+	}
+	return "", false
+}
+
+// listAdoptablePlugins lists, by key, the untagged Plugins applied to the Routes and Services of the set.
+func (kongo *Kongo) listAdoptablePlugins(applied *AppliedResourceSet) (map[string]*kong.Plugin, error) {
+	adoptable := make(map[string]*kong.Plugin)
+	for name, route := range applied.Routes {
+		plugins, err := kongo.Kong.Plugins.ListAllForRoute(kongo.context, route.ID)
+		if err != nil {
+			return nil, fmt.Errorf("error listing Plugins for Route '%s': %v", name, err)
+		}
+		for _, plugin := range plugins {
+			if _, owned := OwnerOf(plugin.Tags); !owned && plugin.Consumer == nil {
+				adoptable[pluginKey(plugin)] = plugin
+			}
+		}
+	}
+	for name, service := range applied.Services {
+		plugins, err := kongo.Kong.Plugins.ListAllForService(kongo.context, service.ID)
+		if err != nil {
+			return nil, fmt.Errorf("error listing Plugins for Service '%s': %v", name, err)
+		}
+		for _, plugin := range plugins {
+			if _, owned := OwnerOf(plugin.Tags); !owned && plugin.Consumer == nil {
+				adoptable[pluginKey(plugin)] = plugin
+			}
+		}
+	}
+	return adoptable, nil
+}
+
+func pluginKey(plugin *kong.Plugin) string {
+	switch {
+	case plugin.Route != nil && plugin.Route.ID != nil:
+		return *plugin.Name + "@route:" + *plugin.Route.ID
+	case plugin.Service != nil && plugin.Service.ID != nil:
+		return *plugin.Name + "@service:" + *plugin.Service.ID
+	default:
+		return *plugin.Name
+	}
+}
+
+func (kongo *Kongo) syncOwnedCertificates(owner string, ownerTags []*string, certificateDefs []*CertificateDef) error {
+	existingCertificates, err := kongo.listCertificatesTagged(owner)
+	if err != nil {
+		return fmt.Errorf("error listing Certificates of '%s': %v", owner, err)
+	}
+
+	existingBySNIs := make(map[string]*kong.Certificate)
+	for _, existing := range existingCertificates {
+		existingBySNIs[sniKey(existing.SNIs)] = existing
+	}
+
+	for _, certificateDef := range certificateDefs {
+		key := sniKey(certificateDef.SNIs)
+		existing, found := existingBySNIs[key]
+		delete(existingBySNIs, key)
+
+		kongCertificate := &kong.Certificate{
+			Cert: kong.String(certificateDef.Cert),
+			Key:  kong.String(certificateDef.Key),
+			SNIs: certificateDef.SNIs,
+			Tags: ownerTags,
+		}
+		if !found {
+			_, err = kongo.Kong.Certificates.Create(kongo.context, kongCertificate)
+		} else if *existing.Cert != certificateDef.Cert || *existing.Key != certificateDef.Key {
+			kongCertificate.ID = existing.ID
+			_, err = kongo.Kong.Certificates.Update(kongo.context, kongCertificate)
+		}
+		if err != nil {
+			return fmt.Errorf("error syncing Certificate for '%s': %v", key, err)
+		}
+	}
+
+	for key, stale := range existingBySNIs {
+		_, err := kongo.DeleteCertificate(*stale.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting Certificate for '%s': %v", key, err)
+		}
+	}
+
+	return nil
+}
+
+func sniKey(snis []*string) string {
+	names := []string{}
+	for _, sni := range snis {
+		names = append(names, *sni)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func (kongo *Kongo) listRoutesTagged(tag string) ([]*kong.Route, error) {
+	routes := []*kong.Route{}
+	for listOptions := tagListOptions(tag); listOptions != nil; {
+		page, next, err := kongo.Kong.Routes.List(kongo.context, listOptions)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, page...)
+		listOptions = next
+	}
+	return routes, nil
+}
+
+func (kongo *Kongo) listServicesTagged(tag string) ([]*kong.Service, error) {
+	services := []*kong.Service{}
+	for listOptions := tagListOptions(tag); listOptions != nil; {
+		page, next, err := kongo.Kong.Services.List(kongo.context, listOptions)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, page...)
+		listOptions = next
+	}
+	return services, nil
+}
+
+func (kongo *Kongo) listUpstreamsTagged(tag string) ([]*kong.Upstream, error) {
+	upstreams := []*kong.Upstream{}
+	for listOptions := tagListOptions(tag); listOptions != nil; {
+		page, next, err := kongo.Kong.Upstreams.List(kongo.context, listOptions)
+		if err != nil {
+			return nil, err
+		}
+		upstreams = append(upstreams, page...)
+		listOptions = next
+	}
+	return upstreams, nil
+}
+
+func (kongo *Kongo) listPluginsTagged(tag string) ([]*kong.Plugin, error) {
+	plugins := []*kong.Plugin{}
+	for listOptions := tagListOptions(tag); listOptions != nil; {
+		page, next, err := kongo.Kong.Plugins.List(kongo.context, listOptions)
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, page...)
+		listOptions = next
+	}
+	return plugins, nil
+}
+
+func (kongo *Kongo) listCertificatesTagged(tag string) ([]*kong.Certificate, error) {
+	certificates := []*kong.Certificate{}
+	for listOptions := tagListOptions(tag); listOptions != nil; {
+		page, next, err := kongo.Kong.Certificates.List(kongo.context, listOptions)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, page...)
+		listOptions = next
+	}
+	return certificates, nil
+}
+
+func tagListOptions(tag string) *kong.ListOpt {
+	return &kong.ListOpt{Size: 1000, Tags: kong.StringSlice(tag)}
+}
@@ -0,0 +1,189 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy retries requests to the Admin API failing with connection errors, 5xx or 429, waiting a delay growing
+// exponentially between attempts. A POST is only retried when Kong cannot have acted on it: the connection was never
+// made, or Kong answered 429 or 503. The PATCHes of kongo set whole entities, so they are retried like PUTs.
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt, 1 disabling retries.
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	// Jitter is the fraction of each delay that is random, 0 to 1, so that clients retrying together spread out.
+	Jitter float64
+	// OnRetry is called before waiting for each retry.
+	OnRetry func(attempt *RetryAttempt)
+}
+
+// DefaultRetryPolicy retries 4 times, over 3 seconds at most unless Kong asks to wait longer.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:  5,
+	InitialDelay: 200 * time.Millisecond,
+	MaxDelay:     5 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.5,
+}
+
+// RetryAttempt describes a failed attempt about to be retried. StatusCode is 0 for connection errors.
+type RetryAttempt struct {
+	Method string
+	Url    string
+	// Attempt is the number of the failed attempt, starting at 1.
+	Attempt    int
+	Delay      time.Duration
+	StatusCode int
+	Err        error
+}
+
+// WithRetryPolicy retries the requests failing transiently, as when Kong restarts or runs its migrations.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(settings *kongoSettings) error {
+		settings.retryPolicy = &policy
+		return nil
+	}
+}
+
+type RetryRoundTripper struct {
+	policy       RetryPolicy
+	roundTripper http.RoundTripper
+	logger       Logger
+}
+
+func NewRetryRoundTripper(policy RetryPolicy, roundTripper http.RoundTripper) *RetryRoundTripper {
+	return &RetryRoundTripper{policy: policy, roundTripper: roundTripper, logger: discardLogger{}}
+}
+
+func (rrt *RetryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	getBody, err := rewindableBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	delay := rrt.policy.InitialDelay
+	for attempt := 1; ; attempt++ {
+		// A RoundTripper must not modify the request, each attempt sends a clone with a body of its own.
+		attemptRequest := req
+		if getBody != nil {
+			attemptRequest = req.Clone(req.Context())
+			attemptRequest.Body, err = getBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		response, err := rrt.roundTripper.RoundTrip(attemptRequest)
+		reason, retryable := retryReason(req.Method, response, err)
+		if !retryable || attempt >= rrt.policy.MaxAttempts || req.Context().Err() != nil {
+			return response, err
+		}
+
+		wait := rrt.jittered(delay)
+		if retryAfter := retryAfterDelay(response); retryAfter > wait {
+			wait = retryAfter
+		}
+		if rrt.policy.MaxDelay > 0 && wait > rrt.policy.MaxDelay {
+			wait = rrt.policy.MaxDelay
+		}
+
+		retryAttempt := &RetryAttempt{Method: req.Method, Url: req.URL.String(), Attempt: attempt, Delay: wait, Err: err}
+		if response != nil {
+			retryAttempt.StatusCode = response.StatusCode
+			// The response is dropped; its connection is only reused once its body is drained.
+			ioutil.ReadAll(response.Body)
+			response.Body.Close()
+		}
+		retriesTotal.WithLabelValues(req.Method, reason).Inc()
+		rrt.logger.Warn("retrying Kong Admin API request", "method", req.Method, "url", retryAttempt.Url,
+			"attempt", attempt, "status", retryAttempt.StatusCode, "error", err, "delay", wait)
+		if rrt.policy.OnRetry != nil {
+			rrt.policy.OnRetry(retryAttempt)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		delay = time.Duration(float64(delay) * rrt.policy.Multiplier)
+		if rrt.policy.MaxDelay > 0 && delay > rrt.policy.MaxDelay {
+			delay = rrt.policy.MaxDelay
+		}
+	}
+}
+
+func (rrt *RetryRoundTripper) jittered(delay time.Duration) time.Duration {
+	return time.Duration(float64(delay) * (1 - rrt.policy.Jitter*rand.Float64()))
+}
+
+// retryReason tells whether the outcome of a request is worth retrying, and why, as the metrics label it: `connection`
+// or the status code.
+func retryReason(method string, response *http.Response, err error) (string, bool) {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrCircuitOpen) {
+			return "", false
+		}
+		return "connection", method != http.MethodPost || isDialError(err)
+	}
+
+	status := response.StatusCode
+	switch {
+	case status == http.StatusTooManyRequests, status == http.StatusServiceUnavailable:
+		return strconv.Itoa(status), true
+	case status >= 500:
+		return strconv.Itoa(status), method != http.MethodPost
+	}
+	return "", false
+}
+
+// isDialError tells whether the request failed before reaching Kong.
+func isDialError(err error) bool {
+	var opError *net.OpError
+	return errors.As(err, &opError) && opError.Op == "dial"
+}
+
+// retryAfterDelay reads the Retry-After header, in seconds, of 429 and 503 responses.
+func retryAfterDelay(response *http.Response) time.Duration {
+	if response == nil {
+		return 0
+	}
+	seconds, err := strconv.Atoi(response.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// rewindableBody gives a way to read the body of the request again for each attempt, buffering it unless the request
+// has GetBody. The request itself is left as it is, but for its body being consumed and closed.
+func rewindableBody(req *http.Request) (func() (io.ReadCloser, error), error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		return req.GetBody, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}, nil
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		statuses []int
+		attempts int
+		status   int
+	}{
+		{"GET retried until it succeeds", http.MethodGet, []int{502, 500, 200}, 3, 200},
+		{"GET retried on 429", http.MethodGet, []int{429, 200}, 2, 200},
+		{"GET not retried on 4xx", http.MethodGet, []int{409, 200}, 1, 409},
+		{"DELETE given up after the last attempt", http.MethodDelete, []int{500, 500, 500, 500}, 3, 500},
+		{"POST not retried on 500", http.MethodPost, []int{500, 201}, 1, 500},
+		{"POST retried on 503", http.MethodPost, []int{503, 201}, 2, 201},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				body := make([]byte, 64)
+				n, _ := request.Body.Read(body)
+				if request.Method == http.MethodPost && string(body[:n]) != `{"name":"kongo"}` {
+					t.Errorf("Expected every attempt to send the body, got: %s", body[:n])
+				}
+				writer.WriteHeader(test.statuses[attempts])
+				attempts++
+			}))
+			defer server.Close()
+
+			retries := 0
+			policy := RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, Multiplier: 2, Jitter: 0.5}
+			policy.OnRetry = func(attempt *RetryAttempt) {
+				retries++
+				if attempt.Attempt != retries || attempt.StatusCode != test.statuses[retries-1] {
+					t.Errorf("Unexpected retry: %+v", attempt)
+				}
+			}
+			httpClient := &http.Client{Transport: NewRetryRoundTripper(policy, http.DefaultTransport)}
+
+			request, _ := http.NewRequest(test.method, server.URL, strings.NewReader(`{"name":"kongo"}`))
+			request.GetBody = nil
+			body := request.Body
+			response, err := httpClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+			if request.Body != body {
+				t.Errorf("Expected the body of the request to be left as it is")
+			}
+			if attempts != test.attempts || retries != test.attempts-1 || response.StatusCode != test.status {
+				t.Fatalf("Expected %d attempts ending with %d, got %d attempts ending with %d", test.attempts, test.status, attempts, response.StatusCode)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyConnectionErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	baseUrl := server.URL
+	server.Close()
+
+	retries := 0
+	policy := RetryPolicy{MaxAttempts: 2, InitialDelay: time.Millisecond, Multiplier: 2}
+	policy.OnRetry = func(attempt *RetryAttempt) {
+		retries++
+	}
+	kongo, err := NewKongo(&baseUrl, WithRetryPolicy(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing listens anymore, the connection is refused before Kong could act on the POST.
+	_, err = kongo.CreateUpstream(&UpstreamDef{Name: "kongo-test-upstream"})
+	if err == nil || retries != 1 {
+		t.Fatalf("Expected a refused POST to be retried once and to fail, got %d retries: %v", retries, err)
+	}
+}
@@ -0,0 +1,268 @@
+package client
+
+import (
+	"fmt"
+	"github.com/hbagdi/go-kong/kong"
+	"reflect"
+)
+
+type KongState struct {
+	Services     []*kong.Service
+	Routes       []*kong.Route
+	Upstreams    []*kong.Upstream
+	Targets      []*kong.Target
+	Plugins      []*kong.Plugin
+	Certificates []*kong.Certificate
+}
+
+func (kongo *Kongo) LoadKongState() (*KongState, error) {
+	state := new(KongState)
+
+	upstreams, err := kongo.ListUpstreams()
+	if err != nil {
+		return nil, fmt.Errorf("error listing Upstreams: %v", err)
+	}
+	state.Upstreams = upstreams
+
+	for _, upstream := range upstreams {
+		targets, err := kongo.ListTargets(*upstream.ID)
+		if err != nil {
+			return nil, fmt.Errorf("error listing Targets for Upstream '%s': %v", *upstream.Name, err)
+		}
+		for _, target := range targets {
+			target.Upstream = &kong.Upstream{ID: upstream.ID, Name: upstream.Name}
+		}
+		state.Targets = append(state.Targets, targets...)
+	}
+
+	state.Services, err = kongo.ListServices()
+	if err != nil {
+		return nil, fmt.Errorf("error listing Services: %v", err)
+	}
+
+	state.Routes, err = kongo.ListRoutes()
+	if err != nil {
+		return nil, fmt.Errorf("error listing Routes: %v", err)
+	}
+
+	state.Plugins, err = kongo.ListPlugins()
+	if err != nil {
+		return nil, fmt.Errorf("error listing Plugins: %v", err)
+	}
+
+	state.Certificates, err = kongo.ListCertificates()
+	if err != nil {
+		return nil, fmt.Errorf("error listing Certificates: %v", err)
+	}
+
+	return state, nil
+}
+
+// ApplyKongState creates the entities in the state, updating the ones that already exist by name, and Targets by their
+// Upstream and target. Upstreams and Services are applied before the Targets and Routes that refer to them; Routes may
+// also refer to Services already in Kong.
+func (kongo *Kongo) ApplyKongState(state *KongState) error {
+	upstreamIds := make(map[string]*string)
+	for _, upstream := range state.Upstreams {
+		applied, err := kongo.upsertUpstream(upstream)
+		if err != nil {
+			return fmt.Errorf("error applying Upstream '%s': %v", *upstream.Name, err)
+		}
+		upstreamIds[*upstream.Name] = applied.ID
+	}
+
+	existingTargets := make(map[string]map[string]*kong.Target)
+	for _, target := range state.Targets {
+		upstreamName := *target.Upstream.Name
+		upstreamId, found := upstreamIds[upstreamName]
+		if !found {
+			return fmt.Errorf("Target '%s' refers to unknown Upstream '%s'", *target.Target, upstreamName)
+		}
+		if _, listed := existingTargets[upstreamName]; !listed {
+			targets, err := kongo.ListTargets(*upstreamId)
+			if err != nil {
+				return fmt.Errorf("error listing Targets for Upstream '%s': %v", upstreamName, err)
+			}
+			existingTargets[upstreamName] = make(map[string]*kong.Target)
+			for _, existing := range targets {
+				existingTargets[upstreamName][withDefaultPort(*existing.Target)] = existing
+			}
+		}
+		existing := existingTargets[upstreamName][withDefaultPort(*target.Target)]
+		_, err := kongo.upsertTarget(upstreamName, target, existing)
+		if err != nil {
+			return fmt.Errorf("error applying Target '%s': %v", *target.Target, err)
+		}
+	}
+
+	serviceIds := make(map[string]*string)
+	for _, service := range state.Services {
+		applied, err := kongo.upsertService(service)
+		if err != nil {
+			return fmt.Errorf("error applying Service '%s': %v", *service.Name, err)
+		}
+		serviceIds[*service.Name] = applied.ID
+	}
+
+	for _, route := range state.Routes {
+		kongRoute := *route
+		if route.Service != nil && route.Service.Name != nil {
+			serviceId, found := serviceIds[*route.Service.Name]
+			if !found {
+				existing, err := kongo.GetService(*route.Service.Name)
+				if err != nil {
+					return fmt.Errorf("Route '%s' refers to unknown Service '%s': %v", *route.Name, *route.Service.Name, err)
+				}
+				serviceId = existing.ID
+			}
+			kongRoute.Service = &kong.Service{ID: serviceId}
+		}
+		_, err := kongo.upsertRoute(&kongRoute)
+		if err != nil {
+			return fmt.Errorf("error applying Route '%s': %v", *route.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// upsertTarget creates the Target unless the existing one already has its weight. Targets being immutable, one with
+// another weight is replaced.
+func (kongo *Kongo) upsertTarget(upstreamName string, target *kong.Target, existing *kong.Target) (*kong.Target, error) {
+	kongTarget := *target
+	kongTarget.ID = nil
+	kongTarget.CreatedAt = nil
+	kongTarget.Upstream = nil
+	if existing != nil {
+		weight := 100
+		if kongTarget.Weight != nil {
+			weight = *kongTarget.Weight
+		}
+		if existing.Weight != nil && *existing.Weight == weight {
+			return existing, nil
+		}
+		err := kongo.Kong.Targets.Delete(kongo.context, kong.String(upstreamName), existing.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	created, err := kongo.Kong.Targets.Create(kongo.context, kong.String(upstreamName), &kongTarget)
+	if err == nil {
+		recordDrift(KindTarget, existing, created)
+	}
+	return created, err
+}
+
+func (kongo *Kongo) upsertRoute(route *kong.Route) (*kong.Route, error) {
+	kongRoute := *route
+	kongRoute.CreatedAt = nil
+	kongRoute.UpdatedAt = nil
+	existing, err := kongo.GetRoute(*route.Name)
+	if err != nil {
+		if !kong.IsNotFoundErr(err) {
+			return nil, err
+		}
+		kongRoute.ID = nil
+		created, err := kongo.Kong.Routes.Create(kongo.context, &kongRoute)
+		if err == nil {
+			recordDrift(KindRoute, nil, created)
+		}
+		return created, err
+	}
+	kongRoute.ID = existing.ID
+	if unchanged(existing, &kongRoute) {
+		return existing, nil
+	}
+	updated, err := kongo.Kong.Routes.Update(kongo.context, &kongRoute)
+	if err == nil {
+		recordDrift(KindRoute, existing, updated)
+	}
+	return updated, err
+}
+
+func (kongo *Kongo) upsertService(service *kong.Service) (*kong.Service, error) {
+	kongService := *service
+	kongService.CreatedAt = nil
+	kongService.UpdatedAt = nil
+	existing, err := kongo.GetService(*service.Name)
+	if err != nil {
+		if !kong.IsNotFoundErr(err) {
+			return nil, err
+		}
+		kongService.ID = nil
+		created, err := kongo.Kong.Services.Create(kongo.context, &kongService)
+		if err == nil {
+			recordDrift(KindService, nil, created)
+		}
+		return created, err
+	}
+	kongService.ID = existing.ID
+	if unchanged(existing, &kongService) {
+		return existing, nil
+	}
+	updated, err := kongo.Kong.Services.Update(kongo.context, &kongService)
+	if err == nil {
+		recordDrift(KindService, existing, updated)
+	}
+	return updated, err
+}
+
+func (kongo *Kongo) upsertUpstream(upstream *kong.Upstream) (*kong.Upstream, error) {
+	kongUpstream := *upstream
+	kongUpstream.CreatedAt = nil
+	existing, err := kongo.GetUpstream(*upstream.Name)
+	if err != nil {
+		if !kong.IsNotFoundErr(err) {
+			return nil, err
+		}
+		kongUpstream.ID = nil
+		created, err := kongo.Kong.Upstreams.Create(kongo.context, &kongUpstream)
+		if err == nil {
+			recordDrift(KindUpstream, nil, created)
+		}
+		return created, err
+	}
+	kongUpstream.ID = existing.ID
+	if unchanged(existing, &kongUpstream) {
+		return existing, nil
+	}
+	updated, err := kongo.Kong.Upstreams.Update(kongo.context, &kongUpstream)
+	if err == nil {
+		recordDrift(KindUpstream, existing, updated)
+	}
+	return updated, err
+}
+
+// unchanged tells whether the existing entity already has every field the wanted one sets, Kong filling in defaults
+// for the others. Syncing an entity that is unchanged sends no update.
+func unchanged(existing interface{}, wanted interface{}) bool {
+	existingFields, err := entityFields(existing)
+	if err != nil {
+		return false
+	}
+	wantedFields, err := entityFields(wanted)
+	if err != nil {
+		return false
+	}
+	for _, field := range DefaultIgnoredFields {
+		delete(wantedFields, field)
+	}
+	return fieldsContain(existingFields, wantedFields)
+}
+
+func fieldsContain(fields map[string]interface{}, wanted map[string]interface{}) bool {
+	for key, value := range wanted {
+		nested, isObject := value.(map[string]interface{})
+		existingNested, existingIsObject := fields[key].(map[string]interface{})
+		if isObject && existingIsObject {
+			if !fieldsContain(existingNested, nested) {
+				return false
+			}
+			continue
+		}
+		if !reflect.DeepEqual(fields[key], value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"fmt"
+	"github.com/hbagdi/go-kong/kong"
+	"net"
+	"reflect"
+	"strings"
+)
+
+// IsK8sServiceRegistered looks for an Upstream tagged with the owner of the service, or one named as the Upstream of a
+// single port registered before entities were tagged.
+func (kongo *Kongo) IsK8sServiceRegistered(baseName string) (bool, error) {
+	upstreams, err := kongo.listUpstreamsTagged(K8sServiceOwner(baseName))
+	if err != nil {
+		return false, fmt.Errorf("error listing Upstreams of '%s': %v", baseName, err)
+	}
+	if len(upstreams) > 0 {
+		return true, nil
+	}
+
+	kongNames, err := kongo.KongNames(baseName)
+	if err != nil {
+		return false, err
+	}
+	_, err = kongo.GetUpstream(kongNames.UpstreamName)
+	if err != nil {
+		if kong.IsNotFoundErr(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error loading Upstream '%s': %v", kongNames.UpstreamName, err)
+	}
+	return true, nil
+}
+
+// SyncK8sService creates or updates the entities of every port, bringing the Targets in line with the Addresses and
+// Endpoints. Entities of ports or Routes no longer declared are deleted, and entities registered before they were tagged
+// with their owner are adopted.
+func (kongo *Kongo) SyncK8sService(k8sService *K8sService) (*RegisteredKongResources, error) {
+	var registered *RegisteredKongResources
+	err := kongo.traced("SyncK8sService", serviceAttributes(k8sService.Name), func(kongo *Kongo) error {
+		var err error
+		registered, err = kongo.syncK8sService(k8sService)
+		return err
+	})
+	return registered, err
+}
+
+func (kongo *Kongo) syncK8sService(k8sService *K8sService) (*RegisteredKongResources, error) {
+	resourceSet, err := kongo.k8sServiceResourceSet(k8sService)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := kongo.ApplyResourceSet(K8sServiceOwner(k8sService.Name), resourceSet)
+	registrationsTotal.WithLabelValues(resultLabel(err)).Inc()
+	registered := newRegisteredKongResources(resourceSet, applied)
+	if err != nil {
+		return registered, fmt.Errorf("error syncing '%s': %v", k8sService.Name, err)
+	}
+	return registered, nil
+}
+
+// configContains compares only the keys that were configured, Kong filling in defaults for the others.
+func configContains(config kong.Configuration, configured kong.Configuration) bool {
+	for key, value := range configured {
+		if !reflect.DeepEqual(config[key], value) {
+			return false
+		}
+	}
+	return true
+}
+
+// SyncTargets diffs the Targets of the Upstream against the given ones, creating those missing, deleting those no longer
+// wanted and recreating those whose weight changed. Targets that are already as wanted are left untouched. Deletions,
+// then creations, run in parallel.
+func (kongo *Kongo) SyncTargets(upstream *kong.Upstream, targetDefs []*TargetDef) ([]*kong.Target, error) {
+	existingTargets, err := kongo.ListTargets(*upstream.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing Targets for Upstream '%s': %v", *upstream.Name, err)
+	}
+
+	wanted := make(map[string]*TargetDef)
+	for _, targetDef := range targetDefs {
+		wanted[withDefaultPort(targetDef.Target)] = targetDef
+	}
+
+	targets := []*kong.Target{}
+	deleteTasks := []*Task{}
+	for _, existing := range existingTargets {
+		targetDef, found := wanted[withDefaultPort(*existing.Target)]
+		if found && existing.Weight != nil && *existing.Weight == targetDef.Weight {
+			delete(wanted, withDefaultPort(*existing.Target))
+			targets = append(targets, existing)
+			continue
+		}
+		target := *existing.Target
+		deleteTasks = append(deleteTasks, &Task{Name: "Target " + target, Run: func() error {
+			_, err := kongo.DeleteTarget(NewTargetDef(target, upstream, 0))
+			if err != nil {
+				return fmt.Errorf("error deleting Target '%s': %v", target, err)
+			}
+			driftDetectedTotal.WithLabelValues(KindTarget).Inc()
+			return nil
+		}})
+	}
+	err = kongo.bulk().Run(kongo.context, deleteTasks)
+	if err != nil {
+		return targets, err
+	}
+
+	// Created Targets are returned in the order of their definitions, whatever order they were created in.
+	createdTargets := make([]*kong.Target, len(targetDefs))
+	createTasks := []*Task{}
+	for i, targetDef := range targetDefs {
+		if wanted[withDefaultPort(targetDef.Target)] != targetDef {
+			continue
+		}
+		delete(wanted, withDefaultPort(targetDef.Target))
+		i, targetDef := i, targetDef
+		createTasks = append(createTasks, &Task{Name: "Target " + targetDef.Target, Run: func() error {
+			kongTarget, err := kongo.CreateTarget(NewTargetDef(targetDef.Target, upstream, targetDef.Weight))
+			if err != nil {
+				return fmt.Errorf("error creating Target (%s): %s", targetDef.Target, err)
+			}
+			createdTargets[i] = kongTarget
+			driftDetectedTotal.WithLabelValues(KindTarget).Inc()
+			return nil
+		}})
+	}
+	err = kongo.bulk().Run(kongo.context, createTasks)
+	for _, kongTarget := range createdTargets {
+		if kongTarget != nil {
+			targets = append(targets, kongTarget)
+		}
+	}
+	if err != nil {
+		return targets, err
+	}
+
+	return targets, nil
+}
+
+// defaultTargetPort is the port Kong gives Targets without one.
+const defaultTargetPort = "8000"
+
+// withDefaultPort gives the target as Kong has it, Targets without a port being given defaultTargetPort.
+func withDefaultPort(target string) string {
+	if _, _, err := net.SplitHostPort(target); err == nil {
+		return target
+	}
+	return net.JoinHostPort(strings.Trim(target, "[]"), defaultTargetPort)
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"github.com/ciroque/kongo/client/kongotest"
+	"github.com/hbagdi/go-kong/kong"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSyncK8sServicePorts(t *testing.T) {
+	server := kongotest.NewServer()
+	defer server.Close()
+	kongo, err := NewKongo(&server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registered, err := kongo.SyncK8sService(multiPortService())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectCounts(t, server, map[string]int{"upstreams": 2, "targets": 3, "services": 2, "routes": 3, "plugins": 1})
+
+	// The grpc port and the api Route are no longer declared, and the Addresses changed.
+	k8sService := multiPortService()
+	k8sService.Ports = k8sService.Ports[:1]
+	k8sService.Ports[0].Routes = k8sService.Ports[0].Routes[:1]
+	k8sService.Ports[0].Addresses = kong.StringSlice("10.0.0.1", "10.0.0.4")
+	synced, err := kongo.SyncK8sService(k8sService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectCounts(t, server, map[string]int{"upstreams": 1, "targets": 2, "services": 1, "routes": 1, "plugins": 0})
+	if *synced.Services[0].ID != *registered.Services[0].ID {
+		t.Fatalf("Expected the Service of the remaining port to be kept")
+	}
+	targets, err := kongo.ListTargets(*synced.Upstreams[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range targets {
+		if strings.HasPrefix(*target.Target, "10.0.0.2") {
+			t.Fatalf("Expected the Target of the removed Address to be deleted")
+		}
+	}
+}
+
+func TestSyncK8sServiceUnchanged(t *testing.T) {
+	server := kongotest.NewServer()
+	defer server.Close()
+	var mutex sync.Mutex
+	mutations := []string{}
+	counting := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodGet {
+			mutex.Lock()
+			mutations = append(mutations, request.Method+" "+request.URL.Path)
+			mutex.Unlock()
+		}
+		server.ServeHTTP(writer, request)
+	}))
+	defer counting.Close()
+	kongo, err := NewKongo(&counting.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k8sService := multiPortService()
+	k8sService.Ports[0].Healthchecks = &kong.Healthcheck{Active: &kong.ActiveHealthcheck{HTTPPath: kong.String("/healthz")}}
+	_, err = kongo.SyncK8sService(k8sService)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mutex.Lock()
+	mutations = mutations[:0]
+	mutex.Unlock()
+	_, err = kongo.SyncK8sService(k8sService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mutations) != 0 {
+		t.Fatalf("Expected syncing an unchanged Service to change nothing in Kong, got: %v", mutations)
+	}
+}
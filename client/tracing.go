@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// TracerName names the tracer of the spans of kongo.
+const TracerName = "github.com/ciroque/kongo/client"
+
+// WithTracerProvider traces the operations of the Kongo, and each request to Kong, with the provider rather than the
+// global one.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(settings *kongoSettings) error {
+		settings.tracerProvider = provider
+		return nil
+	}
+}
+
+func newTracer(provider trace.TracerProvider) trace.Tracer {
+	if provider == nil {
+		// The global provider delegates to whichever provider is set later on.
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(TracerName)
+}
+
+// traced runs the operation in a span, on a copy of the Kongo whose requests are traced as children of the span.
+func (kongo *Kongo) traced(name string, attributes []attribute.KeyValue, operation func(kongo *Kongo) error) error {
+	ctx := kongo.context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	tracer := kongo.tracer
+	if tracer == nil {
+		tracer = newTracer(nil)
+	}
+
+	ctx, span := tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+	defer span.End()
+
+	err := operation(kongo.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// startRequestSpan starts the span of a request to Kong, propagating its context to Kong with W3C Trace Context
+// headers. The request returned carries the context of the span and is the one to send.
+func startRequestSpan(tracer trace.Tracer, req *http.Request) (*http.Request, trace.Span) {
+	kind := adminKind(req.URL.Path)
+	ctx, span := tracer.Start(req.Context(), req.Method+" "+kind,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.full", req.URL.String()),
+			attribute.String("kongo.kind", kind),
+		))
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return req.WithContext(ctx), span
+}
+
+func endRequestSpan(span trace.Span, response *http.Response, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(attribute.Int("http.response.status_code", response.StatusCode))
+		if response.StatusCode >= 500 {
+			span.SetStatus(codes.Error, response.Status)
+		}
+	}
+	span.End()
+}
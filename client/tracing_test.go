@@ -0,0 +1,96 @@
+package client
+
+import (
+	"github.com/ciroque/kongo/client/kongotest"
+	"github.com/hbagdi/go-kong/kong"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTracing(t *testing.T) {
+	fake := kongotest.NewServer()
+	defer fake.Close()
+
+	var mutex sync.Mutex
+	traceparents := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		mutex.Lock()
+		traceparents = append(traceparents, request.Header.Get("Traceparent"))
+		mutex.Unlock()
+		fake.ServeHTTP(writer, request)
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	kongo, err := NewKongo(&server.URL, WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = kongo.RegisterK8sService(&K8sService{
+		Addresses: []*string{kong.String("10.0.0.1")},
+		Name:      "kongo.traced",
+		Path:      "/traced",
+		Port:      80,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Ended()
+	root := spans[len(spans)-1]
+	if root.Name() != "RegisterK8sService" || root.Parent().IsValid() {
+		t.Fatalf("Expected the registration to be the root span, got: %s", root.Name())
+	}
+	traceId := root.SpanContext().TraceID().String()
+
+	requests := spans[:len(spans)-1]
+	if len(requests) != len(traceparents) || len(requests) == 0 {
+		t.Fatalf("Expected a span per request, got %d spans for %d requests", len(requests), len(traceparents))
+	}
+	for _, span := range requests {
+		if span.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Fatalf("Expected the span of %s to be a child of the registration", span.Name())
+		}
+	}
+	for _, traceparent := range traceparents {
+		if !strings.HasPrefix(traceparent, "00-"+traceId+"-") {
+			t.Fatalf("Expected requests to propagate the trace %s, got: '%s'", traceId, traceparent)
+		}
+	}
+}
+
+type transportFunc func(*http.Request) (*http.Response, error)
+
+func (f transportFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTracingSendsRequestWithSpanContext(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	var sent trace.SpanContext
+	roundTripper := &KongoRoundTripper{
+		tracer: newTracer(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))),
+		roundTripper: transportFunc(func(req *http.Request) (*http.Response, error) {
+			sent = trace.SpanContextFromContext(req.Context())
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+		}),
+	}
+
+	request, _ := http.NewRequest(http.MethodGet, "http://kong:8001/services", nil)
+	_, err := roundTripper.RoundTrip(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || sent.SpanID() != spans[0].SpanContext().SpanID() {
+		t.Fatalf("Expected the request sent to Kong to carry the context of its span, got: %v", sent.SpanID())
+	}
+}
@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"fmt"
+	"github.com/ciroque/kongo/client"
+	"github.com/hbagdi/go-kong/kong"
+	jsoniter "github.com/json-iterator/go"
+	v1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const annotationPrefix = "kongo.ciroque.io/"
+
+const (
+	AnnotationEnabled                       = annotationPrefix + "enabled"
+	AnnotationPort                          = annotationPrefix + "port"
+	AnnotationPath                          = annotationPrefix + "path"
+	AnnotationStripPath                     = annotationPrefix + "strip-path"
+	AnnotationHosts                         = annotationPrefix + "hosts"
+	AnnotationMethods                       = annotationPrefix + "methods"
+	AnnotationProtocols                     = annotationPrefix + "protocols"
+	AnnotationPlugins                       = annotationPrefix + "plugins"
+	AnnotationUpstreamAlgorithm             = annotationPrefix + "upstream-algorithm"
+	AnnotationHealthcheckPath               = annotationPrefix + "healthcheck-path"
+	AnnotationHealthcheckInterval           = annotationPrefix + "healthcheck-interval"
+	AnnotationHealthcheckTimeout            = annotationPrefix + "healthcheck-timeout"
+	AnnotationHealthcheckHealthyThreshold   = annotationPrefix + "healthcheck-healthy-threshold"
+	AnnotationHealthcheckUnhealthyThreshold = annotationPrefix + "healthcheck-unhealthy-threshold"
+)
+
+// AnnotationPluginConfigPrefix followed by a plugin name listed in AnnotationPlugins holds that plugin's config as a JSON object,
+// e.g. `kongo.ciroque.io/plugin.rate-limiting: '{"minute": 20}'`.
+const AnnotationPluginConfigPrefix = annotationPrefix + "plugin."
+
+var validMethods = map[string]bool{"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "OPTIONS": true, "CONNECT": true, "TRACE": true}
+var validProtocols = map[string]bool{"http": true, "https": true, "grpc": true, "grpcs": true, "tcp": true, "tls": true}
+var validAlgorithms = map[string]bool{"round-robin": true, "consistent-hashing": true, "least-connections": true}
+
+func IsEnabled(service *v1.Service) bool {
+	enabled, err := strconv.ParseBool(service.Annotations[AnnotationEnabled])
+	return err == nil && enabled
+}
+
+// ApplyAnnotations sets the fields of k8sService configured by the annotations. Every invalid annotation is reported
+// in the returned error, in which case k8sService should not be registered.
+func ApplyAnnotations(annotations map[string]string, k8sService *client.K8sService) error {
+	var errs []error
+	invalid := func(annotation string, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("invalid annotation '%s': %s", annotation, fmt.Sprintf(format, args...)))
+	}
+
+	if path, found := annotations[AnnotationPath]; found {
+		if !strings.HasPrefix(path, "/") {
+			invalid(AnnotationPath, "'%s' does not start with '/'", path)
+		}
+		k8sService.Path = path
+	}
+
+	if stripPath, found := annotations[AnnotationStripPath]; found {
+		value, err := strconv.ParseBool(stripPath)
+		if err != nil {
+			invalid(AnnotationStripPath, "'%s' is not a boolean", stripPath)
+		}
+		k8sService.StripPath = value
+	}
+
+	if hosts, found := annotations[AnnotationHosts]; found {
+		k8sService.Hosts = kong.StringSlice(splitList(hosts)...)
+	}
+
+	if methods, found := annotations[AnnotationMethods]; found {
+		for _, method := range splitList(methods) {
+			method = strings.ToUpper(method)
+			if !validMethods[method] {
+				invalid(AnnotationMethods, "'%s' is not an HTTP method", method)
+			}
+			k8sService.Methods = append(k8sService.Methods, kong.String(method))
+		}
+	}
+
+	if protocols, found := annotations[AnnotationProtocols]; found {
+		for _, protocol := range splitList(protocols) {
+			if !validProtocols[protocol] {
+				invalid(AnnotationProtocols, "'%s' is not a protocol supported by Kong", protocol)
+			}
+			k8sService.Protocols = append(k8sService.Protocols, kong.String(protocol))
+		}
+	}
+
+	if plugins, found := annotations[AnnotationPlugins]; found {
+		for _, name := range splitList(plugins) {
+			pluginDef := &client.PluginDef{Name: name}
+			if config, found := annotations[AnnotationPluginConfigPrefix+name]; found {
+				err := jsoniter.UnmarshalFromString(config, &pluginDef.Config)
+				if err != nil {
+					invalid(AnnotationPluginConfigPrefix+name, "not a JSON object: %v", err)
+				}
+			}
+			k8sService.Plugins = append(k8sService.Plugins, pluginDef)
+		}
+	}
+	for annotation := range annotations {
+		if strings.HasPrefix(annotation, AnnotationPluginConfigPrefix) {
+			name := strings.TrimPrefix(annotation, AnnotationPluginConfigPrefix)
+			if !containsString(splitList(annotations[AnnotationPlugins]), name) {
+				invalid(annotation, "plugin '%s' is not listed in '%s'", name, AnnotationPlugins)
+			}
+		}
+	}
+
+	if algorithm, found := annotations[AnnotationUpstreamAlgorithm]; found {
+		if !validAlgorithms[algorithm] {
+			invalid(AnnotationUpstreamAlgorithm, "'%s' is not one of round-robin, consistent-hashing or least-connections", algorithm)
+		}
+		k8sService.Algorithm = algorithm
+	}
+
+	healthchecks, healthcheckErrs := parseHealthchecks(annotations)
+	errs = append(errs, healthcheckErrs...)
+	k8sService.Healthchecks = healthchecks
+
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return utilerrors.NewAggregate(errs)
+}
+
+func parseHealthchecks(annotations map[string]string) (*kong.Healthcheck, []error) {
+	path, found := annotations[AnnotationHealthcheckPath]
+	if !found {
+		for _, annotation := range []string{AnnotationHealthcheckInterval, AnnotationHealthcheckTimeout, AnnotationHealthcheckHealthyThreshold, AnnotationHealthcheckUnhealthyThreshold} {
+			if _, found := annotations[annotation]; found {
+				return nil, []error{fmt.Errorf("invalid annotation '%s': requires '%s'", annotation, AnnotationHealthcheckPath)}
+			}
+		}
+		return nil, nil
+	}
+
+	var errs []error
+	if !strings.HasPrefix(path, "/") {
+		errs = append(errs, fmt.Errorf("invalid annotation '%s': '%s' does not start with '/'", AnnotationHealthcheckPath, path))
+	}
+
+	positiveInt := func(annotation string) *int {
+		value, found := annotations[annotation]
+		if !found {
+			return nil
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 1 {
+			errs = append(errs, fmt.Errorf("invalid annotation '%s': '%s' is not a positive integer", annotation, value))
+			return nil
+		}
+		return kong.Int(number)
+	}
+
+	interval := positiveInt(AnnotationHealthcheckInterval)
+	if interval == nil {
+		interval = kong.Int(10)
+	}
+
+	healthcheck := &kong.Healthcheck{
+		Active: &kong.ActiveHealthcheck{
+			Type:     kong.String("http"),
+			HTTPPath: kong.String(path),
+			Timeout:  positiveInt(AnnotationHealthcheckTimeout),
+			Healthy: &kong.Healthy{
+				Interval:  interval,
+				Successes: positiveInt(AnnotationHealthcheckHealthyThreshold),
+			},
+			Unhealthy: &kong.Unhealthy{
+				Interval:     interval,
+				HTTPFailures: positiveInt(AnnotationHealthcheckUnhealthyThreshold),
+			},
+		},
+	}
+
+	return healthcheck, errs
+}
+
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
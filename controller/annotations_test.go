@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"github.com/ciroque/kongo/client"
+	"strings"
+	"testing"
+)
+
+func TestApplyAnnotations(t *testing.T) {
+	annotations := map[string]string{
+		AnnotationPath:                        "/orders",
+		AnnotationStripPath:                   "true",
+		AnnotationHosts:                       "orders.example.com, api.example.com",
+		AnnotationMethods:                     "get,post",
+		AnnotationProtocols:                   "https",
+		AnnotationPlugins:                     "cors,rate-limiting",
+		AnnotationPluginConfigPrefix + "cors": `{"origins": ["*"]}`,
+		AnnotationUpstreamAlgorithm:           "least-connections",
+		AnnotationHealthcheckPath:             "/healthz",
+		AnnotationHealthcheckInterval:         "5",
+	}
+
+	k8sService := &client.K8sService{Path: "/default"}
+	err := ApplyAnnotations(annotations, k8sService)
+	if err != nil {
+		t.Fatalf("Valid annotations were rejected: %v", err)
+	}
+
+	if k8sService.Path != "/orders" || !k8sService.StripPath || len(k8sService.Hosts) != 2 || *k8sService.Methods[1] != "POST" {
+		t.Fatalf("The Route annotations were not applied: %+v", k8sService)
+	}
+	if len(k8sService.Plugins) != 2 || k8sService.Plugins[0].Config["origins"] == nil || k8sService.Plugins[1].Config != nil {
+		t.Fatalf("The Plugin annotations were not applied: %+v", k8sService.Plugins)
+	}
+	if k8sService.Algorithm != "least-connections" || *k8sService.Healthchecks.Active.HTTPPath != "/healthz" || *k8sService.Healthchecks.Active.Healthy.Interval != 5 {
+		t.Fatalf("The Upstream annotations were not applied: %+v", k8sService)
+	}
+}
+
+func TestApplyAnnotationsReportsEveryInvalidAnnotation(t *testing.T) {
+	annotations := map[string]string{
+		AnnotationPath:                          "orders",
+		AnnotationMethods:                       "FETCH",
+		AnnotationPluginConfigPrefix + "cors":   `{"origins": ["*"]}`,
+		AnnotationHealthcheckUnhealthyThreshold: "3",
+	}
+
+	err := ApplyAnnotations(annotations, &client.K8sService{})
+	if err == nil {
+		t.Fatalf("Invalid annotations should be rejected")
+	}
+
+	for _, annotation := range []string{AnnotationPath, AnnotationMethods, AnnotationPluginConfigPrefix + "cors", AnnotationHealthcheckUnhealthyThreshold} {
+		if !strings.Contains(err.Error(), annotation) {
+			t.Fatalf("'%s' should have been reported in: %v", annotation, err)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"github.com/ciroque/kongo/client"
+	"github.com/hbagdi/go-kong/kong"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"log/slog"
+	"path"
+	"time"
+)
+
+// Registrar is the part of client.Kongo the controller drives.
+type Registrar interface {
+	SyncK8sService(k8sService *client.K8sService) (*client.RegisteredKongResources, error)
+	IsK8sServiceRegistered(baseName string) (bool, error)
+	DeregisterK8sService(baseName string) error
+	ListKongEntities() ([]*client.KongEntity, error)
+	DeleteKongEntity(entity *client.KongEntity) error
+}
+
+type Config struct {
+	Namespace    string
+	ResyncPeriod time.Duration
+	Workers      int
+	// NotReadyPolicy defaults to NotReadyDrain.
+	NotReadyPolicy NotReadyPolicy
+	// IngressClass defaults to DefaultIngressClass.
+	IngressClass string
+	// FullResyncInterval defaults to DefaultFullResyncInterval, a negative interval disabling the full resync.
+	FullResyncInterval time.Duration
+	// GCGracePeriod is how long a Kong entity has to be seen orphaned before it is deleted, defaulting to
+	// DefaultGCGracePeriod.
+	GCGracePeriod time.Duration
+	// GCUntagged also collects the untagged entities named after Services that are gone, as kongo registered them before
+	// tagging entities with their owner. Entities made by hand under such names are collected along with them.
+	GCUntagged bool
+	// NamingStrategy names the Kong entities of Ingresses and KongoRoutes, defaulting to client.DefaultNamingStrategy.
+	// It is expected to be the naming strategy of the client.Kongo, which names those of Services.
+	NamingStrategy client.NamingStrategy
+	// Recorder receives the Events about Services, one recording to the API server is created when nil.
+	Recorder record.EventRecorder
+	// Logger defaults to slog.Default(), and is expected to be the logger of the client.Kongo.
+	Logger client.Logger
+}
+
+type Controller struct {
+	registrar           Registrar
+	config              Config
+	informerFactory     informers.SharedInformerFactory
+	serviceLister       corelisters.ServiceLister
+	endpointSliceLister discoverylisters.EndpointSliceLister
+	informersSynced     []cache.InformerSynced
+	recorder            record.EventRecorder
+	queue               *keyQueue
+	// orphans holds when the Kong entities, by ID, were first seen without their Service.
+	orphans map[string]time.Time
+}
+
+func NewController(registrar Registrar, kubeClient kubernetes.Interface, config Config) *Controller {
+	config = config.withLogger()
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	if config.NotReadyPolicy == "" {
+		config.NotReadyPolicy = NotReadyDrain
+	}
+	if config.FullResyncInterval == 0 {
+		config.FullResyncInterval = DefaultFullResyncInterval
+	}
+	if config.GCGracePeriod == 0 {
+		config.GCGracePeriod = DefaultGCGracePeriod
+	}
+
+	recorder := config.Recorder
+	if recorder == nil {
+		recorder = newEventRecorder(kubeClient)
+	}
+
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(kubeClient, config.ResyncPeriod, informers.WithNamespace(config.Namespace))
+	serviceInformer := informerFactory.Core().V1().Services()
+	endpointSliceInformer := informerFactory.Discovery().V1().EndpointSlices()
+
+	controller := &Controller{
+		registrar:           registrar,
+		config:              config,
+		informerFactory:     informerFactory,
+		serviceLister:       serviceInformer.Lister(),
+		endpointSliceLister: endpointSliceInformer.Lister(),
+		informersSynced:     []cache.InformerSynced{serviceInformer.Informer().HasSynced, endpointSliceInformer.Informer().HasSynced},
+		recorder:            recorder,
+		orphans:             make(map[string]time.Time),
+	}
+	controller.queue = newKeyQueue("kongo", controller.sync)
+
+	serviceInformer.Informer().AddEventHandler(controller.queue.eventHandler())
+	endpointSliceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    controller.enqueueEndpointSlice,
+		UpdateFunc: func(_, newObj interface{}) { controller.enqueueEndpointSlice(newObj) },
+		DeleteFunc: controller.enqueueEndpointSlice,
+	})
+
+	return controller
+}
+
+// Run blocks until the context is cancelled.
+func (controller *Controller) Run(ctx context.Context) error {
+	defer utilruntime.HandleCrash()
+
+	controller.informerFactory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), controller.informersSynced...) {
+		return fmt.Errorf("timed out waiting for the informer caches to sync")
+	}
+
+	controller.config.Logger.Info("kongo controller started", "workers", controller.config.Workers)
+	if controller.config.FullResyncInterval > 0 {
+		go controller.runFullResync(ctx)
+	}
+	controller.queue.run(ctx, controller.config.Workers)
+	controller.config.Logger.Info("kongo controller stopping")
+	return nil
+}
+
+func (controller *Controller) enqueueEndpointSlice(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	endpointSlice, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return
+	}
+	serviceName, found := endpointSlice.Labels[discoveryv1.LabelServiceName]
+	if !found {
+		return
+	}
+	controller.queue.add(endpointSlice.Namespace + "/" + serviceName)
+}
+
+func (controller *Controller) sync(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	baseName := BaseName(namespace, name)
+
+	service, err := controller.serviceLister.Services(namespace).Get(name)
+	if errors.IsNotFound(err) || (err == nil && !isRegistrable(service)) {
+		return controller.deregister(baseName)
+	}
+	if err != nil {
+		return err
+	}
+
+	servicePorts, err := selectServicePorts(service)
+	if err != nil {
+		controller.recorder.Eventf(service, v1.EventTypeWarning, "InvalidAnnotations", "Not registered with Kong: %v", err)
+		return nil
+	}
+
+	endpointSlices, err := controller.endpointSliceLister.EndpointSlices(service.Namespace).List(serviceSelector(service.Name))
+	if err != nil {
+		return err
+	}
+
+	k8sService := controller.k8sService(service, servicePorts[0], endpointSlices)
+	err = ApplyAnnotations(service.Annotations, k8sService)
+	if err != nil {
+		// Retrying cannot help until the Service itself is updated, which queues it again.
+		controller.recorder.Eventf(service, v1.EventTypeWarning, "InvalidAnnotations", "Not registered with Kong: %v", err)
+		return nil
+	}
+	k8sService.Ports = controller.k8sServicePorts(k8sService, servicePorts[1:], endpointSlices)
+
+	_, err = controller.registrar.SyncK8sService(k8sService)
+	if err != nil {
+		controller.recorder.Eventf(service, v1.EventTypeWarning, "SyncFailed", "Registration with Kong failed: %v", err)
+		return fmt.Errorf("error registering '%s': %v", baseName, err)
+	}
+	return nil
+}
+
+func (controller *Controller) deregister(baseName string) error {
+	registered, err := controller.registrar.IsK8sServiceRegistered(baseName)
+	if err != nil || !registered {
+		return err
+	}
+
+	controller.config.Logger.Info("deregistering Kubernetes Service", "service", baseName)
+	return controller.registrar.DeregisterK8sService(baseName)
+}
+
+func (controller *Controller) k8sService(service *v1.Service, servicePort v1.ServicePort, endpointSlices []*discoveryv1.EndpointSlice) *client.K8sService {
+	return &client.K8sService{
+		Endpoints: k8sEndpoints(endpointSlices, servicePort, controller.config.NotReadyPolicy),
+		Name:      BaseName(service.Namespace, service.Name),
+		Path:      "/" + service.Namespace + "/" + service.Name,
+		Port:      int(servicePort.Port),
+	}
+}
+
+// k8sServicePorts adds the other ports of a Service to the annotated port the K8sService describes, which keeps the Kong
+// names and path it would have alone. The other ports take its annotations, named and routed after their port name.
+func (controller *Controller) k8sServicePorts(k8sService *client.K8sService, otherPorts []v1.ServicePort, endpointSlices []*discoveryv1.EndpointSlice) []*client.K8sServicePort {
+	if len(otherPorts) == 0 {
+		return nil
+	}
+
+	first := k8sService.ServicePorts()[0]
+	ports := []*client.K8sServicePort{first}
+	for _, servicePort := range otherPorts {
+		port := *first
+		port.Name = servicePort.Name
+		port.Port = int(servicePort.Port)
+		port.Path = path.Join(first.Path, servicePort.Name)
+		port.Endpoints = k8sEndpoints(endpointSlices, servicePort, controller.config.NotReadyPolicy)
+
+		route := *first.Routes[0]
+		route.Paths = kong.StringSlice(port.Path)
+		port.Routes = []*client.K8sRoute{&route}
+		ports = append(ports, &port)
+	}
+	return ports
+}
+
+func (config Config) withLogger() Config {
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	return config
+}
+
+// BaseName is the name handed to client.Kongo for a Kubernetes Service.
+func BaseName(namespace string, name string) string {
+	return namespace + "." + name
+}
+
+func serviceSelector(serviceName string) labels.Selector {
+	return labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: serviceName})
+}
+
+func isRegistrable(service *v1.Service) bool {
+	return IsEnabled(service) && service.Spec.Type != v1.ServiceTypeExternalName && len(service.Spec.Ports) > 0
+}
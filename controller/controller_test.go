@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"context"
+	"github.com/ciroque/kongo/client"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeRegistrar struct {
+	mutex      sync.Mutex
+	registered map[string]*client.K8sService
+	entities   []*client.KongEntity
+}
+
+func newFakeRegistrar() *fakeRegistrar {
+	return &fakeRegistrar{registered: make(map[string]*client.K8sService)}
+}
+
+func (registrar *fakeRegistrar) SyncK8sService(k8sService *client.K8sService) (*client.RegisteredKongResources, error) {
+	registrar.mutex.Lock()
+	defer registrar.mutex.Unlock()
+	registrar.registered[k8sService.Name] = k8sService
+	return &client.RegisteredKongResources{}, nil
+}
+
+func (registrar *fakeRegistrar) IsK8sServiceRegistered(baseName string) (bool, error) {
+	registrar.mutex.Lock()
+	defer registrar.mutex.Unlock()
+	_, found := registrar.registered[baseName]
+	return found, nil
+}
+
+func (registrar *fakeRegistrar) DeregisterK8sService(baseName string) error {
+	registrar.mutex.Lock()
+	defer registrar.mutex.Unlock()
+	delete(registrar.registered, baseName)
+	return nil
+}
+
+func (registrar *fakeRegistrar) ListKongEntities() ([]*client.KongEntity, error) {
+	registrar.mutex.Lock()
+	defer registrar.mutex.Unlock()
+	return append([]*client.KongEntity{}, registrar.entities...), nil
+}
+
+func (registrar *fakeRegistrar) DeleteKongEntity(entity *client.KongEntity) error {
+	registrar.mutex.Lock()
+	defer registrar.mutex.Unlock()
+	for i, existing := range registrar.entities {
+		if existing.ID == entity.ID {
+			registrar.entities = append(registrar.entities[:i], registrar.entities[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (registrar *fakeRegistrar) get(baseName string) *client.K8sService {
+	registrar.mutex.Lock()
+	defer registrar.mutex.Unlock()
+	return registrar.registered[baseName]
+}
+
+func eventually(t *testing.T, description string, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for: %s", description)
+}
+
+func newService(namespace string, name string) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   namespace,
+			Name:        name,
+			Annotations: map[string]string{AnnotationEnabled: "true"},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Name: "http", Port: 80}},
+		},
+	}
+}
+
+func newEndpointSlice(namespace string, serviceName string, port int32, addresses ...string) *discoveryv1.EndpointSlice {
+	portName := "http"
+	endpointSlice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      serviceName + "-abcde",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: serviceName},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports:       []discoveryv1.EndpointPort{{Name: &portName, Port: &port}},
+	}
+	for _, address := range addresses {
+		endpointSlice.Endpoints = append(endpointSlice.Endpoints, discoveryv1.Endpoint{Addresses: []string{address}})
+	}
+	return endpointSlice
+}
+
+func TestControllerRegistersServices(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	kubeClient := fake.NewSimpleClientset()
+	registrar := newFakeRegistrar()
+	controller := NewController(registrar, kubeClient, Config{Recorder: record.NewFakeRecorder(10)})
+	go controller.Run(ctx)
+
+	_, err := kubeClient.CoreV1().Services("kongo").Create(ctx, newService("kongo", "orders"), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = kubeClient.DiscoveryV1().EndpointSlices("kongo").Create(ctx, newEndpointSlice("kongo", "orders", 8080, "10.0.0.1"), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, "the Service to be registered with its endpoint", func() bool {
+		k8sService := registrar.get("kongo.orders")
+		return k8sService != nil && len(k8sService.Endpoints) == 1 && k8sService.Endpoints[0].Target() == "10.0.0.1:8080"
+	})
+
+	_, err = kubeClient.DiscoveryV1().EndpointSlices("kongo").Update(ctx, newEndpointSlice("kongo", "orders", 8080, "10.0.0.1", "10.0.0.2"), metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, "the Targets to follow the endpoints", func() bool {
+		k8sService := registrar.get("kongo.orders")
+		return k8sService != nil && len(k8sService.Endpoints) == 2
+	})
+
+	err = kubeClient.CoreV1().Services("kongo").Delete(ctx, "orders", metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, "the Service to be deregistered", func() bool {
+		return registrar.get("kongo.orders") == nil
+	})
+}
+
+func TestControllerReportsInvalidAnnotations(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	kubeClient := fake.NewSimpleClientset()
+	registrar := newFakeRegistrar()
+	recorder := record.NewFakeRecorder(10)
+	controller := NewController(registrar, kubeClient, Config{Recorder: recorder})
+	go controller.Run(ctx)
+
+	service := newService("kongo", "billing")
+	service.Annotations[AnnotationUpstreamAlgorithm] = "random"
+	_, err := kubeClient.CoreV1().Services("kongo").Create(ctx, service, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, "InvalidAnnotations") || !strings.Contains(event, AnnotationUpstreamAlgorithm) {
+			t.Fatalf("Unexpected event: %s", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the invalid annotation Event")
+	}
+
+	if registrar.get("kongo.billing") != nil {
+		t.Fatalf("A Service with invalid annotations should not be registered")
+	}
+}
+
+func TestControllerRegistersEveryPort(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	kubeClient := fake.NewSimpleClientset()
+	registrar := newFakeRegistrar()
+	controller := NewController(registrar, kubeClient, Config{Recorder: record.NewFakeRecorder(10)})
+	go controller.Run(ctx)
+
+	service := newService("kongo", "catalog")
+	service.Annotations[AnnotationHosts] = "catalog.example.com"
+	service.Spec.Ports = append(service.Spec.Ports, v1.ServicePort{Name: "grpc", Port: 9090})
+	_, err := kubeClient.CoreV1().Services("kongo").Create(ctx, service, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, "the Service to be registered with both ports", func() bool {
+		k8sService := registrar.get("kongo.catalog")
+		return k8sService != nil && len(k8sService.Ports) == 2
+	})
+	ports := registrar.get("kongo.catalog").Ports
+	if ports[0].Name != "" || ports[0].Port != 80 || *ports[0].Routes[0].Paths[0] != "/kongo/catalog" {
+		t.Fatalf("The first port should keep the names and path of a single port, got: %+v", ports[0])
+	}
+	grpc := ports[1]
+	if grpc.Name != "grpc" || grpc.Port != 9090 || *grpc.Routes[0].Paths[0] != "/kongo/catalog/grpc" {
+		t.Fatalf("The other port should be named and routed after its port name, got: %+v", grpc)
+	}
+	if len(grpc.Routes[0].Hosts) != 1 || *grpc.Routes[0].Hosts[0] != "catalog.example.com" {
+		t.Fatalf("The other port should take the annotations of the Service")
+	}
+}
@@ -0,0 +1,507 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ciroque/kongo/apis/kongo/v1alpha1"
+	"github.com/ciroque/kongo/client"
+	"github.com/hbagdi/go-kong/kong"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"sort"
+	"strings"
+)
+
+// ResourceSetApplier is the part of client.Kongo the CRDController drives.
+type ResourceSetApplier interface {
+	ApplyResourceSet(owner string, resourceSet *client.ResourceSet) (*client.AppliedResourceSet, error)
+	DeleteResourceSet(owner string) error
+}
+
+// CRDController reconciles KongoRoutes, along with the KongoPlugins and KongoUpstreamPolicy they name, into Kong.
+// The status of a KongoRoute reports the IDs of its Kong entities; the status of a KongoPlugin or KongoUpstreamPolicy
+// gathers those of the KongoRoutes using it.
+type CRDController struct {
+	applier         ResourceSetApplier
+	dynamicClient   dynamic.Interface
+	config          Config
+	informerFactory dynamicinformer.DynamicSharedInformerFactory
+	routeLister     cache.GenericLister
+	pluginLister    cache.GenericLister
+	policyLister    cache.GenericLister
+	informersSynced []cache.InformerSynced
+	routeQueue      *keyQueue
+	pluginQueue     *keyQueue
+	policyQueue     *keyQueue
+}
+
+func NewCRDController(applier ResourceSetApplier, dynamicClient dynamic.Interface, config Config) *CRDController {
+	config = config.withLogger()
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	if config.NamingStrategy == nil {
+		config.NamingStrategy = client.DefaultNamingStrategy
+	}
+
+	informerFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, config.ResyncPeriod, config.Namespace, nil)
+	routeInformer := informerFactory.ForResource(v1alpha1.KongoRouteResource)
+	pluginInformer := informerFactory.ForResource(v1alpha1.KongoPluginResource)
+	policyInformer := informerFactory.ForResource(v1alpha1.KongoUpstreamPolicyResource)
+
+	controller := &CRDController{
+		applier:         applier,
+		dynamicClient:   dynamicClient,
+		config:          config,
+		informerFactory: informerFactory,
+		routeLister:     routeInformer.Lister(),
+		pluginLister:    pluginInformer.Lister(),
+		policyLister:    policyInformer.Lister(),
+		informersSynced: []cache.InformerSynced{
+			routeInformer.Informer().HasSynced,
+			pluginInformer.Informer().HasSynced,
+			policyInformer.Informer().HasSynced,
+		},
+	}
+	controller.routeQueue = newKeyQueue("kongo-route", controller.syncRoute)
+	controller.pluginQueue = newKeyQueue("kongo-plugin", controller.syncPluginStatus)
+	controller.policyQueue = newKeyQueue("kongo-upstream-policy", controller.syncPolicyStatus)
+
+	routeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.routeChanged,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			controller.enqueueRouteReferences(oldObj)
+			if generationChanged(oldObj, newObj) {
+				controller.routeChanged(newObj)
+			} else {
+				controller.enqueueRouteReferences(newObj)
+			}
+		},
+		DeleteFunc: controller.routeChanged,
+	})
+	pluginInformer.Informer().AddEventHandler(controller.referencingRoutesHandler(controller.pluginQueue, func(route *v1alpha1.KongoRoute, name string) bool {
+		return containsString(route.Spec.Plugins, name)
+	}))
+	policyInformer.Informer().AddEventHandler(controller.referencingRoutesHandler(controller.policyQueue, func(route *v1alpha1.KongoRoute, name string) bool {
+		return route.Spec.UpstreamPolicy == name
+	}))
+
+	return controller
+}
+
+// Run blocks until the context is cancelled.
+func (controller *CRDController) Run(ctx context.Context) error {
+	defer utilruntime.HandleCrash()
+
+	controller.informerFactory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), controller.informersSynced...) {
+		return fmt.Errorf("timed out waiting for the informer caches to sync")
+	}
+
+	controller.config.Logger.Info("kongo CRD controller started", "workers", controller.config.Workers)
+	go controller.pluginQueue.run(ctx, 1)
+	go controller.policyQueue.run(ctx, 1)
+	controller.routeQueue.run(ctx, controller.config.Workers)
+	controller.config.Logger.Info("kongo CRD controller stopping")
+	return nil
+}
+
+func (controller *CRDController) routeChanged(obj interface{}) {
+	controller.routeQueue.addObject(obj)
+	controller.enqueueRouteReferences(obj)
+}
+
+// enqueueRouteReferences queues the KongoPlugins and KongoUpstreamPolicy of the route for a status refresh.
+func (controller *CRDController) enqueueRouteReferences(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	route := new(v1alpha1.KongoRoute)
+	if fromUnstructured(obj, route) != nil {
+		return
+	}
+	for _, plugin := range route.Spec.Plugins {
+		controller.pluginQueue.add(route.Namespace + "/" + plugin)
+	}
+	if route.Spec.UpstreamPolicy != "" {
+		controller.policyQueue.add(route.Namespace + "/" + route.Spec.UpstreamPolicy)
+	}
+}
+
+// referencingRoutesHandler queues the object for a status refresh and, unless only its status changed, the
+// KongoRoutes of its namespace referring to it by name.
+func (controller *CRDController) referencingRoutesHandler(statusQueue *keyQueue, references func(route *v1alpha1.KongoRoute, name string) bool) cache.ResourceEventHandlerFuncs {
+	enqueue := func(obj interface{}) {
+		key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+		if err != nil {
+			return
+		}
+		statusQueue.add(key)
+		namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+		routes, err := controller.listRoutes(namespace)
+		if err != nil {
+			return
+		}
+		for _, route := range routes {
+			if references(route, name) {
+				controller.routeQueue.add(route.Namespace + "/" + route.Name)
+			}
+		}
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if generationChanged(oldObj, newObj) {
+				enqueue(newObj)
+			}
+		},
+		DeleteFunc: enqueue,
+	}
+}
+
+func (controller *CRDController) syncRoute(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	owner := KongoRouteOwner(namespace, name)
+
+	route := new(v1alpha1.KongoRoute)
+	err = controller.get(controller.routeLister, namespace, name, route)
+	if errors.IsNotFound(err) {
+		return controller.applier.DeleteResourceSet(owner)
+	}
+	if err != nil {
+		return err
+	}
+
+	status := v1alpha1.KongoRouteStatus{ObservedGeneration: route.Generation}
+	resourceSet, pluginNames, err := controller.translateKongoRoute(route)
+	if err != nil {
+		status.SyncError = err.Error()
+		return controller.updateRouteStatus(route, status)
+	}
+
+	applied, syncErr := controller.applier.ApplyResourceSet(owner, resourceSet)
+	if applied != nil {
+		kongNames, _ := KongoRouteNames(controller.config.NamingStrategy, namespace, name)
+		status.UpstreamID = idOf(applied.Upstreams[kongNames.UpstreamName])
+		status.ServiceID = idOf(applied.Services[kongNames.ServiceName])
+		status.RouteID = idOf(applied.Routes[kongNames.RouteName])
+		for i, plugin := range applied.Plugins {
+			if status.PluginIDs == nil {
+				status.PluginIDs = make(map[string]string)
+			}
+			status.PluginIDs[pluginNames[i]] = *plugin.ID
+		}
+	}
+	if syncErr != nil {
+		status.SyncError = syncErr.Error()
+	}
+
+	err = controller.updateRouteStatus(route, status)
+	if syncErr != nil {
+		return fmt.Errorf("error syncing KongoRoute '%s': %v", key, syncErr)
+	}
+	return err
+}
+
+// translateKongoRoute resolves the KongoPlugins and KongoUpstreamPolicy of the route, returning the names of the
+// KongoPlugins in the order of the Plugins of the set.
+func (controller *CRDController) translateKongoRoute(route *v1alpha1.KongoRoute) (*client.ResourceSet, []string, error) {
+	plugins := []*v1alpha1.KongoPlugin{}
+	for _, name := range route.Spec.Plugins {
+		plugin := new(v1alpha1.KongoPlugin)
+		err := controller.get(controller.pluginLister, route.Namespace, name, plugin)
+		if err != nil {
+			return nil, nil, fmt.Errorf("KongoPlugin '%s': %v", name, err)
+		}
+		plugins = append(plugins, plugin)
+	}
+
+	var policy *v1alpha1.KongoUpstreamPolicy
+	if route.Spec.UpstreamPolicy != "" {
+		policy = new(v1alpha1.KongoUpstreamPolicy)
+		err := controller.get(controller.policyLister, route.Namespace, route.Spec.UpstreamPolicy, policy)
+		if err != nil {
+			return nil, nil, fmt.Errorf("KongoUpstreamPolicy '%s': %v", route.Spec.UpstreamPolicy, err)
+		}
+	}
+
+	resourceSet, err := TranslateKongoRoute(controller.config.NamingStrategy, route, plugins, policy)
+	return resourceSet, route.Spec.Plugins, err
+}
+
+func (controller *CRDController) updateRouteStatus(route *v1alpha1.KongoRoute, status v1alpha1.KongoRouteStatus) error {
+	if equality.Semantic.DeepEqual(route.Status, status) {
+		return nil
+	}
+	route.Status = status
+	return controller.updateStatus(v1alpha1.KongoRouteResource, route)
+}
+
+func (controller *CRDController) syncPluginStatus(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	plugin := new(v1alpha1.KongoPlugin)
+	err = controller.get(controller.pluginLister, namespace, name, plugin)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	routes, err := controller.listRoutes(namespace)
+	if err != nil {
+		return err
+	}
+
+	status := v1alpha1.KongoPluginStatus{}
+	syncErrors := []string{}
+	for _, route := range routes {
+		if !containsString(route.Spec.Plugins, name) {
+			continue
+		}
+		if id, found := route.Status.PluginIDs[name]; found {
+			status.PluginIDs = append(status.PluginIDs, id)
+		}
+		if route.Status.SyncError != "" {
+			syncErrors = append(syncErrors, route.Name+": "+route.Status.SyncError)
+		}
+	}
+	sort.Strings(status.PluginIDs)
+	status.SyncError = strings.Join(syncErrors, "; ")
+
+	if equality.Semantic.DeepEqual(plugin.Status, status) {
+		return nil
+	}
+	plugin.Status = status
+	return controller.updateStatus(v1alpha1.KongoPluginResource, plugin)
+}
+
+func (controller *CRDController) syncPolicyStatus(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	policy := new(v1alpha1.KongoUpstreamPolicy)
+	err = controller.get(controller.policyLister, namespace, name, policy)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	routes, err := controller.listRoutes(namespace)
+	if err != nil {
+		return err
+	}
+
+	status := v1alpha1.KongoUpstreamPolicyStatus{}
+	syncErrors := []string{}
+	for _, route := range routes {
+		if route.Spec.UpstreamPolicy != name {
+			continue
+		}
+		if route.Status.UpstreamID != "" {
+			status.UpstreamIDs = append(status.UpstreamIDs, route.Status.UpstreamID)
+		}
+		if route.Status.SyncError != "" {
+			syncErrors = append(syncErrors, route.Name+": "+route.Status.SyncError)
+		}
+	}
+	sort.Strings(status.UpstreamIDs)
+	status.SyncError = strings.Join(syncErrors, "; ")
+
+	if equality.Semantic.DeepEqual(policy.Status, status) {
+		return nil
+	}
+	policy.Status = status
+	return controller.updateStatus(v1alpha1.KongoUpstreamPolicyResource, policy)
+}
+
+func (controller *CRDController) get(lister cache.GenericLister, namespace string, name string, into interface{}) error {
+	obj, err := lister.ByNamespace(namespace).Get(name)
+	if err != nil {
+		return err
+	}
+	return fromUnstructured(obj, into)
+}
+
+func (controller *CRDController) listRoutes(namespace string) ([]*v1alpha1.KongoRoute, error) {
+	objs, err := controller.routeLister.ByNamespace(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	routes := []*v1alpha1.KongoRoute{}
+	for _, obj := range objs {
+		route := new(v1alpha1.KongoRoute)
+		if err := fromUnstructured(obj, route); err != nil {
+			return nil, err
+		}
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Name < routes[j].Name })
+	return routes, nil
+}
+
+func (controller *CRDController) updateStatus(resource schema.GroupVersionResource, obj metav1.Object) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	_, err = controller.dynamicClient.Resource(resource).Namespace(obj.GetNamespace()).
+		UpdateStatus(context.Background(), &unstructured.Unstructured{Object: content}, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("error updating the status of '%s/%s': %v", obj.GetNamespace(), obj.GetName(), err)
+	}
+	return nil
+}
+
+func fromUnstructured(obj interface{}, into interface{}) error {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("unexpected object type %T", obj)
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), into)
+}
+
+func generationChanged(oldObj interface{}, newObj interface{}) bool {
+	oldMeta, oldOk := oldObj.(metav1.Object)
+	newMeta, newOk := newObj.(metav1.Object)
+	return !oldOk || !newOk || oldMeta.GetGeneration() != newMeta.GetGeneration()
+}
+
+func idOf(entity interface{}) string {
+	switch entity := entity.(type) {
+	case *kong.Upstream:
+		if entity != nil && entity.ID != nil {
+			return *entity.ID
+		}
+	case *kong.Service:
+		if entity != nil && entity.ID != nil {
+			return *entity.ID
+		}
+	case *kong.Route:
+		if entity != nil && entity.ID != nil {
+			return *entity.ID
+		}
+	}
+	return ""
+}
+
+// KongoRouteOwner is the tag marking the Kong entities created for a KongoRoute.
+func KongoRouteOwner(namespace string, name string) string {
+	return "kongo-route:" + namespace + ":" + name
+}
+
+func KongoRouteNames(naming client.NamingStrategy, namespace string, name string) (*client.KongNames, error) {
+	return client.NamesFor(naming, client.NameComponents{Namespace: namespace, Name: name + ".kongoroute"})
+}
+
+// TranslateKongoRoute maps the route onto an Upstream targeting the backend through cluster DNS, a Service on that
+// Upstream and a Route carrying the Plugins. The policy, when given, configures the Upstream.
+func TranslateKongoRoute(naming client.NamingStrategy, route *v1alpha1.KongoRoute, plugins []*v1alpha1.KongoPlugin, policy *v1alpha1.KongoUpstreamPolicy) (*client.ResourceSet, error) {
+	if len(route.Spec.Paths) == 0 {
+		return nil, fmt.Errorf("at least one path is required")
+	}
+	backend := route.Spec.Backend
+	if backend.ServiceName == "" || backend.ServicePort < 1 {
+		return nil, fmt.Errorf("the backend needs a serviceName and a servicePort")
+	}
+
+	kongNames, err := KongoRouteNames(naming, route.Namespace, route.Name)
+	if err != nil {
+		return nil, err
+	}
+	upstreamDef := &client.UpstreamDef{Name: kongNames.UpstreamName}
+	if policy != nil {
+		upstreamDef.Algorithm = policy.Spec.Algorithm
+		if healthcheck := policy.Spec.Healthcheck; healthcheck != nil {
+			upstreamDef.Healthchecks = kongHealthcheck(healthcheck)
+		}
+	}
+
+	upstream := &kong.Upstream{Name: kong.String(kongNames.UpstreamName)}
+	service := &kong.Service{Name: kong.String(kongNames.ServiceName)}
+	resourceSet := &client.ResourceSet{
+		Upstreams: []*client.UpstreamDef{upstreamDef},
+		Targets: []*client.TargetDef{
+			client.NewTargetDef(fmt.Sprintf("%s.%s.svc:%d", backend.ServiceName, route.Namespace, backend.ServicePort), upstream, ReadyTargetWeight),
+		},
+		Services: []*client.ServiceDef{{Name: kongNames.ServiceName, Host: kongNames.UpstreamName, Port: int(backend.ServicePort)}},
+		Routes: []*client.RouteDef{{
+			Name:      kongNames.RouteName,
+			Paths:     kong.StringSlice(route.Spec.Paths...),
+			Service:   service,
+			StripPath: route.Spec.StripPath,
+			Hosts:     kong.StringSlice(route.Spec.Hosts...),
+			Methods:   kong.StringSlice(route.Spec.Methods...),
+			Protocols: kong.StringSlice(route.Spec.Protocols...),
+		}},
+	}
+
+	for _, plugin := range plugins {
+		config := kong.Configuration{}
+		if plugin.Spec.Config != nil {
+			err := json.Unmarshal(plugin.Spec.Config.Raw, &config)
+			if err != nil {
+				return nil, fmt.Errorf("KongoPlugin '%s' config is not a JSON object: %v", plugin.Name, err)
+			}
+		}
+		resourceSet.Plugins = append(resourceSet.Plugins, &client.PluginDef{
+			Name:   plugin.Spec.Plugin,
+			Config: config,
+			Route:  &kong.Route{Name: kong.String(kongNames.RouteName)},
+		})
+	}
+
+	return resourceSet, nil
+}
+
+// kongHealthcheck mirrors the healthcheck annotations, probing every 10 seconds unless told otherwise.
+func kongHealthcheck(healthcheck *v1alpha1.KongoHealthcheck) *kong.Healthcheck {
+	positiveInt := func(value int) *int {
+		if value < 1 {
+			return nil
+		}
+		return kong.Int(value)
+	}
+
+	interval := positiveInt(healthcheck.Interval)
+	if interval == nil {
+		interval = kong.Int(10)
+	}
+
+	return &kong.Healthcheck{
+		Active: &kong.ActiveHealthcheck{
+			Type:     kong.String("http"),
+			HTTPPath: kong.String(healthcheck.Path),
+			Timeout:  positiveInt(healthcheck.Timeout),
+			Healthy: &kong.Healthy{
+				Interval:  interval,
+				Successes: positiveInt(healthcheck.HealthyThreshold),
+			},
+			Unhealthy: &kong.Unhealthy{
+				Interval:     interval,
+				HTTPFailures: positiveInt(healthcheck.UnhealthyThreshold),
+			},
+		},
+	}
+}
@@ -0,0 +1,201 @@
+package controller
+
+import (
+	"context"
+	"github.com/ciroque/kongo/apis/kongo/v1alpha1"
+	"github.com/ciroque/kongo/client"
+	"github.com/hbagdi/go-kong/kong"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"sync"
+	"testing"
+)
+
+type fakeResourceSetApplier struct {
+	mutex        sync.Mutex
+	resourceSets map[string]*client.ResourceSet
+}
+
+func (applier *fakeResourceSetApplier) ApplyResourceSet(owner string, resourceSet *client.ResourceSet) (*client.AppliedResourceSet, error) {
+	applier.mutex.Lock()
+	defer applier.mutex.Unlock()
+	applier.resourceSets[owner] = resourceSet
+
+	applied := &client.AppliedResourceSet{
+		Upstreams: make(map[string]*kong.Upstream),
+		Services:  make(map[string]*kong.Service),
+		Routes:    make(map[string]*kong.Route),
+	}
+	for _, upstreamDef := range resourceSet.Upstreams {
+		applied.Upstreams[upstreamDef.Name] = &kong.Upstream{ID: kong.String("id-" + upstreamDef.Name)}
+	}
+	for _, serviceDef := range resourceSet.Services {
+		applied.Services[serviceDef.Name] = &kong.Service{ID: kong.String("id-" + serviceDef.Name)}
+	}
+	for _, routeDef := range resourceSet.Routes {
+		applied.Routes[routeDef.Name] = &kong.Route{ID: kong.String("id-" + routeDef.Name)}
+	}
+	for _, pluginDef := range resourceSet.Plugins {
+		applied.Plugins = append(applied.Plugins, &kong.Plugin{ID: kong.String("id-" + pluginDef.Name)})
+	}
+	return applied, nil
+}
+
+func (applier *fakeResourceSetApplier) DeleteResourceSet(owner string) error {
+	applier.mutex.Lock()
+	defer applier.mutex.Unlock()
+	delete(applier.resourceSets, owner)
+	return nil
+}
+
+func (applier *fakeResourceSetApplier) get(owner string) *client.ResourceSet {
+	applier.mutex.Lock()
+	defer applier.mutex.Unlock()
+	return applier.resourceSets[owner]
+}
+
+func newDynamicClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	scheme := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(scheme)
+	return dynamicfake.NewSimpleDynamicClient(scheme, objects...)
+}
+
+func newKongoRoute(namespace string, name string) *v1alpha1.KongoRoute {
+	return &v1alpha1.KongoRoute{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.SchemeGroupVersion.String(), Kind: "KongoRoute"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Generation: 1},
+		Spec: v1alpha1.KongoRouteSpec{
+			Backend:        v1alpha1.KongoRouteBackend{ServiceName: "cart", ServicePort: 8080},
+			Paths:          []string{"/cart", "/basket"},
+			Hosts:          []string{"shop.example.com"},
+			Plugins:        []string{"limits"},
+			UpstreamPolicy: "balanced",
+		},
+	}
+}
+
+func createObject(t *testing.T, dynamicClient *dynamicfake.FakeDynamicClient, resource schema.GroupVersionResource, obj metav1.Object) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dynamicClient.Resource(resource).Namespace(obj.GetNamespace()).Create(context.Background(), &unstructured.Unstructured{Object: content}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getStatus(t *testing.T, dynamicClient *dynamicfake.FakeDynamicClient, namespace string, name string, into interface{}) {
+	var obj *unstructured.Unstructured
+	var err error
+	switch into.(type) {
+	case *v1alpha1.KongoRoute:
+		obj, err = dynamicClient.Resource(v1alpha1.KongoRouteResource).Namespace(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	case *v1alpha1.KongoPlugin:
+		obj, err = dynamicClient.Resource(v1alpha1.KongoPluginResource).Namespace(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	case *v1alpha1.KongoUpstreamPolicy:
+		obj, err = dynamicClient.Resource(v1alpha1.KongoUpstreamPolicyResource).Namespace(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	default:
+		t.Fatalf("unexpected type %T", into)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fromUnstructured(obj, into); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCRDController(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dynamicClient := newDynamicClient(newKongoRoute("shop", "cart"))
+	applier := &fakeResourceSetApplier{resourceSets: make(map[string]*client.ResourceSet)}
+	controller := NewCRDController(applier, dynamicClient, Config{})
+	go controller.Run(ctx)
+
+	route := new(v1alpha1.KongoRoute)
+	eventually(t, "the missing references to be reported", func() bool {
+		getStatus(t, dynamicClient, "shop", "cart", route)
+		return route.Status.SyncError != ""
+	})
+	if applier.get(KongoRouteOwner("shop", "cart")) != nil {
+		t.Fatalf("A KongoRoute with missing references should not be synced")
+	}
+
+	plugin := &v1alpha1.KongoPlugin{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.SchemeGroupVersion.String(), Kind: "KongoPlugin"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "limits", Generation: 1},
+		Spec: v1alpha1.KongoPluginSpec{
+			Plugin: "rate-limiting",
+			Config: &apiextensionsv1.JSON{Raw: []byte(`{"minute":20}`)},
+		},
+	}
+	policy := &v1alpha1.KongoUpstreamPolicy{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.SchemeGroupVersion.String(), Kind: "KongoUpstreamPolicy"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "balanced", Generation: 1},
+		Spec: v1alpha1.KongoUpstreamPolicySpec{
+			Algorithm:   "least-connections",
+			Healthcheck: &v1alpha1.KongoHealthcheck{Path: "/healthz"},
+		},
+	}
+	createObject(t, dynamicClient, v1alpha1.KongoPluginResource, plugin)
+	createObject(t, dynamicClient, v1alpha1.KongoUpstreamPolicyResource, policy)
+
+	owner := KongoRouteOwner("shop", "cart")
+	eventually(t, "the KongoRoute to be synced", func() bool {
+		return applier.get(owner) != nil
+	})
+
+	resourceSet := applier.get(owner)
+	kongNames, _ := KongoRouteNames(client.DefaultNamingStrategy, "shop", "cart")
+	if len(resourceSet.Routes) != 1 || len(resourceSet.Routes[0].Paths) != 2 || *resourceSet.Routes[0].Hosts[0] != "shop.example.com" {
+		t.Fatalf("The KongoRoute should become a single Route with all paths: %+v", resourceSet.Routes)
+	}
+	if len(resourceSet.Targets) != 1 || resourceSet.Targets[0].Target != "cart.shop.svc:8080" {
+		t.Fatalf("The backend should be targeted through cluster DNS: %+v", resourceSet.Targets)
+	}
+	if resourceSet.Upstreams[0].Algorithm != "least-connections" || *resourceSet.Upstreams[0].Healthchecks.Active.HTTPPath != "/healthz" {
+		t.Fatalf("The KongoUpstreamPolicy should configure the Upstream: %+v", resourceSet.Upstreams[0])
+	}
+	if len(resourceSet.Plugins) != 1 || resourceSet.Plugins[0].Config["minute"] != float64(20) || *resourceSet.Plugins[0].Route.Name != kongNames.RouteName {
+		t.Fatalf("The KongoPlugin should be applied to the Route: %+v", resourceSet.Plugins)
+	}
+
+	eventually(t, "the KongoRoute status to report the Kong IDs", func() bool {
+		getStatus(t, dynamicClient, "shop", "cart", route)
+		return route.Status.SyncError == "" && route.Status.RouteID == "id-"+kongNames.RouteName && route.Status.PluginIDs["limits"] == "id-rate-limiting"
+	})
+	eventually(t, "the KongoPlugin status to report the Kong IDs", func() bool {
+		getStatus(t, dynamicClient, "shop", "limits", plugin)
+		return len(plugin.Status.PluginIDs) == 1 && plugin.Status.PluginIDs[0] == "id-rate-limiting"
+	})
+	eventually(t, "the KongoUpstreamPolicy status to report the Kong IDs", func() bool {
+		getStatus(t, dynamicClient, "shop", "balanced", policy)
+		return len(policy.Status.UpstreamIDs) == 1 && policy.Status.UpstreamIDs[0] == "id-"+kongNames.UpstreamName
+	})
+
+	err := dynamicClient.Resource(v1alpha1.KongoRouteResource).Namespace("shop").Delete(ctx, "cart", metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the KongoRoute resources to be deleted", func() bool {
+		return applier.get(owner) == nil
+	})
+}
+
+func TestTranslateKongoRouteRejectsInvalidPluginConfig(t *testing.T) {
+	plugin := &v1alpha1.KongoPlugin{
+		ObjectMeta: metav1.ObjectMeta{Name: "limits"},
+		Spec:       v1alpha1.KongoPluginSpec{Plugin: "rate-limiting", Config: &apiextensionsv1.JSON{Raw: []byte(`[1, 2]`)}},
+	}
+	_, err := TranslateKongoRoute(client.DefaultNamingStrategy, newKongoRoute("shop", "cart"), []*v1alpha1.KongoPlugin{plugin}, nil)
+	if err == nil {
+		t.Fatalf("A config that is not a JSON object should be rejected")
+	}
+}
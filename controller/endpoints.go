@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"fmt"
+	"github.com/ciroque/kongo/client"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"sort"
+	"strconv"
+)
+
+// NotReadyPolicy decides what becomes of the Target of an endpoint that is terminating or not ready.
+type NotReadyPolicy string
+
+const (
+	// NotReadyDrain keeps the Target at weight 0, so Kong sends it no new requests while in-flight ones complete.
+	NotReadyDrain NotReadyPolicy = "drain"
+	// NotReadyRemove deletes the Target.
+	NotReadyRemove NotReadyPolicy = "remove"
+)
+
+const ReadyTargetWeight = 100
+
+func ParseNotReadyPolicy(value string) (NotReadyPolicy, error) {
+	switch policy := NotReadyPolicy(value); policy {
+	case NotReadyDrain, NotReadyRemove:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown not-ready policy '%s', expected drain or remove", value)
+}
+
+// selectServicePorts picks the Service port named or numbered by AnnotationPort alone, every port without it.
+func selectServicePorts(service *v1.Service) ([]v1.ServicePort, error) {
+	selector, found := service.Annotations[AnnotationPort]
+	if !found {
+		return service.Spec.Ports, nil
+	}
+
+	for _, servicePort := range service.Spec.Ports {
+		if servicePort.Name == selector || strconv.Itoa(int(servicePort.Port)) == selector {
+			return []v1.ServicePort{servicePort}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid annotation '%s': the Service has no port '%s'", AnnotationPort, selector)
+}
+
+// k8sEndpoints turns the endpoints of the slices into weighted Targets for the port the slices resolved servicePort to,
+// which is how named target ports end up as numbers. IPv4, IPv6 and FQDN slices are all included, and an endpoint
+// appearing in more than one slice is kept once, with its highest weight.
+func k8sEndpoints(endpointSlices []*discoveryv1.EndpointSlice, servicePort v1.ServicePort, policy NotReadyPolicy) []*client.K8sEndpoint {
+	byTarget := make(map[string]*client.K8sEndpoint)
+
+	for _, endpointSlice := range endpointSlices {
+		port, found := endpointSlicePort(endpointSlice, servicePort)
+		if !found {
+			continue
+		}
+
+		for _, endpoint := range endpointSlice.Endpoints {
+			weight := ReadyTargetWeight
+			if !isReady(endpoint) {
+				if policy == NotReadyRemove {
+					continue
+				}
+				weight = 0
+			}
+
+			for _, address := range endpoint.Addresses {
+				k8sEndpoint := &client.K8sEndpoint{Address: address, Port: int(port), Weight: weight}
+				existing, found := byTarget[k8sEndpoint.Target()]
+				if !found || existing.Weight < weight {
+					byTarget[k8sEndpoint.Target()] = k8sEndpoint
+				}
+			}
+		}
+	}
+
+	k8sEndpoints := []*client.K8sEndpoint{}
+	for _, k8sEndpoint := range byTarget {
+		k8sEndpoints = append(k8sEndpoints, k8sEndpoint)
+	}
+	sort.Slice(k8sEndpoints, func(i, j int) bool { return k8sEndpoints[i].Target() < k8sEndpoints[j].Target() })
+
+	return k8sEndpoints
+}
+
+// isReady follows the EndpointSlice API, where a missing ready condition means ready and terminating endpoints are never ready.
+func isReady(endpoint discoveryv1.Endpoint) bool {
+	conditions := endpoint.Conditions
+	if conditions.Terminating != nil && *conditions.Terminating {
+		return false
+	}
+	return conditions.Ready == nil || *conditions.Ready
+}
+
+func endpointSlicePort(endpointSlice *discoveryv1.EndpointSlice, servicePort v1.ServicePort) (int32, bool) {
+	for _, port := range endpointSlice.Ports {
+		if port.Port == nil {
+			continue
+		}
+		name := ""
+		if port.Name != nil {
+			name = *port.Name
+		}
+		if name == servicePort.Name {
+			return *port.Port, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"testing"
+)
+
+func TestK8sEndpoints(t *testing.T) {
+	servicePort := v1.ServicePort{Name: "web", Port: 80, TargetPort: intstr.FromString("http")}
+	portName := "web"
+	port := int32(8080)
+	notReady := false
+	terminating := true
+
+	ipv4 := &discoveryv1.EndpointSlice{
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports:       []discoveryv1.EndpointPort{{Name: &portName, Port: &port}},
+		Endpoints: []discoveryv1.Endpoint{
+			{Addresses: []string{"10.0.0.1"}},
+			{Addresses: []string{"10.0.0.2"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+			{Addresses: []string{"10.0.0.3"}, Conditions: discoveryv1.EndpointConditions{Terminating: &terminating}},
+		},
+	}
+	ipv6 := &discoveryv1.EndpointSlice{
+		AddressType: discoveryv1.AddressTypeIPv6,
+		Ports:       []discoveryv1.EndpointPort{{Name: &portName, Port: &port}},
+		Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"fd00::1"}}},
+	}
+	otherPort := "metrics"
+	metrics := &discoveryv1.EndpointSlice{
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports:       []discoveryv1.EndpointPort{{Name: &otherPort, Port: &port}},
+		Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.9"}}},
+	}
+	slices := []*discoveryv1.EndpointSlice{ipv4, ipv6, metrics}
+
+	drained := k8sEndpoints(slices, servicePort, NotReadyDrain)
+	expected := map[string]int{"10.0.0.1:8080": ReadyTargetWeight, "10.0.0.2:8080": 0, "10.0.0.3:8080": 0, "[fd00::1]:8080": ReadyTargetWeight}
+	if len(drained) != len(expected) {
+		t.Fatalf("Expected %v Targets, got %v", len(expected), len(drained))
+	}
+	for _, endpoint := range drained {
+		weight, found := expected[endpoint.Target()]
+		if !found || weight != endpoint.Weight {
+			t.Fatalf("Unexpected Target '%s' with weight %v", endpoint.Target(), endpoint.Weight)
+		}
+	}
+
+	removed := k8sEndpoints(slices, servicePort, NotReadyRemove)
+	if len(removed) != 2 {
+		t.Fatalf("Not ready and terminating endpoints should have been removed, got %v Targets", len(removed))
+	}
+}
+
+func TestSelectServicePorts(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}},
+		Spec:       v1.ServiceSpec{Ports: []v1.ServicePort{{Name: "http", Port: 80}, {Name: "grpc", Port: 9090}}},
+	}
+
+	servicePorts, err := selectServicePorts(service)
+	if err != nil || len(servicePorts) != 2 {
+		t.Fatalf("Every port should have been selected, got: %v, %v", servicePorts, err)
+	}
+
+	service.Annotations[AnnotationPort] = "grpc"
+	servicePorts, err = selectServicePorts(service)
+	if err != nil || len(servicePorts) != 1 || servicePorts[0].Port != 9090 {
+		t.Fatalf("The annotated port should have been selected alone, got: %v, %v", servicePorts, err)
+	}
+
+	service.Annotations[AnnotationPort] = "8443"
+	_, err = selectServicePorts(service)
+	if err == nil {
+		t.Fatalf("An annotated port the Service lacks should be rejected")
+	}
+}
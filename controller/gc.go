@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"context"
+	"github.com/ciroque/kongo/client"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultFullResyncInterval = 5 * time.Minute
+	DefaultGCGracePeriod      = 10 * time.Minute
+)
+
+// runFullResync calls fullResync every config.FullResyncInterval until the context is cancelled.
+func (controller *Controller) runFullResync(ctx context.Context) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		controller.fullResync(time.Now())
+	}, controller.config.FullResyncInterval)
+}
+
+// fullResync queues every registrable Service, catching up on events that were missed, then looks for Kong entities
+// owned by Services that are gone. Such orphans are deleted once they have been seen orphaned for the grace period,
+// giving events in flight the chance to deregister them first.
+func (controller *Controller) fullResync(now time.Time) {
+	services, err := controller.serviceLister.List(labels.Everything())
+	if err != nil {
+		controller.config.Logger.Error("full resync failed listing Services", "error", err)
+		resyncsTotal.WithLabelValues("error").Inc()
+		return
+	}
+	for _, service := range services {
+		if isRegistrable(service) {
+			controller.queue.add(service.Namespace + "/" + service.Name)
+		}
+	}
+
+	entities, err := controller.registrar.ListKongEntities()
+	if err != nil {
+		controller.config.Logger.Error("full resync failed listing Kong entities", "error", err)
+		resyncsTotal.WithLabelValues("error").Inc()
+		return
+	}
+
+	orphans := make(map[string]time.Time)
+	failed := false
+	for _, entity := range entities {
+		namespace, name, ok := owningService(entity, controller.config.GCUntagged)
+		if !ok || (controller.config.Namespace != "" && namespace != controller.config.Namespace) {
+			continue
+		}
+		service, err := controller.serviceLister.Services(namespace).Get(name)
+		if err == nil && isRegistrable(service) {
+			continue
+		}
+		if err != nil && !errors.IsNotFound(err) {
+			continue
+		}
+
+		firstSeen, found := controller.orphans[entity.ID]
+		if !found {
+			firstSeen = now
+		}
+		if now.Sub(firstSeen) < controller.config.GCGracePeriod {
+			orphans[entity.ID] = firstSeen
+			continue
+		}
+
+		err = controller.registrar.DeleteKongEntity(entity)
+		if err != nil {
+			controller.config.Logger.Warn("failed collecting orphan", "kind", entity.Kind, "name", entity.Name, "error", err)
+			orphans[entity.ID] = firstSeen
+			failed = true
+			continue
+		}
+		controller.config.Logger.Info("collected orphan", "kind", entity.Kind, "name", entity.Name, "namespace", namespace,
+			"service", name)
+		orphansCollectedTotal.WithLabelValues(entity.Kind).Inc()
+	}
+
+	controller.orphans = orphans
+	orphansPending.Set(float64(len(orphans)))
+	if failed {
+		resyncsTotal.WithLabelValues("error").Inc()
+	} else {
+		resyncsTotal.WithLabelValues("success").Inc()
+	}
+}
+
+// owningService finds the Service of an entity from its owner tag. Untagged entities only belong to the Service they
+// are named after when adopted, kongo having registered Services without tags before; otherwise they are left alone,
+// kongo cannot tell its own from those made by hand. Entities owned by other sources are not the entities of a Service.
+func owningService(entity *client.KongEntity, adoptUntagged bool) (namespace string, name string, ok bool) {
+	if entity.Owner == "" {
+		if !adoptUntagged {
+			return "", "", false
+		}
+		return entity.Components.Namespace, entity.Components.Name, isServiceEntity(entity.Components)
+	}
+	if !strings.HasPrefix(entity.Owner, client.K8sServiceOwner("")) {
+		return "", "", false
+	}
+	namespace, name = client.SplitBaseName(strings.TrimPrefix(entity.Owner, client.K8sServiceOwner("")))
+	return namespace, name, namespace != "" && name != ""
+}
+
+// isServiceEntity tells the entities of Services apart from those of other sources, such as Ingresses, whose names
+// have dots.
+func isServiceEntity(components client.NameComponents) bool {
+	return components.Namespace != "" && components.Name != "" && !strings.Contains(components.Name, ".")
+}
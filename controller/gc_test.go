@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"context"
+	"github.com/ciroque/kongo/client"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"testing"
+	"time"
+)
+
+func kongEntities(baseName string, kinds ...string) []*client.KongEntity {
+	entities := []*client.KongEntity{}
+	for _, kind := range kinds {
+		name := baseName + "." + kind
+		components, _ := client.DefaultNamingStrategy.ParseKongName(name)
+		entities = append(entities, &client.KongEntity{Kind: kind, ID: "id-" + name, Name: name, Components: components})
+	}
+	return entities
+}
+
+// ownedEntities are the entities of a Service tagged with their owner.
+func ownedEntities(baseName string, kinds ...string) []*client.KongEntity {
+	entities := kongEntities(baseName, kinds...)
+	for _, entity := range entities {
+		entity.Owner = client.K8sServiceOwner(baseName)
+	}
+	return entities
+}
+
+func TestFullResyncCollectsOrphans(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	kubeClient := fake.NewSimpleClientset(newService("kongo", "orders"))
+	registrar := newFakeRegistrar()
+	registrar.entities = append(registrar.entities, ownedEntities("kongo.orders", client.KindRoute, client.KindService, client.KindUpstream)...)
+	registrar.entities = append(registrar.entities, ownedEntities("kongo.gone", client.KindRoute, client.KindUpstream)...)
+	registrar.entities = append(registrar.entities, kongEntities("shop.storefront.cart.8080", client.KindService)...)
+	// Made by hand, under a name kongo could have given it.
+	registrar.entities = append(registrar.entities, kongEntities("prod.web", client.KindUpstream)...)
+
+	controller := NewController(registrar, kubeClient, Config{
+		Recorder:           record.NewFakeRecorder(10),
+		FullResyncInterval: -1,
+		GCGracePeriod:      time.Minute,
+	})
+	controller.informerFactory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), controller.informersSynced...) {
+		t.Fatal("informer caches did not sync")
+	}
+
+	collectedRoutes := testutil.ToFloat64(orphansCollectedTotal.WithLabelValues(client.KindRoute))
+	now := time.Now()
+
+	controller.fullResync(now)
+	if entities, _ := registrar.ListKongEntities(); len(entities) != 7 {
+		t.Fatalf("Orphans should be kept during the grace period, %d entities left", len(entities))
+	}
+	if len(controller.orphans) != 2 || testutil.ToFloat64(orphansPending) != 2 {
+		t.Fatalf("Both entities of the missing Service should be pending: %v", controller.orphans)
+	}
+	if controller.queue.queue.Len() != 1 {
+		t.Fatalf("The existing Service should be queued for a resync")
+	}
+
+	controller.fullResync(now.Add(2 * time.Minute))
+	entities, _ := registrar.ListKongEntities()
+	if len(entities) != 5 {
+		t.Fatalf("The orphans should be collected after the grace period, %d entities left", len(entities))
+	}
+	for _, entity := range entities {
+		if entity.Components.Name == "gone" {
+			t.Fatalf("'%s' should have been collected", entity.Name)
+		}
+	}
+	if len(controller.orphans) != 0 || testutil.ToFloat64(orphansPending) != 0 {
+		t.Fatalf("No orphans should be pending: %v", controller.orphans)
+	}
+	if testutil.ToFloat64(orphansCollectedTotal.WithLabelValues(client.KindRoute)) != collectedRoutes+1 {
+		t.Fatalf("The collected Route should be counted")
+	}
+}
+
+func TestFullResyncCollectsUntaggedOrphansWhenAdopted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registrar := newFakeRegistrar()
+	registrar.entities = append(registrar.entities, kongEntities("prod.web", client.KindUpstream)...)
+
+	controller := NewController(registrar, fake.NewSimpleClientset(), Config{
+		Recorder:           record.NewFakeRecorder(10),
+		FullResyncInterval: -1,
+		GCGracePeriod:      time.Minute,
+		GCUntagged:         true,
+	})
+	controller.informerFactory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), controller.informersSynced...) {
+		t.Fatal("informer caches did not sync")
+	}
+
+	now := time.Now()
+	controller.fullResync(now)
+	controller.fullResync(now.Add(2 * time.Minute))
+	if entities, _ := registrar.ListKongEntities(); len(entities) != 0 {
+		t.Fatalf("Untagged orphans should be collected when adopted, %d entities left", len(entities))
+	}
+}
+
+func TestIsServiceEntity(t *testing.T) {
+	components, _ := client.DefaultNamingStrategy.ParseKongName(BaseName("kongo", "orders") + ".upstream")
+	if !isServiceEntity(components) {
+		t.Fatalf("The entities of a Service should be recognized: %+v", components)
+	}
+	for _, name := range []string{"orders.upstream", "shop.storefront.cart.8080.service"} {
+		components, _ := client.DefaultNamingStrategy.ParseKongName(name)
+		if isServiceEntity(components) {
+			t.Fatalf("'%s' should not be taken for the entity of a Service", name)
+		}
+	}
+}
+
+func TestOwningService(t *testing.T) {
+	entity := kongEntities("kongo.orders.grpc", client.KindUpstream)[0]
+	entity.Owner = client.K8sServiceOwner("kongo.orders")
+	if namespace, name, ok := owningService(entity, false); !ok || namespace != "kongo" || name != "orders" {
+		t.Fatalf("The entity of a port should belong to its Service: %s/%s", namespace, name)
+	}
+
+	entity.Owner = "kongo-ingress:kongo:orders"
+	if _, _, ok := owningService(entity, false); ok {
+		t.Fatalf("The entities of other owners should not belong to a Service")
+	}
+
+	entity.Owner = ""
+	if _, _, ok := owningService(entity, true); ok {
+		t.Fatalf("An untagged entity with a dotted name should not belong to a Service")
+	}
+
+	entity = kongEntities("prod.web", client.KindUpstream)[0]
+	if _, _, ok := owningService(entity, false); ok {
+		t.Fatalf("An untagged entity should not belong to a Service unless adopted")
+	}
+	if namespace, name, ok := owningService(entity, true); !ok || namespace != "prod" || name != "web" {
+		t.Fatalf("An adopted untagged entity should belong to the Service it is named after: %s/%s", namespace, name)
+	}
+}
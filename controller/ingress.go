@@ -0,0 +1,353 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"github.com/ciroque/kongo/client"
+	"github.com/hbagdi/go-kong/kong"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const legacyIngressClassAnnotation = "kubernetes.io/ingress.class"
+
+// IngressRegistrar is the part of client.Kongo the IngressController drives.
+type IngressRegistrar interface {
+	SyncResourceSet(owner string, resourceSet *client.ResourceSet) error
+	DeleteResourceSet(owner string) error
+}
+
+type IngressController struct {
+	registrar       IngressRegistrar
+	config          Config
+	informerFactory informers.SharedInformerFactory
+	ingressLister   networkinglisters.IngressLister
+	secretLister    corelisters.SecretLister
+	serviceLister   corelisters.ServiceLister
+	informersSynced []cache.InformerSynced
+	recorder        record.EventRecorder
+	queue           *keyQueue
+}
+
+// NewIngressController handles the Ingresses whose class is config.IngressClass, leaving the others to other controllers.
+func NewIngressController(registrar IngressRegistrar, kubeClient kubernetes.Interface, config Config) *IngressController {
+	config = config.withLogger()
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	if config.NamingStrategy == nil {
+		config.NamingStrategy = client.DefaultNamingStrategy
+	}
+	if config.IngressClass == "" {
+		config.IngressClass = DefaultIngressClass
+	}
+
+	recorder := config.Recorder
+	if recorder == nil {
+		recorder = newEventRecorder(kubeClient)
+	}
+
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(kubeClient, config.ResyncPeriod, informers.WithNamespace(config.Namespace))
+	ingressInformer := informerFactory.Networking().V1().Ingresses()
+	secretInformer := informerFactory.Core().V1().Secrets()
+	serviceInformer := informerFactory.Core().V1().Services()
+
+	controller := &IngressController{
+		registrar:       registrar,
+		config:          config,
+		informerFactory: informerFactory,
+		ingressLister:   ingressInformer.Lister(),
+		secretLister:    secretInformer.Lister(),
+		serviceLister:   serviceInformer.Lister(),
+		informersSynced: []cache.InformerSynced{
+			ingressInformer.Informer().HasSynced,
+			secretInformer.Informer().HasSynced,
+			serviceInformer.Informer().HasSynced,
+		},
+		recorder: recorder,
+	}
+	controller.queue = newKeyQueue("kongo-ingress", controller.sync)
+
+	ingressInformer.Informer().AddEventHandler(controller.queue.eventHandler())
+	secretInformer.Informer().AddEventHandler(controller.referencingIngressesHandler(referencesSecret))
+	serviceInformer.Informer().AddEventHandler(controller.referencingIngressesHandler(referencesService))
+
+	return controller
+}
+
+// Run blocks until the context is cancelled.
+func (controller *IngressController) Run(ctx context.Context) error {
+	defer utilruntime.HandleCrash()
+
+	controller.informerFactory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), controller.informersSynced...) {
+		return fmt.Errorf("timed out waiting for the informer caches to sync")
+	}
+
+	controller.config.Logger.Info("kongo ingress controller started", "class", controller.config.IngressClass, "workers", controller.config.Workers)
+	controller.queue.run(ctx, controller.config.Workers)
+	controller.config.Logger.Info("kongo ingress controller stopping")
+	return nil
+}
+
+// referencingIngressesHandler queues the Ingresses of the object's namespace that refer to the object by name.
+func (controller *IngressController) referencingIngressesHandler(references func(ingress *networkingv1.Ingress, name string) bool) cache.ResourceEventHandlerFuncs {
+	enqueue := func(obj interface{}) {
+		key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+		if err != nil {
+			return
+		}
+		namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+		ingresses, err := controller.ingressLister.Ingresses(namespace).List(labels.Everything())
+		if err != nil {
+			return
+		}
+		for _, ingress := range ingresses {
+			if references(ingress, name) {
+				controller.queue.addObject(ingress)
+			}
+		}
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(_, newObj interface{}) { enqueue(newObj) },
+		DeleteFunc: enqueue,
+	}
+}
+
+func (controller *IngressController) sync(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	owner := IngressOwner(namespace, name)
+
+	ingress, err := controller.ingressLister.Ingresses(namespace).Get(name)
+	if errors.IsNotFound(err) || (err == nil && !HasIngressClass(ingress, controller.config.IngressClass)) {
+		return controller.registrar.DeleteResourceSet(owner)
+	}
+	if err != nil {
+		return err
+	}
+
+	resourceSet, err := TranslateIngress(controller.config.NamingStrategy, ingress, controller.secretLister.Secrets(namespace), controller.serviceLister.Services(namespace))
+	if err != nil {
+		controller.recorder.Eventf(ingress, v1.EventTypeWarning, "TranslationFailed", "Not synced with Kong: %v", err)
+		return nil
+	}
+
+	err = controller.registrar.SyncResourceSet(owner, resourceSet)
+	if err != nil {
+		controller.recorder.Eventf(ingress, v1.EventTypeWarning, "SyncFailed", "Sync with Kong failed: %v", err)
+		return fmt.Errorf("error syncing Ingress '%s': %v", key, err)
+	}
+	return nil
+}
+
+const DefaultIngressClass = "kongo"
+
+// IngressOwner is the tag marking the Kong entities created for an Ingress.
+func IngressOwner(namespace string, name string) string {
+	return "kongo-ingress:" + namespace + ":" + name
+}
+
+// HasIngressClass checks spec.ingressClassName, falling back to the legacy annotation for Ingresses without one.
+func HasIngressClass(ingress *networkingv1.Ingress, ingressClass string) bool {
+	if ingress.Spec.IngressClassName != nil {
+		return *ingress.Spec.IngressClassName == ingressClass
+	}
+	return ingress.Annotations[legacyIngressClassAnnotation] == ingressClass
+}
+
+// TranslateIngress maps every backend to a Kong Service, addressed through cluster DNS, and every path to a Route.
+// TLS sections become Certificates with their hosts as SNIs. Entities are named with the naming strategy, the Ingress
+// name being followed by the backend or the position of the path.
+func TranslateIngress(naming client.NamingStrategy, ingress *networkingv1.Ingress, secrets corelisters.SecretNamespaceLister, services corelisters.ServiceNamespaceLister) (*client.ResourceSet, error) {
+	resourceSet := new(client.ResourceSet)
+	serviceNames := make(map[string]bool)
+
+	addBackend := func(backend *networkingv1.IngressBackend) (string, error) {
+		if backend.Service == nil {
+			return "", fmt.Errorf("only Service backends are supported")
+		}
+		port, err := backendPort(ingress.Namespace, backend.Service, services)
+		if err != nil {
+			return "", err
+		}
+		serviceName, err := naming.KongName(client.NameComponents{
+			Namespace: ingress.Namespace,
+			Name:      strings.Join([]string{ingress.Name, backend.Service.Name, strconv.Itoa(port)}, "."),
+			Kind:      client.KindService,
+		})
+		if err != nil {
+			return "", err
+		}
+		if !serviceNames[serviceName] {
+			serviceNames[serviceName] = true
+			resourceSet.Services = append(resourceSet.Services, &client.ServiceDef{
+				Name: serviceName,
+				Host: backend.Service.Name + "." + ingress.Namespace + ".svc",
+				Port: port,
+			})
+		}
+		return serviceName, nil
+	}
+
+	routeName := func(suffix string) (string, error) {
+		return naming.KongName(client.NameComponents{Namespace: ingress.Namespace, Name: ingress.Name + "." + suffix, Kind: client.KindRoute})
+	}
+
+	if ingress.Spec.DefaultBackend != nil {
+		serviceName, err := addBackend(ingress.Spec.DefaultBackend)
+		if err != nil {
+			return nil, fmt.Errorf("default backend: %v", err)
+		}
+		name, err := routeName("default")
+		if err != nil {
+			return nil, fmt.Errorf("default backend: %v", err)
+		}
+		resourceSet.Routes = append(resourceSet.Routes, &client.RouteDef{
+			Name:    name,
+			Paths:   kong.StringSlice("/"),
+			Service: &kong.Service{Name: kong.String(serviceName)},
+		})
+	}
+
+	for ruleIndex, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for pathIndex, path := range rule.HTTP.Paths {
+			serviceName, err := addBackend(&path.Backend)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d, path '%s': %v", ruleIndex, path.Path, err)
+			}
+			paths, err := kongPaths(path)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d, path '%s': %v", ruleIndex, path.Path, err)
+			}
+			name, err := routeName(fmt.Sprintf("%d-%d", ruleIndex, pathIndex))
+			if err != nil {
+				return nil, fmt.Errorf("rule %d, path '%s': %v", ruleIndex, path.Path, err)
+			}
+			routeDef := &client.RouteDef{
+				Name:    name,
+				Paths:   paths,
+				Service: &kong.Service{Name: kong.String(serviceName)},
+			}
+			if rule.Host != "" {
+				routeDef.Hosts = kong.StringSlice(rule.Host)
+			}
+			resourceSet.Routes = append(resourceSet.Routes, routeDef)
+		}
+	}
+
+	for _, tls := range ingress.Spec.TLS {
+		if len(tls.Hosts) == 0 {
+			return nil, fmt.Errorf("TLS section with Secret '%s' lists no hosts", tls.SecretName)
+		}
+		secret, err := secrets.Get(tls.SecretName)
+		if err != nil {
+			return nil, fmt.Errorf("TLS Secret '%s': %v", tls.SecretName, err)
+		}
+		cert, key := secret.Data[v1.TLSCertKey], secret.Data[v1.TLSPrivateKeyKey]
+		if len(cert) == 0 || len(key) == 0 {
+			return nil, fmt.Errorf("TLS Secret '%s' lacks '%s' or '%s'", tls.SecretName, v1.TLSCertKey, v1.TLSPrivateKeyKey)
+		}
+		resourceSet.Certificates = append(resourceSet.Certificates, &client.CertificateDef{
+			Cert: string(cert),
+			Key:  string(key),
+			SNIs: kong.StringSlice(tls.Hosts...),
+		})
+	}
+
+	return resourceSet, nil
+}
+
+// kongPaths expresses the Ingress path types with Kong's prefix and regex paths. Kong paths are plain prefixes,
+// so Prefix paths are split into an exact match and a prefix ending on the element boundary. The anchored paths being
+// regexes, the Ingress path is quoted within them.
+func kongPaths(path networkingv1.HTTPIngressPath) ([]*string, error) {
+	value := path.Path
+	if value == "" {
+		value = "/"
+	}
+	if !strings.HasPrefix(value, "/") {
+		return nil, fmt.Errorf("paths must start with '/'")
+	}
+
+	pathType := networkingv1.PathTypeImplementationSpecific
+	if path.PathType != nil {
+		pathType = *path.PathType
+	}
+
+	switch pathType {
+	case networkingv1.PathTypeExact:
+		return kong.StringSlice(regexp.QuoteMeta(value) + "$"), nil
+	case networkingv1.PathTypePrefix:
+		value = strings.TrimSuffix(value, "/")
+		if value == "" {
+			return kong.StringSlice("/"), nil
+		}
+		value = regexp.QuoteMeta(value)
+		return kong.StringSlice(value+"$", value+"/"), nil
+	case networkingv1.PathTypeImplementationSpecific:
+		return kong.StringSlice(value), nil
+	}
+	return nil, fmt.Errorf("unknown path type '%s'", pathType)
+}
+
+func backendPort(namespace string, backend *networkingv1.IngressServiceBackend, services corelisters.ServiceNamespaceLister) (int, error) {
+	if backend.Port.Name == "" {
+		return int(backend.Port.Number), nil
+	}
+
+	service, err := services.Get(backend.Name)
+	if err != nil {
+		return 0, fmt.Errorf("resolving port '%s' of Service '%s/%s': %v", backend.Port.Name, namespace, backend.Name, err)
+	}
+	for _, servicePort := range service.Spec.Ports {
+		if servicePort.Name == backend.Port.Name {
+			return int(servicePort.Port), nil
+		}
+	}
+	return 0, fmt.Errorf("Service '%s/%s' has no port '%s'", namespace, backend.Name, backend.Port.Name)
+}
+
+func referencesSecret(ingress *networkingv1.Ingress, name string) bool {
+	for _, tls := range ingress.Spec.TLS {
+		if tls.SecretName == name {
+			return true
+		}
+	}
+	return false
+}
+
+func referencesService(ingress *networkingv1.Ingress, name string) bool {
+	if ingress.Spec.DefaultBackend != nil && ingress.Spec.DefaultBackend.Service != nil && ingress.Spec.DefaultBackend.Service.Name == name {
+		return true
+	}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service != nil && path.Backend.Service.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"context"
+	"github.com/ciroque/kongo/client"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"sync"
+	"testing"
+)
+
+type fakeIngressRegistrar struct {
+	mutex        sync.Mutex
+	resourceSets map[string]*client.ResourceSet
+}
+
+func (registrar *fakeIngressRegistrar) SyncResourceSet(owner string, resourceSet *client.ResourceSet) error {
+	registrar.mutex.Lock()
+	defer registrar.mutex.Unlock()
+	registrar.resourceSets[owner] = resourceSet
+	return nil
+}
+
+func (registrar *fakeIngressRegistrar) DeleteResourceSet(owner string) error {
+	registrar.mutex.Lock()
+	defer registrar.mutex.Unlock()
+	delete(registrar.resourceSets, owner)
+	return nil
+}
+
+func (registrar *fakeIngressRegistrar) get(owner string) *client.ResourceSet {
+	registrar.mutex.Lock()
+	defer registrar.mutex.Unlock()
+	return registrar.resourceSets[owner]
+}
+
+func newIngress(namespace string, name string, ingressClass string) *networkingv1.Ingress {
+	prefix := networkingv1.PathTypePrefix
+	exact := networkingv1.PathTypeExact
+	return &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: &ingressClass,
+			TLS:              []networkingv1.IngressTLS{{Hosts: []string{"shop.example.com"}, SecretName: "shop-tls"}},
+			Rules: []networkingv1.IngressRule{{
+				Host: "shop.example.com",
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{
+						{
+							Path:     "/cart/",
+							PathType: &prefix,
+							Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+								Name: "cart", Port: networkingv1.ServiceBackendPort{Name: "http"},
+							}},
+						},
+						{
+							Path:     "/healthz",
+							PathType: &exact,
+							Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+								Name: "cart", Port: networkingv1.ServiceBackendPort{Number: 8080},
+							}},
+						},
+					},
+				}},
+			}},
+		},
+	}
+}
+
+func newIngressFixtures(namespace string) (*v1.Service, *v1.Secret) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "cart"},
+		Spec:       v1.ServiceSpec{Ports: []v1.ServicePort{{Name: "http", Port: 8080}}},
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "shop-tls"},
+		Type:       v1.SecretTypeTLS,
+		Data:       map[string][]byte{v1.TLSCertKey: []byte("cert"), v1.TLSPrivateKeyKey: []byte("key")},
+	}
+	return service, secret
+}
+
+func TestIngressController(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	service, secret := newIngressFixtures("shop")
+	kubeClient := fake.NewSimpleClientset(service, secret)
+	registrar := &fakeIngressRegistrar{resourceSets: make(map[string]*client.ResourceSet)}
+	controller := NewIngressController(registrar, kubeClient, Config{Recorder: record.NewFakeRecorder(10)})
+	go controller.Run(ctx)
+
+	_, err := kubeClient.NetworkingV1().Ingresses("shop").Create(ctx, newIngress("shop", "storefront", DefaultIngressClass), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = kubeClient.NetworkingV1().Ingresses("shop").Create(ctx, newIngress("shop", "other", "nginx"), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner := IngressOwner("shop", "storefront")
+	eventually(t, "the Ingress to be synced", func() bool {
+		return registrar.get(owner) != nil
+	})
+
+	resourceSet := registrar.get(owner)
+	if len(resourceSet.Services) != 1 || resourceSet.Services[0].Host != "cart.shop.svc" || resourceSet.Services[0].Port != 8080 {
+		t.Fatalf("Both paths should share the one backend Service: %+v", resourceSet.Services)
+	}
+	if len(resourceSet.Routes) != 2 || *resourceSet.Routes[0].Hosts[0] != "shop.example.com" {
+		t.Fatalf("Each path should become a Route for the rule host: %+v", resourceSet.Routes)
+	}
+	if len(resourceSet.Certificates) != 1 || resourceSet.Certificates[0].Cert != "cert" || *resourceSet.Certificates[0].SNIs[0] != "shop.example.com" {
+		t.Fatalf("The TLS section should become a Certificate: %+v", resourceSet.Certificates)
+	}
+	if registrar.get(IngressOwner("shop", "other")) != nil {
+		t.Fatalf("Ingresses of other classes should be left alone")
+	}
+
+	err = kubeClient.NetworkingV1().Ingresses("shop").Delete(ctx, "storefront", metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the Ingress resources to be deleted", func() bool {
+		return registrar.get(owner) == nil
+	})
+}
+
+func TestKongPaths(t *testing.T) {
+	prefix := networkingv1.PathTypePrefix
+	exact := networkingv1.PathTypeExact
+	implementationSpecific := networkingv1.PathTypeImplementationSpecific
+
+	cases := []struct {
+		path     string
+		pathType *networkingv1.PathType
+		expected []string
+	}{
+		{"/cart/", &prefix, []string{"/cart$", "/cart/"}},
+		{"/", &prefix, []string{"/"}},
+		{"/healthz", &exact, []string{"/healthz$"}},
+		{"/v1.0/items", &prefix, []string{`/v1\.0/items$`, `/v1\.0/items/`}},
+		{"/search(all)", &exact, []string{`/search\(all\)$`}},
+		{"/static", &implementationSpecific, []string{"/static"}},
+		{"/static", nil, []string{"/static"}},
+	}
+
+	for _, c := range cases {
+		paths, err := kongPaths(networkingv1.HTTPIngressPath{Path: c.path, PathType: c.pathType})
+		if err != nil {
+			t.Fatalf("'%s' should translate: %v", c.path, err)
+		}
+		if len(paths) != len(c.expected) {
+			t.Fatalf("'%s' translated to %v paths, expected %v", c.path, len(paths), c.expected)
+		}
+		for i := range paths {
+			if *paths[i] != c.expected[i] {
+				t.Fatalf("'%s' translated to '%s', expected '%s'", c.path, *paths[i], c.expected[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"github.com/ciroque/kongo/client"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultLeaseName     = "kongo-controller"
+	DefaultLeaseDuration = 15 * time.Second
+	DefaultRenewDeadline = 10 * time.Second
+	DefaultRetryPeriod   = 2 * time.Second
+
+	serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// LeaderElectionConfig describes the Lease the replicas of the controller compete for. Zero values take the defaults,
+// the namespace defaulting to that of the pod, or "default" outside of a cluster.
+type LeaderElectionConfig struct {
+	LeaseName      string
+	LeaseNamespace string
+	// Identity defaults to the hostname followed by a random suffix.
+	Identity      string
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+	// Logger defaults to slog.Default().
+	Logger client.Logger
+}
+
+// RunWithLeaderElection blocks until this replica holds the Lease, then calls run with a context cancelled when the
+// Lease is lost. The Lease is released when ctx is cancelled, letting a follower take over right away. Losing the Lease
+// otherwise is reported as an error, the replica being expected to exit and restart as a follower.
+func RunWithLeaderElection(ctx context.Context, kubeClient kubernetes.Interface, config LeaderElectionConfig, run func(ctx context.Context) error) error {
+	config = config.withDefaults()
+
+	lock, err := resourcelock.New(
+		resourcelock.LeasesResourceLock,
+		config.LeaseNamespace,
+		config.LeaseName,
+		kubeClient.CoreV1(),
+		kubeClient.CoordinationV1(),
+		resourcelock.ResourceLockConfig{Identity: config.Identity},
+	)
+	if err != nil {
+		return fmt.Errorf("error creating the Lease lock: %v", err)
+	}
+
+	// run is started on a goroutine of its own, its result is waited for once the elector returns.
+	var leading atomic.Bool
+	runResult := make(chan error, 1)
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   config.LeaseDuration,
+		RenewDeadline:   config.RenewDeadline,
+		RetryPeriod:     config.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            config.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				config.Logger.Info("acquired Lease", "identity", config.Identity, "namespace", config.LeaseNamespace,
+					"lease", config.LeaseName)
+				leading.Store(true)
+				runResult <- run(leaderCtx)
+			},
+			OnStoppedLeading: func() {
+				config.Logger.Info("released Lease", "identity", config.Identity, "namespace", config.LeaseNamespace,
+					"lease", config.LeaseName)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != config.Identity {
+					config.Logger.Info("following", "identity", config.Identity, "leader", identity)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error configuring leader election: %v", err)
+	}
+
+	elector.Run(ctx)
+
+	if leading.Load() {
+		if err := <-runResult; err != nil {
+			return err
+		}
+	}
+	if ctx.Err() == nil {
+		return fmt.Errorf("lost Lease %s/%s", config.LeaseNamespace, config.LeaseName)
+	}
+	return nil
+}
+
+func (config LeaderElectionConfig) withDefaults() LeaderElectionConfig {
+	if config.LeaseName == "" {
+		config.LeaseName = DefaultLeaseName
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	if config.LeaseNamespace == "" {
+		config.LeaseNamespace = podNamespace()
+	}
+	if config.Identity == "" {
+		hostname, _ := os.Hostname()
+		config.Identity = hostname + "_" + string(uuid.NewUUID())
+	}
+	if config.LeaseDuration == 0 {
+		config.LeaseDuration = DefaultLeaseDuration
+	}
+	if config.RenewDeadline == 0 {
+		config.RenewDeadline = DefaultRenewDeadline
+	}
+	if config.RetryPeriod == 0 {
+		config.RetryPeriod = DefaultRetryPeriod
+	}
+	return config
+}
+
+func podNamespace() string {
+	namespace, err := os.ReadFile(serviceAccountNamespaceFile)
+	if err != nil || strings.TrimSpace(string(namespace)) == "" {
+		return "default"
+	}
+	return strings.TrimSpace(string(namespace))
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"context"
+	"k8s.io/client-go/kubernetes/fake"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLeaderElectionHandsOver(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+
+	var mutex sync.Mutex
+	leading := []string{}
+	isLeading := func(identity string) bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, leader := range leading {
+			if leader == identity {
+				return true
+			}
+		}
+		return false
+	}
+
+	replica := func(ctx context.Context, identity string) chan error {
+		result := make(chan error, 1)
+		config := LeaderElectionConfig{
+			LeaseNamespace: "kongo",
+			Identity:       identity,
+			LeaseDuration:  time.Second,
+			RenewDeadline:  500 * time.Millisecond,
+			RetryPeriod:    100 * time.Millisecond,
+		}
+		go func() {
+			result <- RunWithLeaderElection(ctx, kubeClient, config, func(ctx context.Context) error {
+				mutex.Lock()
+				leading = append(leading, identity)
+				mutex.Unlock()
+				<-ctx.Done()
+				return nil
+			})
+		}()
+		return result
+	}
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	first := replica(firstCtx, "first")
+	eventually(t, "the first replica to lead", func() bool { return isLeading("first") })
+
+	secondCtx, cancelSecond := context.WithCancel(context.Background())
+	defer cancelSecond()
+	second := replica(secondCtx, "second")
+
+	time.Sleep(300 * time.Millisecond)
+	if isLeading("second") {
+		t.Fatalf("Only one replica should lead at a time")
+	}
+
+	cancelFirst()
+	if err := <-first; err != nil {
+		t.Fatalf("A replica stopped on purpose should not report an error: %v", err)
+	}
+	eventually(t, "the second replica to take over", func() bool { return isLeading("second") })
+
+	cancelSecond()
+	if err := <-second; err != nil {
+		t.Fatal(err)
+	}
+}
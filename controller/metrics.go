@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	resyncsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kongo_resyncs_total",
+		Help: "Full resyncs of the registered Kubernetes Services with Kong, by result.",
+	}, []string{"result"})
+
+	orphansPending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kongo_orphans_pending",
+		Help: "Kong entities without a Kubernetes Service, waiting out the grace period before being collected.",
+	})
+
+	orphansCollectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kongo_orphans_collected_total",
+		Help: "Kong entities deleted because their Kubernetes Service no longer exists, by kind.",
+	}, []string{"kind"})
+)
+
+// RegisterMetrics registers the metrics of the resyncs and of the collection of orphans with the registerer.
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{resyncsTotal, orphansPending, orphansCollectedTotal} {
+		err := registerer.Register(collector)
+		if err != nil {
+			return fmt.Errorf("error registering the controller metrics: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"time"
+)
+
+// keyQueue hands namespace/name keys to sync, retrying failed keys with backoff.
+type keyQueue struct {
+	queue workqueue.TypedRateLimitingInterface[string]
+	sync  func(key string) error
+}
+
+func newKeyQueue(name string, sync func(key string) error) *keyQueue {
+	return &keyQueue{
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: name},
+		),
+		sync: sync,
+	}
+}
+
+func (keyQueue *keyQueue) add(key string) {
+	keyQueue.queue.Add(key)
+}
+
+func (keyQueue *keyQueue) addObject(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	keyQueue.queue.Add(key)
+}
+
+func (keyQueue *keyQueue) eventHandler() cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    keyQueue.addObject,
+		UpdateFunc: func(_, newObj interface{}) { keyQueue.addObject(newObj) },
+		DeleteFunc: keyQueue.addObject,
+	}
+}
+
+// run blocks until the context is cancelled, then shuts the queue down.
+func (keyQueue *keyQueue) run(ctx context.Context, workers int) {
+	defer keyQueue.queue.ShutDown()
+
+	for i := 0; i < workers; i++ {
+		go wait.UntilWithContext(ctx, keyQueue.runWorker, time.Second)
+	}
+
+	<-ctx.Done()
+}
+
+func (keyQueue *keyQueue) runWorker(ctx context.Context) {
+	for keyQueue.processNextItem() {
+	}
+}
+
+func (keyQueue *keyQueue) processNextItem() bool {
+	key, shutdown := keyQueue.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer keyQueue.queue.Done(key)
+
+	err := keyQueue.sync(key)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("error syncing '%s', requeuing: %v", key, err))
+		keyQueue.queue.AddRateLimited(key)
+		return true
+	}
+
+	keyQueue.queue.Forget(key)
+	return true
+}
+
+func newEventRecorder(kubeClient kubernetes.Interface) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "kongo"})
+}
@@ -0,0 +1,203 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"github.com/ciroque/kongo/client"
+	jsoniter "github.com/json-iterator/go"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultConsulWait bounds how long a blocking query waits for a change.
+	DefaultConsulWait       = 5 * time.Minute
+	consulMinRetryDelay     = time.Second
+	consulMaxRetryDelay     = time.Minute
+	consulIndexHeader       = "X-Consul-Index"
+	consulTokenHeader       = "X-Consul-Token"
+	consulHealthServicePath = "/v1/health/service/"
+)
+
+// ConsulService is a service whose Targets are the healthy instances of a service of the Consul catalog.
+type ConsulService struct {
+	// Name is the base name of the service.
+	Name    string
+	Service string
+	// Path defaults to `/name`.
+	Path string
+}
+
+// ConsulSource discovers services through the health API of Consul, watching them with blocking queries.
+type ConsulSource struct {
+	address    string
+	token      string
+	wait       time.Duration
+	services   []ConsulService
+	httpClient *http.Client
+	logger     client.Logger
+}
+
+// NewConsulSource talks to the Consul agent at the address, e.g. `http://localhost:8500`, with the ACL token when given.
+// It logs with the logger, slog.Default() when nil.
+func NewConsulSource(address string, token string, logger client.Logger, services ...ConsulService) *ConsulSource {
+	return &ConsulSource{
+		address:    strings.TrimSuffix(address, "/"),
+		token:      token,
+		wait:       DefaultConsulWait,
+		services:   services,
+		httpClient: &http.Client{},
+		logger:     orDefault(logger),
+	}
+}
+
+// consulServiceEntry is the part of an entry of /v1/health/service kongo uses.
+type consulServiceEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+		Weights struct {
+			Passing int
+		}
+	}
+}
+
+func (source *ConsulSource) Name() string {
+	return "consul " + source.address
+}
+
+// Discover turns the instances passing their health checks into Targets, weighted by their passing weight, addressed
+// by the address of the service or else that of its node. A service without healthy instances is kept without Targets.
+func (source *ConsulSource) Discover(ctx context.Context) ([]*client.K8sService, error) {
+	k8sServices := []*client.K8sService{}
+	for _, service := range source.services {
+		entries, _, err := source.healthyInstances(ctx, service.Service, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		k8sService := &client.K8sService{
+			Name: service.Name,
+			Path: defaultString(service.Path, "/"+service.Name),
+		}
+		for _, entry := range entries {
+			address := entry.Service.Address
+			if address == "" {
+				address = entry.Node.Address
+			}
+			weight := entry.Service.Weights.Passing
+			if weight == 0 {
+				weight = 1
+			}
+			k8sService.Endpoints = append(k8sService.Endpoints, &client.K8sEndpoint{
+				Address: address,
+				Port:    entry.Service.Port,
+				Weight:  weight,
+			})
+		}
+		if len(entries) > 0 {
+			k8sService.Port = entries[0].Service.Port
+		}
+		k8sServices = append(k8sServices, k8sService)
+	}
+	return k8sServices, nil
+}
+
+// Watch runs a blocking query per service, signalling a change whenever the index Consul returns moves. Failed queries
+// are retried with a growing delay.
+func (source *ConsulSource) Watch(ctx context.Context, changes chan<- struct{}) error {
+	for _, service := range source.services {
+		go source.watchService(ctx, service.Service, changes)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (source *ConsulSource) watchService(ctx context.Context, service string, changes chan<- struct{}) {
+	index := uint64(0)
+	retryDelay := consulMinRetryDelay
+	for ctx.Err() == nil {
+		_, newIndex, err := source.healthyInstances(ctx, service, index)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			source.logger.Warn("watching Consul service failed", "service", service, "retry", retryDelay, "error", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryDelay):
+			}
+			retryDelay *= 2
+			if retryDelay > consulMaxRetryDelay {
+				retryDelay = consulMaxRetryDelay
+			}
+			continue
+		}
+		retryDelay = consulMinRetryDelay
+
+		switch {
+		case newIndex < index:
+			// The index went backwards, as it does when Consul restores a snapshot; start over.
+			index = 0
+			notify(changes)
+		case newIndex > index:
+			if index != 0 {
+				notify(changes)
+			}
+			index = newIndex
+		}
+	}
+}
+
+// healthyInstances queries the instances of the service passing their health checks, blocking until the index moves
+// past the given one when it is not 0.
+func (source *ConsulSource) healthyInstances(ctx context.Context, service string, index uint64) ([]*consulServiceEntry, uint64, error) {
+	query := url.Values{"passing": {"true"}}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", strconv.Itoa(int(source.wait.Seconds()))+"s")
+	}
+	requestUrl := source.address + consulHealthServicePath + url.PathEscape(service) + "?" + query.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error querying Consul service '%s': %v", service, err)
+	}
+	if source.token != "" {
+		request.Header.Set(consulTokenHeader, source.token)
+	}
+
+	response, err := source.httpClient.Do(request)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error querying Consul service '%s': %v", service, err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading Consul service '%s': %v", service, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("error querying Consul service '%s': %s: %s", service, response.Status, strings.TrimSpace(string(body)))
+	}
+
+	entries := []*consulServiceEntry{}
+	err = jsoniter.Unmarshal(body, &entries)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error parsing Consul service '%s': %v", service, err)
+	}
+
+	newIndex, err := strconv.ParseUint(response.Header.Get(consulIndexHeader), 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error parsing the index of Consul service '%s': %v", service, err)
+	}
+	return entries, newIndex, nil
+}
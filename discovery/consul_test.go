@@ -0,0 +1,128 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConsul serves /v1/health/service/orders, blocking queries waiting for the index to move.
+type fakeConsul struct {
+	mutex   sync.Mutex
+	index   uint64
+	body    string
+	changed chan struct{}
+}
+
+func (consul *fakeConsul) set(body string) {
+	consul.mutex.Lock()
+	defer consul.mutex.Unlock()
+	consul.index++
+	consul.body = body
+	close(consul.changed)
+	consul.changed = make(chan struct{})
+}
+
+func (consul *fakeConsul) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.URL.Path != "/v1/health/service/orders" || request.URL.Query().Get("passing") != "true" {
+		http.NotFound(writer, request)
+		return
+	}
+	if request.Header.Get("X-Consul-Token") != "secret" {
+		http.Error(writer, "ACL not found", http.StatusForbidden)
+		return
+	}
+
+	consul.mutex.Lock()
+	index, changed := consul.index, consul.changed
+	consul.mutex.Unlock()
+
+	wanted, _ := strconv.ParseUint(request.URL.Query().Get("index"), 10, 64)
+	if wanted >= index {
+		select {
+		case <-changed:
+		case <-request.Context().Done():
+			return
+		}
+	}
+
+	consul.mutex.Lock()
+	defer consul.mutex.Unlock()
+	writer.Header().Set("X-Consul-Index", strconv.FormatUint(consul.index, 10))
+	fmt.Fprint(writer, consul.body)
+}
+
+const consulEntries = `[
+	{"Node": {"Address": "10.0.0.1"}, "Service": {"Address": "", "Port": 8080, "Weights": {"Passing": 3}}},
+	{"Node": {"Address": "10.0.0.9"}, "Service": {"Address": "10.0.0.2", "Port": 8080, "Weights": {"Passing": 0}}}
+]`
+
+func newFakeConsul(t *testing.T) (*fakeConsul, string) {
+	consul := &fakeConsul{changed: make(chan struct{})}
+	consul.set(consulEntries)
+	server := httptest.NewServer(consul)
+	t.Cleanup(server.Close)
+	return consul, server.URL
+}
+
+func TestConsulSourceDiscovers(t *testing.T) {
+	_, address := newFakeConsul(t)
+
+	source := NewConsulSource(address, "secret", nil, ConsulService{Name: "legacy.orders", Service: "orders"})
+	k8sServices, err := source.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(k8sServices) != 1 {
+		t.Fatalf("The service should be discovered: %d", len(k8sServices))
+	}
+
+	orders := k8sServices[0]
+	if orders.Name != "legacy.orders" || orders.Path != "/legacy.orders" || orders.Port != 8080 || len(orders.Endpoints) != 2 {
+		t.Fatalf("Unexpected service: %+v", orders)
+	}
+	if orders.Endpoints[0].Target() != "10.0.0.1:8080" || orders.Endpoints[0].Weight != 3 {
+		t.Fatalf("An instance without an address should be reached at its node: %+v", orders.Endpoints[0])
+	}
+	if orders.Endpoints[1].Target() != "10.0.0.2:8080" || orders.Endpoints[1].Weight != 1 {
+		t.Fatalf("An instance should be reached at its address, weighted at least 1: %+v", orders.Endpoints[1])
+	}
+
+	_, err = NewConsulSource(address, "", nil, ConsulService{Name: "orders", Service: "orders"}).Discover(context.Background())
+	if err == nil {
+		t.Fatalf("A failed query should fail the discovery")
+	}
+}
+
+func TestConsulSourceWatches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	consul, address := newFakeConsul(t)
+	source := NewConsulSource(address, "secret", nil, ConsulService{Name: "orders", Service: "orders"})
+	changes := make(chan struct{}, 1)
+	go source.Watch(ctx, changes)
+
+	select {
+	case <-changes:
+		t.Fatalf("The first query should not signal a change")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	consul.set("[]")
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the change in Consul")
+	}
+
+	k8sServices, err := source.Discover(ctx)
+	if err != nil || len(k8sServices[0].Endpoints) != 0 {
+		t.Fatalf("A service without healthy instances should have no Targets: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"github.com/ciroque/kongo/client"
+	"net"
+	"strings"
+)
+
+// SRVService is a service whose Targets are those of an SRV record, e.g. `_http._tcp.orders.example.com`.
+type SRVService struct {
+	// Name is the base name of the service.
+	Name   string
+	Record string
+	// Path defaults to `/name`.
+	Path string
+}
+
+// DNSSource discovers services by looking up SRV records. It cannot watch them, and is polled.
+type DNSSource struct {
+	services []SRVService
+	resolver *net.Resolver
+}
+
+// NewDNSSource queries the DNS server at the given host:port, or the resolvers of the system when it is empty.
+func NewDNSSource(server string, services ...SRVService) *DNSSource {
+	resolver := net.DefaultResolver
+	if server != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network string, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, server)
+			},
+		}
+	}
+	return &DNSSource{services: services, resolver: resolver}
+}
+
+func (source *DNSSource) Name() string {
+	return "dns"
+}
+
+// Discover only keeps the records of the lowest priority, those clients are meant to use while they answer. A weight of
+// 0 becomes 1, since Kong sends no requests to a Target of weight 0. Any failed lookup fails the whole discovery.
+func (source *DNSSource) Discover(ctx context.Context) ([]*client.K8sService, error) {
+	k8sServices := []*client.K8sService{}
+	for _, service := range source.services {
+		_, records, err := source.resolver.LookupSRV(ctx, "", "", service.Record)
+		if err != nil {
+			return nil, fmt.Errorf("error looking up '%s': %v", service.Record, err)
+		}
+
+		k8sService := &client.K8sService{
+			Name: service.Name,
+			Path: defaultString(service.Path, "/"+service.Name),
+		}
+		for _, record := range records {
+			if record.Priority != records[0].Priority {
+				continue
+			}
+			weight := int(record.Weight)
+			if weight == 0 {
+				weight = 1
+			}
+			k8sService.Endpoints = append(k8sService.Endpoints, &client.K8sEndpoint{
+				Address: strings.TrimSuffix(record.Target, "."),
+				Port:    int(record.Port),
+				Weight:  weight,
+			})
+		}
+		if len(records) > 0 {
+			k8sService.Port = int(records[0].Port)
+		}
+		k8sServices = append(k8sServices, k8sService)
+	}
+	return k8sServices, nil
+}
+
+func (source *DNSSource) Watch(ctx context.Context, changes chan<- struct{}) error {
+	return nil
+}
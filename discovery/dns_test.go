@@ -0,0 +1,84 @@
+package discovery
+
+import (
+	"context"
+	"github.com/miekg/dns"
+	"net"
+	"testing"
+)
+
+// startDNSServer answers SRV queries from the records, by name, and NXDOMAIN otherwise.
+func startDNSServer(t *testing.T, records map[string][]*dns.SRV) string {
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	server := &dns.Server{
+		PacketConn:        packetConn,
+		NotifyStartedFunc: func() { close(started) },
+		Handler: dns.HandlerFunc(func(writer dns.ResponseWriter, request *dns.Msg) {
+			response := new(dns.Msg)
+			response.SetReply(request)
+			question := request.Question[0]
+			answers, found := records[question.Name]
+			if !found {
+				response.Rcode = dns.RcodeNameError
+			}
+			if question.Qtype == dns.TypeSRV {
+				for _, answer := range answers {
+					answer.Hdr = dns.RR_Header{Name: question.Name, Rrtype: dns.TypeSRV, Class: dns.ClassINET, Ttl: 30}
+					response.Answer = append(response.Answer, answer)
+				}
+			}
+			writer.WriteMsg(response)
+		}),
+	}
+	go server.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { server.Shutdown() })
+
+	return packetConn.LocalAddr().String()
+}
+
+func TestDNSSourceDiscovers(t *testing.T) {
+	server := startDNSServer(t, map[string][]*dns.SRV{
+		"_http._tcp.orders.example.com.": {
+			{Priority: 10, Weight: 5, Port: 8080, Target: "a.orders.example.com."},
+			{Priority: 10, Weight: 0, Port: 8080, Target: "b.orders.example.com."},
+			{Priority: 20, Weight: 5, Port: 8080, Target: "backup.orders.example.com."},
+		},
+	})
+
+	source := NewDNSSource(server, SRVService{Name: "orders", Record: "_http._tcp.orders.example.com"})
+	k8sServices, err := source.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(k8sServices) != 1 {
+		t.Fatalf("The service should be discovered: %d", len(k8sServices))
+	}
+
+	orders := k8sServices[0]
+	if orders.Name != "orders" || orders.Path != "/orders" || orders.Port != 8080 {
+		t.Fatalf("Unexpected service: %+v", orders)
+	}
+	weights := make(map[string]int)
+	for _, endpoint := range orders.Endpoints {
+		weights[endpoint.Target()] = endpoint.Weight
+	}
+	if len(weights) != 2 || weights["a.orders.example.com:8080"] != 5 || weights["b.orders.example.com:8080"] != 1 {
+		t.Fatalf("Only the records of the lowest priority should be Targets, weighted at least 1: %v", weights)
+	}
+}
+
+func TestDNSSourceFailsOnMissingRecords(t *testing.T) {
+	server := startDNSServer(t, map[string][]*dns.SRV{})
+
+	source := NewDNSSource(server, SRVService{Name: "orders", Record: "_http._tcp.orders.example.com"})
+	_, err := source.Discover(context.Background())
+	if err == nil {
+		t.Fatalf("A failed lookup should fail the discovery")
+	}
+}
@@ -0,0 +1,207 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"github.com/ciroque/kongo/client"
+	"github.com/fsnotify/fsnotify"
+	"github.com/hbagdi/go-kong/kong"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+)
+
+// DiscoveryFile is the content of the file of a FileSource, in YAML or JSON.
+type DiscoveryFile struct {
+	Services []*FileService `json:"services"`
+}
+
+// FileService is a service of a DiscoveryFile. Its fields describe a single port with a single Route, unless Ports are
+// given, as those of client.K8sService do. Namespace is empty by default, which keeps the garbage collection of the
+// controller, looking for Kubernetes Services, away from the service.
+type FileService struct {
+	Namespace string      `json:"namespace,omitempty"`
+	Name      string      `json:"name"`
+	Port      int         `json:"port,omitempty"`
+	Path      string      `json:"path,omitempty"`
+	Targets   []string    `json:"targets,omitempty"`
+	Hosts     []string    `json:"hosts,omitempty"`
+	Methods   []string    `json:"methods,omitempty"`
+	StripPath bool        `json:"stripPath,omitempty"`
+	Algorithm string      `json:"algorithm,omitempty"`
+	Ports     []*FilePort `json:"ports,omitempty"`
+}
+
+type FilePort struct {
+	Name      string       `json:"name,omitempty"`
+	Port      int          `json:"port"`
+	Path      string       `json:"path,omitempty"`
+	Targets   []string     `json:"targets,omitempty"`
+	Algorithm string       `json:"algorithm,omitempty"`
+	Routes    []*FileRoute `json:"routes,omitempty"`
+}
+
+type FileRoute struct {
+	Name      string   `json:"name,omitempty"`
+	Paths     []string `json:"paths,omitempty"`
+	Hosts     []string `json:"hosts,omitempty"`
+	Methods   []string `json:"methods,omitempty"`
+	StripPath bool     `json:"stripPath,omitempty"`
+}
+
+// FileSource discovers the services of a DiscoveryFile, watching it for changes.
+type FileSource struct {
+	path string
+}
+
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: filepath.Clean(path)}
+}
+
+func (source *FileSource) Name() string {
+	return "file " + source.path
+}
+
+func (source *FileSource) Discover(ctx context.Context) ([]*client.K8sService, error) {
+	content, err := ioutil.ReadFile(source.path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading '%s': %v", source.path, err)
+	}
+
+	discoveryFile := new(DiscoveryFile)
+	err = yaml.UnmarshalStrict(content, discoveryFile)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing '%s': %v", source.path, err)
+	}
+
+	k8sServices := []*client.K8sService{}
+	seen := make(map[string]bool)
+	for _, fileService := range discoveryFile.Services {
+		k8sService, err := fileService.K8sService()
+		if err != nil {
+			return nil, fmt.Errorf("invalid service in '%s': %v", source.path, err)
+		}
+		if seen[k8sService.Name] {
+			return nil, fmt.Errorf("invalid service in '%s': '%s' is declared twice", source.path, k8sService.Name)
+		}
+		seen[k8sService.Name] = true
+		k8sServices = append(k8sServices, k8sService)
+	}
+	return k8sServices, nil
+}
+
+// Watch watches the directory of the file, editors often replacing a file rather than writing to it.
+func (source *FileSource) Watch(ctx context.Context, changes chan<- struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating watcher: %v", err)
+	}
+	defer watcher.Close()
+
+	err = watcher.Add(filepath.Dir(source.path))
+	if err != nil {
+		return fmt.Errorf("error watching '%s': %v", filepath.Dir(source.path), err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return fmt.Errorf("watcher of '%s' closed", source.path)
+			}
+			if filepath.Clean(event.Name) == source.path {
+				notify(changes)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return fmt.Errorf("watcher of '%s' closed", source.path)
+			}
+			return fmt.Errorf("error watching '%s': %v", source.path, err)
+		}
+	}
+}
+
+// K8sService defaults the path to `/namespace/name`, as the controller does, and the paths of Routes to that of their
+// port.
+func (fileService *FileService) K8sService() (*client.K8sService, error) {
+	if fileService.Name == "" {
+		return nil, fmt.Errorf("a service needs a name")
+	}
+
+	baseName := fileService.Name
+	path := "/" + fileService.Name
+	if fileService.Namespace != "" {
+		baseName = fileService.Namespace + "." + fileService.Name
+		path = "/" + fileService.Namespace + path
+	}
+
+	if len(fileService.Ports) == 0 {
+		addresses, err := targetAddresses(baseName, fileService.Targets)
+		if err != nil {
+			return nil, err
+		}
+		return &client.K8sService{
+			Addresses: addresses,
+			Name:      baseName,
+			Path:      defaultString(fileService.Path, path),
+			Port:      fileService.Port,
+			StripPath: fileService.StripPath,
+			Hosts:     kong.StringSlice(fileService.Hosts...),
+			Methods:   kong.StringSlice(fileService.Methods...),
+			Algorithm: fileService.Algorithm,
+		}, nil
+	}
+
+	k8sService := &client.K8sService{Name: baseName}
+	for _, filePort := range fileService.Ports {
+		addresses, err := targetAddresses(baseName, filePort.Targets)
+		if err != nil {
+			return nil, err
+		}
+		port := &client.K8sServicePort{
+			Name:      filePort.Name,
+			Port:      filePort.Port,
+			Path:      defaultString(filePort.Path, path),
+			Addresses: addresses,
+			Algorithm: filePort.Algorithm,
+		}
+		for _, fileRoute := range filePort.Routes {
+			paths := fileRoute.Paths
+			if len(paths) == 0 {
+				paths = []string{port.Path}
+			}
+			port.Routes = append(port.Routes, &client.K8sRoute{
+				Name:      fileRoute.Name,
+				Paths:     kong.StringSlice(paths...),
+				StripPath: fileRoute.StripPath,
+				Hosts:     kong.StringSlice(fileRoute.Hosts...),
+				Methods:   kong.StringSlice(fileRoute.Methods...),
+			})
+		}
+		if len(port.Routes) == 0 {
+			port.Routes = []*client.K8sRoute{{Paths: kong.StringSlice(port.Path)}}
+		}
+		k8sService.Ports = append(k8sService.Ports, port)
+	}
+	return k8sService, nil
+}
+
+func targetAddresses(baseName string, targets []string) ([]*string, error) {
+	for _, target := range targets {
+		_, _, err := net.SplitHostPort(target)
+		if err != nil {
+			return nil, fmt.Errorf("target '%s' of '%s' is not host:port: %v", target, baseName, err)
+		}
+	}
+	return kong.StringSlice(targets...), nil
+}
+
+func defaultString(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,114 @@
+package discovery
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const discoveryYAML = `
+services:
+- name: billing
+  port: 80
+  targets: ["10.0.0.1:8080", "10.0.0.2:8080"]
+- namespace: legacy
+  name: orders
+  ports:
+  - name: http
+    port: 80
+    targets: ["orders.internal:8080"]
+    routes:
+    - paths: ["/orders"]
+    - name: admin
+      paths: ["/orders/admin"]
+      methods: ["GET"]
+  - name: grpc
+    port: 9090
+    targets: ["orders.internal:9090"]
+`
+
+func writeDiscoveryFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "kongo-discovery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "services.yaml")
+	err = ioutil.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileSourceDiscovers(t *testing.T) {
+	source := NewFileSource(writeDiscoveryFile(t, discoveryYAML))
+
+	k8sServices, err := source.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(k8sServices) != 2 {
+		t.Fatalf("Both services should be discovered: %d", len(k8sServices))
+	}
+
+	billing := k8sServices[0]
+	if billing.Name != "billing" || billing.Path != "/billing" || len(billing.Addresses) != 2 {
+		t.Fatalf("The single port form should be kept: %+v", billing)
+	}
+
+	orders := k8sServices[1]
+	if orders.Name != "legacy.orders" || len(orders.Ports) != 2 {
+		t.Fatalf("The ports should be kept: %+v", orders)
+	}
+	if routes := orders.Ports[0].Routes; len(routes) != 2 || routes[1].Name != "admin" || *routes[1].Methods[0] != "GET" {
+		t.Fatalf("The routes of the port should be kept: %+v", routes)
+	}
+	if grpc := orders.Ports[1]; grpc.Path != "/legacy/orders" || len(grpc.Routes) != 1 || *grpc.Routes[0].Paths[0] != "/legacy/orders" {
+		t.Fatalf("A port without routes should get one on its path: %+v", grpc)
+	}
+}
+
+func TestFileSourceRejectsInvalidFiles(t *testing.T) {
+	for _, content := range []string{
+		"services:\n- port: 80\n",
+		"services:\n- name: billing\n  targets: [\"10.0.0.1\"]\n",
+		"services:\n- name: billing\n- name: billing\n",
+		"services:\n- name: billing\n  target: \"10.0.0.1:80\"\n",
+	} {
+		_, err := NewFileSource(writeDiscoveryFile(t, content)).Discover(context.Background())
+		if err == nil {
+			t.Fatalf("'%s' should be rejected", content)
+		}
+	}
+}
+
+func TestFileSourceWatches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	path := writeDiscoveryFile(t, discoveryYAML)
+	source := NewFileSource(path)
+	changes := make(chan struct{}, 1)
+	go source.Watch(ctx, changes)
+
+	deadline := time.After(5 * time.Second)
+	for {
+		// Rewritten until seen, the watcher being set up concurrently.
+		err := ioutil.WriteFile(path, []byte("services: []\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-changes:
+			return
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("Timed out waiting for the change to the file")
+		}
+	}
+}
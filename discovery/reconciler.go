@@ -0,0 +1,125 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"github.com/ciroque/kongo/client"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const DefaultInterval = 30 * time.Second
+
+// Registrar is the part of client.Kongo the Reconciler drives.
+type Registrar interface {
+	SyncK8sService(k8sService *client.K8sService) (*client.RegisteredKongResources, error)
+	DeregisterK8sService(baseName string) error
+}
+
+// Reconciler keeps the registrations in Kong in line with the services of its sources, reconciling whenever a source
+// signals a change and every interval.
+type Reconciler struct {
+	registrar Registrar
+	sources   []DiscoverySource
+	interval  time.Duration
+	logger    client.Logger
+	// registered holds the names of the services registered, by source. Services a source stopped discovering while
+	// no Reconciler was running are not known, and stay registered.
+	registered map[string]map[string]bool
+}
+
+// NewReconciler logs with the logger, slog.Default() when nil.
+func NewReconciler(registrar Registrar, interval time.Duration, logger client.Logger, sources ...DiscoverySource) *Reconciler {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Reconciler{
+		registrar:  registrar,
+		sources:    sources,
+		interval:   interval,
+		logger:     orDefault(logger),
+		registered: make(map[string]map[string]bool),
+	}
+}
+
+func orDefault(logger client.Logger) client.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
+
+// Run blocks until the context is cancelled.
+func (reconciler *Reconciler) Run(ctx context.Context) error {
+	changes := make(chan struct{}, 1)
+	for _, source := range reconciler.sources {
+		go func(source DiscoverySource) {
+			err := source.Watch(ctx, changes)
+			if err != nil && ctx.Err() == nil {
+				reconciler.logger.Warn("watching failed, polling instead", "source", source.Name(), "interval", reconciler.interval,
+					"error", err)
+			}
+		}(source)
+	}
+
+	ticker := time.NewTicker(reconciler.interval)
+	defer ticker.Stop()
+
+	reconciler.logger.Info("kongo reconciler started", "sources", len(reconciler.sources))
+	for {
+		err := reconciler.Reconcile(ctx)
+		if err != nil {
+			reconciler.logger.Error("reconciling failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			reconciler.logger.Info("kongo reconciler stopping")
+			return nil
+		case <-changes:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile syncs every service the sources discover and deregisters those they no longer do. A source failing to
+// discover is skipped, keeping its services as they are.
+func (reconciler *Reconciler) Reconcile(ctx context.Context) error {
+	failures := []string{}
+	for _, source := range reconciler.sources {
+		k8sServices, err := source.Discover(ctx)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("error discovering %s: %v", source.Name(), err))
+			continue
+		}
+
+		discovered := make(map[string]bool)
+		for _, k8sService := range k8sServices {
+			discovered[k8sService.Name] = true
+			_, err := reconciler.registrar.SyncK8sService(k8sService)
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("error registering '%s' of %s: %v", k8sService.Name, source.Name(), err))
+			}
+		}
+
+		for baseName := range reconciler.registered[source.Name()] {
+			if discovered[baseName] {
+				continue
+			}
+			reconciler.logger.Info("deregistering service no longer discovered", "service", baseName, "source", source.Name())
+			err := reconciler.registrar.DeregisterK8sService(baseName)
+			if err != nil {
+				// Kept as registered, so that the next reconcile tries again.
+				discovered[baseName] = true
+				failures = append(failures, fmt.Sprintf("error deregistering '%s' of %s: %v", baseName, source.Name(), err))
+			}
+		}
+		reconciler.registered[source.Name()] = discovered
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("reconciling failed: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
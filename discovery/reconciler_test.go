@@ -0,0 +1,69 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/ciroque/kongo/client"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type fakeRegistrar struct {
+	registered map[string]*client.K8sService
+}
+
+func (registrar *fakeRegistrar) SyncK8sService(k8sService *client.K8sService) (*client.RegisteredKongResources, error) {
+	registrar.registered[k8sService.Name] = k8sService
+	return &client.RegisteredKongResources{}, nil
+}
+
+func (registrar *fakeRegistrar) DeregisterK8sService(baseName string) error {
+	delete(registrar.registered, baseName)
+	return nil
+}
+
+type fakeSource struct {
+	k8sServices []*client.K8sService
+	err         error
+}
+
+func (source *fakeSource) Name() string {
+	return "fake"
+}
+
+func (source *fakeSource) Discover(ctx context.Context) ([]*client.K8sService, error) {
+	return source.k8sServices, source.err
+}
+
+func (source *fakeSource) Watch(ctx context.Context, changes chan<- struct{}) error {
+	return nil
+}
+
+func TestReconcilerFollowsSources(t *testing.T) {
+	registrar := &fakeRegistrar{registered: make(map[string]*client.K8sService)}
+	source := &fakeSource{k8sServices: []*client.K8sService{{Name: "orders"}, {Name: "billing"}}}
+	var logged bytes.Buffer
+	reconciler := NewReconciler(registrar, 0, slog.New(slog.NewTextHandler(&logged, nil)), source)
+
+	err := reconciler.Reconcile(context.Background())
+	if err != nil || len(registrar.registered) != 2 {
+		t.Fatalf("Both services should be registered: %v", err)
+	}
+
+	source.k8sServices, source.err = nil, fmt.Errorf("unavailable")
+	err = reconciler.Reconcile(context.Background())
+	if err == nil || len(registrar.registered) != 2 {
+		t.Fatalf("A failing source should keep its services registered: %v", err)
+	}
+
+	source.k8sServices, source.err = []*client.K8sService{{Name: "orders"}}, nil
+	err = reconciler.Reconcile(context.Background())
+	if err != nil || len(registrar.registered) != 1 || registrar.registered["orders"] == nil {
+		t.Fatalf("The service no longer discovered should be deregistered: %v", registrar.registered)
+	}
+	if !strings.Contains(logged.String(), "service=billing") {
+		t.Fatalf("The deregistration should be logged with the logger given, got: %s", logged.String())
+	}
+}
@@ -0,0 +1,27 @@
+package discovery
+
+import (
+	"context"
+	"github.com/ciroque/kongo/client"
+)
+
+// DiscoverySource finds services to register with Kong outside of Kubernetes. The names of the services it discovers are
+// base names, as handed to client.Kongo, and must not clash with those of other sources.
+type DiscoverySource interface {
+	// Name identifies the source in logs.
+	Name() string
+	// Discover lists the services currently found. A source failing to discover returns an error rather than fewer
+	// services, so that the services it found before are left registered.
+	Discover(ctx context.Context) ([]*client.K8sService, error)
+	// Watch signals on changes whenever the discovered services may have changed, until the context is cancelled.
+	// Sources that cannot watch return nil at once and are only polled.
+	Watch(ctx context.Context, changes chan<- struct{}) error
+}
+
+// notify signals a change without blocking, a change already pending covering this one.
+func notify(changes chan<- struct{}) {
+	select {
+	case changes <- struct{}{}:
+	default:
+	}
+}
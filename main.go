@@ -2,21 +2,87 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
+	"github.com/ciroque/kongo/client"
+	"github.com/ciroque/kongo/controller"
+	"github.com/ciroque/kongo/discovery"
 	"github.com/hbagdi/go-kong/kong"
 	jsoniter "github.com/json-iterator/go"
-	"github.com/ciroque/kongo/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"io"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 )
 
 type Arguments struct {
-	KongUri     *string
-	Command     *string
-	Namespace   *string
-	ServiceName *string
+	KongUri            *string
+	Command            *string
+	Namespace          *string
+	ServiceName        *string
+	File               *string
+	SelectTags         *string
+	BackupDir          *string
+	Compress           *bool
+	Snapshot           *string
+	Conflict           *string
+	Kubeconfig         *string
+	Workers            *int
+	NotReady           *string
+	Ingress            *bool
+	IngressClass       *string
+	CRDs               *bool
+	LeaderElect        *bool
+	LeaseName          *string
+	LeaseNamespace     *string
+	LeaseDuration      *time.Duration
+	RenewDeadline      *time.Duration
+	RetryPeriod        *time.Duration
+	FullResyncInterval *time.Duration
+	GCGracePeriod      *time.Duration
+	GCUntagged         *bool
+	NamingTemplate     *string
+	Cluster            *string
+	DiscoveryFile      *string
+	SRVRecords         *string
+	DNSServer          *string
+	DiscoveryInterval  *time.Duration
+	ConsulAddress      *string
+	ConsulServices     *string
+	FanOutPolicy       *string
+	Left               *string
+	Right              *string
+	Ignore             *string
+	Retries            *int
+	RetryMaxDelay      *time.Duration
+	RateLimit          *float64
+	RateBurst          *int
+	BreakerThreshold   *int
+	BreakerOpenTimeout *time.Duration
+	Parallelism        *int
+	MetricsAddress     *string
+	TraceExporter      *string
+	LogLevel           *string
+	LogFormat          *string
+	AuditFile          *string
+	AuditWebhook       *string
+	AuditOperator      *string
+	DryRun             *bool
 }
 
 func (a Arguments) String() string {
@@ -26,46 +92,286 @@ func (a Arguments) String() string {
 
 var arguments Arguments
 
+// auditSinks are shared by every Kongo of the commands, none unless -auditFile or -auditWebhook is set.
+var auditSinks []client.AuditSink
+
 type Command struct {
 	function    func(kongo *client.Kongo, args Arguments) error
 	description string
+	// multiFunction runs the command against several Kong clusters, commands without one requiring a single -kongUri.
+	multiFunction func(multi *client.MultiKongo, args Arguments) error
 }
 
 func init() {
-	arguments.KongUri = flag.String("kongUri", "http://localhost:8001", "Url for the Kong admin API")
+	arguments.KongUri = flag.String("kongUri", "http://localhost:8001", "Url for the Kong admin API, or comma separated Urls of several Kong clusters")
+	arguments.FanOutPolicy = flag.String("fanOutPolicy", string(client.FanOutAll), "How many of several Kong clusters must succeed, one of all, best-effort or quorum")
+	arguments.Left = flag.String("left", "", "Url for the Admin API of the first Kong the compare command loads")
+	arguments.Right = flag.String("right", "", "Url for the Admin API of the second Kong the compare command loads")
+	arguments.Ignore = flag.String("ignore", strings.Join(client.DefaultIgnoredFields, ","), "Comma separated fields the compare command ignores, e.g. created_at or route.regex_priority")
+	arguments.Retries = flag.Int("retries", client.DefaultRetryPolicy.MaxAttempts, "How many times requests to Kong failing transiently are attempted, 1 to never retry")
+	arguments.RetryMaxDelay = flag.Duration("retryMaxDelay", client.DefaultRetryPolicy.MaxDelay, "The longest wait between attempts of a request to Kong")
+	arguments.RateLimit = flag.Float64("rateLimit", 0, "The most requests per second sent to Kong, 0 for no limit")
+	arguments.RateBurst = flag.Int("rateBurst", 10, "How many requests may be sent to Kong at once, within -rateLimit")
+	arguments.BreakerThreshold = flag.Int("breakerThreshold", 0, "How many requests to Kong have to fail in a row to stop sending any for -breakerOpenTimeout, 0 to keep sending")
+	arguments.BreakerOpenTimeout = flag.Duration("breakerOpenTimeout", client.DefaultCircuitBreakerSettings.OpenTimeout, "How long requests to Kong fail fast once -breakerThreshold is reached")
+	arguments.Parallelism = flag.Int("parallelism", client.DefaultParallelism, "How many requests bulk operations, like truncate, send to Kong at once")
+	arguments.MetricsAddress = flag.String("metricsAddress", "", "The address, e.g. :9090, the controller and reconcile commands serve Prometheus metrics on at /metrics, none when empty")
+	arguments.TraceExporter = flag.String("traceExporter", "none", "Where the OpenTelemetry spans of kongo are exported, none or stdout")
+	arguments.LogLevel = flag.String("log-level", "info", "The least severe logs written to stderr, one of debug, info, warn or error")
+	arguments.LogFormat = flag.String("log-format", "text", "The format of the logs written to stderr, text or json")
+	arguments.AuditFile = flag.String("auditFile", "", "A file every change made to Kong is appended to, as JSON lines, none when empty")
+	arguments.AuditWebhook = flag.String("auditWebhook", "", "A Url every change made to Kong is POSTed to, as JSON, none when empty")
+	arguments.AuditOperator = flag.String("auditOperator", "", "Who the changes made to Kong are audited as, the USER environment variable when empty")
+	arguments.DryRun = flag.Bool("dryRun", false, "Print the changes a command would make to Kong rather than making them, Kong still being read")
 	arguments.Command = flag.String("command", "usage", "Describes the usage of kongo")
 	arguments.Namespace = flag.String("namespace", "", "The target namespace")
 	arguments.ServiceName = flag.String("service", "", "The target service name")
+	arguments.File = flag.String("file", "kong.yaml", "The decK file to import from or export to")
+	arguments.SelectTags = flag.String("selectTags", "", "Comma separated tags limiting which entities are imported or exported")
+	arguments.BackupDir = flag.String("backupDir", "backups", "The directory timestamped backups are written to")
+	arguments.Compress = flag.Bool("compress", false, "Gzip the files of a backup")
+	arguments.Snapshot = flag.String("snapshot", "", "The backup directory to restore from")
+	arguments.Conflict = flag.String("conflict", "skip", "How restore handles existing entities, one of skip, overwrite or fail")
+	arguments.Kubeconfig = flag.String("kubeconfig", "", "Path to a kubeconfig, the in-cluster configuration is used when empty")
+	arguments.NotReady = flag.String("notReady", "drain", "What the controller does with the Targets of endpoints that are not ready, drain (weight 0) or remove")
+	arguments.Ingress = flag.Bool("ingress", false, "Have the controller also sync Ingresses of the ingress class with Kong")
+	arguments.IngressClass = flag.String("ingressClass", controller.DefaultIngressClass, "The ingress class of the Ingresses the controller syncs")
+	arguments.CRDs = flag.Bool("crds", false, "Have the controller also sync KongoRoutes, KongoPlugins and KongoUpstreamPolicies with Kong")
+	arguments.LeaderElect = flag.Bool("leaderElect", false, "Have controller replicas elect a leader through a Lease, only the leader reconciling")
+	arguments.LeaseName = flag.String("leaseName", controller.DefaultLeaseName, "The name of the leader election Lease")
+	arguments.LeaseNamespace = flag.String("leaseNamespace", "", "The namespace of the leader election Lease, the namespace of the pod when empty")
+	arguments.LeaseDuration = flag.Duration("leaseDuration", controller.DefaultLeaseDuration, "How long followers wait before taking over a Lease that is not renewed")
+	arguments.RenewDeadline = flag.Duration("renewDeadline", controller.DefaultRenewDeadline, "How long the leader retries renewing the Lease before giving it up")
+	arguments.RetryPeriod = flag.Duration("retryPeriod", controller.DefaultRetryPeriod, "How often replicas try to acquire or renew the Lease")
+	arguments.FullResyncInterval = flag.Duration("fullResyncInterval", controller.DefaultFullResyncInterval, "How often the controller resyncs every Service and looks for orphaned Kong entities, negative to never")
+	arguments.GCGracePeriod = flag.Duration("gcGracePeriod", controller.DefaultGCGracePeriod, "How long a Kong entity has to be orphaned before the controller deletes it")
+	arguments.GCUntagged = flag.Bool("gcUntagged", false, "Have the controller also delete untagged Kong entities named after Services that are gone, as registered by older versions of kongo")
+	arguments.NamingTemplate = flag.String("namingTemplate", "", "A text/template naming the Kong entities, e.g. '{{.Cluster}}-{{.Namespace}}-{{.Name}}-{{.Kind}}', namespace.name.kind when empty")
+	arguments.Cluster = flag.String("cluster", "", "The cluster name available to the naming template")
+	arguments.DiscoveryFile = flag.String("discoveryFile", "", "A YAML or JSON file of services the reconcile command registers, watched for changes")
+	arguments.SRVRecords = flag.String("srvRecords", "", "Comma separated name=record pairs of services the reconcile command registers from DNS SRV records")
+	arguments.DNSServer = flag.String("dnsServer", "", "The host:port of the DNS server SRV records are looked up with, the system resolvers when empty")
+	arguments.ConsulAddress = flag.String("consulAddress", "http://localhost:8500", "The address of the Consul agent the reconcile command queries, with the token of CONSUL_HTTP_TOKEN")
+	arguments.ConsulServices = flag.String("consulServices", "", "Comma separated name=service pairs of services the reconcile command registers from the Consul catalog")
+	arguments.DiscoveryInterval = flag.Duration("discoveryInterval", discovery.DefaultInterval, "How often the reconcile command polls its sources")
+	arguments.Workers = flag.Int("workers", 2, "The number of Kubernetes Services the controller reconciles concurrently")
 }
 
 func main() {
 
 	flag.Parse()
 
-	fmt.Println("arguments: ", arguments)
+	logger, err := newLogger(*arguments.LogLevel, *arguments.LogFormat)
+	if err != nil {
+		log.Fatal("Not so fast: ", err)
+	}
+	// The log package, which the controller and discovery log with, writes through the logger too.
+	slog.SetDefault(logger)
+
+	logger.Debug("parsed arguments", "arguments", arguments.String())
+
+	naming, err := namingStrategy(arguments)
+	if err != nil {
+		log.Fatal("Not so fast: ", err)
+	}
+
+	err = setupTracing(*arguments.TraceExporter)
+	if err != nil {
+		log.Fatal("Not so fast: ", err)
+	}
 
-	kongo, _ := client.NewKongo(arguments.KongUri)
+	auditSinks, err = newAuditSinks(arguments)
+	if err != nil {
+		log.Fatal("Not so fast: ", err)
+	}
 
 	commands := getCommands()
 	command, found := commands[*arguments.Command]
 	if !found {
 		command = commands["usage"]
 	}
-	err := command.function(kongo, arguments)
+
+	kongUris := strings.Split(*arguments.KongUri, ",")
+	if len(kongUris) > 1 && command.multiFunction != nil {
+		multi, err := newMultiKongo(kongUris, naming, arguments)
+		if err != nil {
+			log.Fatal("Not so fast: ", err)
+		}
+		err = command.multiFunction(multi, arguments)
+		closeAuditSinks()
+		for _, cluster := range multi.Clusters() {
+			printPlan(cluster, multi.Kongo(cluster))
+		}
+		if err != nil {
+			log.Fatal("Not so fast: ", err)
+		}
+		return
+	}
+	if len(kongUris) > 1 {
+		log.Fatalf("Not so fast: command '%s' supports a single -kongUri", *arguments.Command)
+	}
+
+	kongo, err := client.NewKongo(arguments.KongUri, kongoOptions(arguments)...)
 	if err != nil {
 		log.Fatal("Not so fast: ", err)
 	}
+	kongo.SetNamingStrategy(naming)
+
+	err = command.function(kongo, arguments)
+	closeAuditSinks()
+	printPlan(*arguments.KongUri, kongo)
+	if err != nil {
+		log.Fatal("Not so fast: ", err)
+	}
+}
+
+// printPlan prints the changes a dry run planned, even those of a command that failed along the way.
+func printPlan(cluster string, kongo *client.Kongo) {
+	if kongo.Plan() == nil {
+		return
+	}
+	fmt.Printf("Dry run against %s, Kong was not changed. ", cluster)
+	kongo.Plan().Write(os.Stdout)
+}
+
+// kongoOptions configures every Kongo of the commands.
+func kongoOptions(args Arguments) []client.Option {
+	retryPolicy := client.DefaultRetryPolicy
+	retryPolicy.MaxAttempts = *args.Retries
+	retryPolicy.MaxDelay = *args.RetryMaxDelay
+	options := []client.Option{
+		client.WithRetryPolicy(retryPolicy),
+		client.WithParallelism(*args.Parallelism),
+		client.WithLogger(slog.Default()),
+	}
+
+	if *args.RateLimit > 0 {
+		options = append(options, client.WithRateLimit(*args.RateLimit, *args.RateBurst))
+	}
+	if *args.BreakerThreshold > 0 {
+		options = append(options, client.WithCircuitBreaker(client.CircuitBreakerSettings{
+			FailureThreshold: *args.BreakerThreshold,
+			OpenTimeout:      *args.BreakerOpenTimeout,
+		}))
+	}
+	if *args.DryRun {
+		options = append(options, client.WithDryRun())
+	}
+	if len(auditSinks) > 0 {
+		options = append(options, client.WithAudit(auditOperator(args), auditSinks...))
+	}
+	return options
+}
+
+func newAuditSinks(args Arguments) ([]client.AuditSink, error) {
+	sinks := []client.AuditSink{}
+	if *args.AuditFile != "" {
+		fileSink, err := client.NewFileAuditSink(*args.AuditFile)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, fileSink)
+	}
+	if *args.AuditWebhook != "" {
+		sinks = append(sinks, client.NewWebhookAuditSink(*args.AuditWebhook, client.DefaultWebhookQueueSize, slog.Default()))
+	}
+	return sinks, nil
+}
+
+// closeAuditSinks writes the audit records still queued before kongo exits.
+func closeAuditSinks() {
+	for _, sink := range auditSinks {
+		if closer, ok := sink.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+}
+
+// auditOperator falls back on the user running kongo, or the host, e.g. the pod, it runs on.
+func auditOperator(args Arguments) string {
+	if *args.AuditOperator != "" {
+		return *args.AuditOperator
+	}
+	if user := os.Getenv("USER"); user != "" {
+		return user
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// newLogger writes the logs of kongo to stderr.
+func newLogger(level string, format string) (*slog.Logger, error) {
+	var leveler slog.Level
+	err := leveler.UnmarshalText([]byte(level))
+	if err != nil {
+		return nil, fmt.Errorf("unknown log level '%s', expected debug, info, warn or error", level)
+	}
+
+	options := &slog.HandlerOptions{Level: leveler}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, options)), nil
+	}
+	return nil, fmt.Errorf("unknown log format '%s', expected text or json", format)
+}
+
+// newMultiKongo names each cluster after its Kong admin API Url.
+func newMultiKongo(kongUris []string, naming client.NamingStrategy, args Arguments) (*client.MultiKongo, error) {
+	policy, err := client.ParseFanOutPolicy(*args.FanOutPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	clusters := make(map[string]*client.Kongo)
+	for _, kongUri := range kongUris {
+		kongUri := strings.TrimSpace(kongUri)
+		kongo, err := client.NewKongo(&kongUri, kongoOptions(args)...)
+		if err != nil {
+			return nil, err
+		}
+		kongo.SetNamingStrategy(naming)
+		clusters[kongUri] = kongo
+	}
+	return client.NewMultiKongo(policy, clusters)
+}
+
+func printClusterResults(results []*client.ClusterResult) {
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Printf("%s: failed: %v\n", result.Cluster, result.Err)
+		} else if result.Resources != nil {
+			fmt.Printf("%s: %s\n", result.Cluster, client.String(*result.Resources))
+		} else {
+			fmt.Printf("%s: done\n", result.Cluster)
+		}
+	}
+}
+
+func namingStrategy(args Arguments) (client.NamingStrategy, error) {
+	if *args.NamingTemplate == "" {
+		return client.DefaultNamingStrategy, nil
+	}
+	return client.NewTemplateNamingStrategy(*args.NamingTemplate, *args.Cluster)
 }
 
 func getCommands() map[string]Command {
 	commands := make(map[string]Command)
 
-	commands["clear-entries"] = Command{clearEntries, "Removes all entries identified by the given namespace and name"}
-	commands["register-test-resources"] = Command{registerTestResources, "Generates test entities in Kong"}
-	commands["deregister-test-resources"] = Command{deregisterTestResources, "Removes test resources from Kong"}
-	commands["list"] = Command{listAllThings, "Lists all entities within Kong"}
-	commands["truncate"] = Command{truncateKong, "Deletes all entities from Kong (USE WITH CAUTION)"}
-	commands["usage"] = Command{printUsage, "Shows the usage of the tool and available commands"}
+	commands["backup"] = Command{backupKong, "Writes all entities within Kong to a timestamped backup directory", nil}
+	commands["restore"] = Command{restoreKong, "Recreates the entities of a backup directory in Kong", nil}
+	commands["clear-entries"] = Command{clearEntries, "Removes all entries identified by the given namespace and name", clearEntriesMulti}
+	commands["register-test-resources"] = Command{registerTestResources, "Generates test entities in Kong", registerTestResourcesMulti}
+	commands["export"] = Command{exportDeckFile, "Writes all entities within Kong to a decK file", nil}
+	commands["import"] = Command{importDeckFile, "Creates or updates the entities described in a decK file", nil}
+	commands["reconcile"] = Command{runReconciler, "Keeps the services of a discovery file, of DNS SRV records and of the Consul catalog registered with Kong", nil}
+	commands["compare"] = Command{compareKongs, "Reports the entities missing on either side and the fields differing between the Kongs of -left and -right", nil}
+	commands["controller"] = Command{runController, "Registers the Kubernetes Services opted in by annotations in the given namespace, or all namespaces, with Kong", nil}
+	commands["deregister-test-resources"] = Command{deregisterTestResources, "Removes test resources from Kong", deregisterTestResourcesMulti}
+	commands["list"] = Command{listAllThings, "Lists all entities within Kong", nil}
+	commands["truncate"] = Command{truncateKong, "Deletes all entities from Kong (USE WITH CAUTION)", nil}
+	commands["usage"] = Command{printUsage, "Shows the usage of the tool and available commands", printUsageMulti}
 
 	return commands
 }
@@ -79,30 +385,237 @@ func clearEntries(kongo *client.Kongo, args Arguments) error {
 	return kongo.DeregisterK8sService(baseName)
 }
 
-func deregisterTestResources(kongo *client.Kongo, args Arguments) error {
-	k8sService := client.K8sService{
+func clearEntriesMulti(multi *client.MultiKongo, args Arguments) error {
+	if *arguments.Namespace == "" || *arguments.ServiceName == "" {
+		return fmt.Errorf("clear-entries expects the namespace and name, these were not provided. %v", args)
+	}
+
+	results, err := multi.DeregisterK8sService(fmt.Sprintf("%s.%s", *arguments.Namespace, *arguments.ServiceName))
+	printClusterResults(results)
+	return err
+}
+
+func runController(kongo *client.Kongo, args Arguments) error {
+	restConfig, err := clientcmd.BuildConfigFromFlags("", *args.Kubeconfig)
+	if err != nil {
+		return fmt.Errorf("error loading Kubernetes configuration: %v", err)
+	}
+
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("error creating Kubernetes client: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	err = serveMetrics(ctx, *args.MetricsAddress, controller.RegisterMetrics)
+	if err != nil {
+		return err
+	}
+
+	notReadyPolicy, err := controller.ParseNotReadyPolicy(*args.NotReady)
+	if err != nil {
+		return err
+	}
+
+	config := controller.Config{
+		Namespace:          *args.Namespace,
+		Workers:            *args.Workers,
+		NotReadyPolicy:     notReadyPolicy,
+		IngressClass:       *args.IngressClass,
+		FullResyncInterval: *args.FullResyncInterval,
+		GCGracePeriod:      *args.GCGracePeriod,
+		GCUntagged:         *args.GCUntagged,
+		Logger:             slog.Default(),
+	}
+	config.NamingStrategy, err = namingStrategy(args)
+	if err != nil {
+		return err
+	}
+
+	var dynamicClient dynamic.Interface
+	if *args.CRDs {
+		dynamicClient, err = dynamic.NewForConfig(restConfig)
+		if err != nil {
+			return fmt.Errorf("error creating Kubernetes dynamic client: %v", err)
+		}
+	}
+
+	run := func(ctx context.Context) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		if *args.Ingress {
+			ingressController := controller.NewIngressController(kongo, kubeClient, config)
+			go func() {
+				err := ingressController.Run(ctx)
+				if err != nil {
+					slog.Error("Ingress controller failed", "error", err)
+					cancel()
+				}
+			}()
+		}
+
+		if *args.CRDs {
+			crdController := controller.NewCRDController(kongo, dynamicClient, config)
+			go func() {
+				err := crdController.Run(ctx)
+				if err != nil {
+					slog.Error("CRD controller failed", "error", err)
+					cancel()
+				}
+			}()
+		}
+
+		kongoController := controller.NewController(kongo, kubeClient, config)
+		return kongoController.Run(ctx)
+	}
+
+	if !*args.LeaderElect {
+		return run(ctx)
+	}
+
+	leaderElectionConfig := controller.LeaderElectionConfig{
+		LeaseName:      *args.LeaseName,
+		LeaseNamespace: *args.LeaseNamespace,
+		LeaseDuration:  *args.LeaseDuration,
+		RenewDeadline:  *args.RenewDeadline,
+		RetryPeriod:    *args.RetryPeriod,
+		Logger:         slog.Default(),
+	}
+	return controller.RunWithLeaderElection(ctx, kubeClient, leaderElectionConfig, run)
+}
+
+func runReconciler(kongo *client.Kongo, args Arguments) error {
+	sources := []discovery.DiscoverySource{}
+	if *args.DiscoveryFile != "" {
+		sources = append(sources, discovery.NewFileSource(*args.DiscoveryFile))
+	}
+	if *args.SRVRecords != "" {
+		names, records, err := splitPairs(*args.SRVRecords)
+		if err != nil {
+			return fmt.Errorf("invalid SRV records: %v", err)
+		}
+		srvServices := []discovery.SRVService{}
+		for i := range names {
+			srvServices = append(srvServices, discovery.SRVService{Name: names[i], Record: records[i]})
+		}
+		sources = append(sources, discovery.NewDNSSource(*args.DNSServer, srvServices...))
+	}
+	if *args.ConsulServices != "" {
+		names, services, err := splitPairs(*args.ConsulServices)
+		if err != nil {
+			return fmt.Errorf("invalid Consul services: %v", err)
+		}
+		consulServices := []discovery.ConsulService{}
+		for i := range names {
+			consulServices = append(consulServices, discovery.ConsulService{Name: names[i], Service: services[i]})
+		}
+		sources = append(sources, discovery.NewConsulSource(*args.ConsulAddress, os.Getenv("CONSUL_HTTP_TOKEN"), slog.Default(), consulServices...))
+	}
+	if len(sources) == 0 {
+		return fmt.Errorf("reconcile expects a discovery file, SRV records or Consul services, none were provided. %v", args)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	err := serveMetrics(ctx, *args.MetricsAddress)
+	if err != nil {
+		return err
+	}
+
+	return discovery.NewReconciler(kongo, *args.DiscoveryInterval, slog.Default(), sources...).Run(ctx)
+}
+
+// setupTracing makes the exporter that of the global tracer provider kongo traces with, and W3C Trace Context its
+// propagator.
+func setupTracing(exporter string) error {
+	switch exporter {
+	case "none":
+		return nil
+	case "stdout":
+		stdoutExporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return fmt.Errorf("error creating the stdout trace exporter: %v", err)
+		}
+		// Spans are exported as they end, none being lost when a command exits.
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(stdoutExporter)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+		return nil
+	}
+	return fmt.Errorf("unknown trace exporter '%s', expected none or stdout", exporter)
+}
+
+// serveMetrics serves the Prometheus metrics at /metrics until the context is done, unless the address is empty. The
+// metrics of kongo are registered first, then those of the register functions.
+func serveMetrics(ctx context.Context, address string, register ...func(prometheus.Registerer) error) error {
+	if address == "" {
+		return nil
+	}
+	for _, registerMetrics := range append([]func(prometheus.Registerer) error{client.RegisterMetrics}, register...) {
+		err := registerMetrics(prometheus.DefaultRegisterer)
+		if err != nil {
+			return err
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{Addr: address, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("serving metrics on '%s' failed: %v", address, err)
+		}
+	}()
+	return nil
+}
+
+// splitPairs splits comma separated name=value pairs.
+func splitPairs(pairs string) (names []string, values []string, err error) {
+	for _, pair := range strings.Split(pairs, ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found || name == "" || value == "" {
+			return nil, nil, fmt.Errorf("'%s' is not name=value", pair)
+		}
+		names = append(names, name)
+		values = append(values, value)
+	}
+	return names, values, nil
+}
+
+func testK8sService() *client.K8sService {
+	return &client.K8sService{
 		Addresses: []*string{kong.String("localhost")},
 		Name:      "kongo.test-service-one",
 		Path:      "/testing-1-2-3",
 		Port:      80,
 	}
+}
 
-	err := kongo.DeregisterK8sService(k8sService.Name)
+func deregisterTestResources(kongo *client.Kongo, args Arguments) error {
+	err := kongo.DeregisterK8sService(testK8sService().Name)
 	if err != nil {
 		return fmt.Errorf("None delete the things: %v", err)
 	}
 	return nil
 }
 
-func registerTestResources(kongo *client.Kongo, args Arguments) error {
-	k8sService := client.K8sService{
-		Addresses: []*string{kong.String("localhost")},
-		Name:      "kongo.test-service-one",
-		Path:      "/testing-1-2-3",
-		Port:      80,
+func deregisterTestResourcesMulti(multi *client.MultiKongo, args Arguments) error {
+	results, err := multi.DeregisterK8sService(testK8sService().Name)
+	printClusterResults(results)
+	if err != nil {
+		return fmt.Errorf("None delete the things: %v", err)
 	}
+	return nil
+}
 
-	registered, err := kongo.RegisterK8sService(&k8sService)
+func registerTestResources(kongo *client.Kongo, args Arguments) error {
+	registered, err := kongo.RegisterK8sService(testK8sService())
 	if err != nil {
 		_, err = fmt.Println("None create the things: ", err)
 		return err
@@ -112,6 +625,108 @@ func registerTestResources(kongo *client.Kongo, args Arguments) error {
 	return err
 }
 
+func registerTestResourcesMulti(multi *client.MultiKongo, args Arguments) error {
+	results, err := multi.RegisterK8sService(testK8sService())
+	printClusterResults(results)
+	if err != nil {
+		return fmt.Errorf("None create the things: %v", err)
+	}
+	return nil
+}
+
+func backupKong(kongo *client.Kongo, args Arguments) error {
+	dir, err := kongo.Backup(*args.BackupDir, *args.Compress)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Backup written to: ", dir)
+	return nil
+}
+
+func restoreKong(kongo *client.Kongo, args Arguments) error {
+	if *args.Snapshot == "" {
+		return fmt.Errorf("restore expects the snapshot directory, this was not provided. %v", args)
+	}
+
+	strategy, err := client.ParseConflictStrategy(*args.Conflict)
+	if err != nil {
+		return err
+	}
+
+	return kongo.Restore(*args.Snapshot, strategy)
+}
+
+func exportDeckFile(kongo *client.Kongo, args Arguments) error {
+	state, err := kongo.LoadKongState()
+	if err != nil {
+		return err
+	}
+
+	deckFile := client.NewDeckFile(state, splitList(*args.SelectTags), nil)
+	return client.WriteDeckFile(*args.File, deckFile)
+}
+
+func importDeckFile(kongo *client.Kongo, args Arguments) error {
+	deckFile, err := client.ReadDeckFile(*args.File)
+	if err != nil {
+		return err
+	}
+
+	if *args.SelectTags != "" {
+		if deckFile.Info == nil {
+			deckFile.Info = new(client.DeckInfo)
+		}
+		deckFile.Info.SelectTags = splitList(*args.SelectTags)
+	}
+
+	state, err := deckFile.KongState()
+	if err != nil {
+		return err
+	}
+
+	return kongo.ApplyKongState(state)
+}
+
+func splitList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+func compareKongs(kongo *client.Kongo, args Arguments) error {
+	if *args.Left == "" || *args.Right == "" {
+		return fmt.Errorf("compare expects the left and right Urls, these were not provided. %v", args)
+	}
+
+	states := []*client.KongState{}
+	for _, kongUri := range []*string{args.Left, args.Right} {
+		kongo, err := client.NewKongo(kongUri, kongoOptions(args)...)
+		if err != nil {
+			return err
+		}
+		state, err := kongo.LoadKongState()
+		if err != nil {
+			return fmt.Errorf("error loading '%s': %v", *kongUri, err)
+		}
+		states = append(states, state)
+	}
+
+	differences, err := client.CompareKongStates(states[0], states[1], splitList(*args.Ignore))
+	if err != nil {
+		return err
+	}
+	for _, difference := range differences {
+		fmt.Println(difference)
+	}
+	if len(differences) > 0 {
+		return fmt.Errorf("found %d differences between '%s' and '%s'", len(differences), *args.Left, *args.Right)
+	}
+	fmt.Printf("no differences between '%s' and '%s'\n", *args.Left, *args.Right)
+	return nil
+}
+
 func listAllThings(kongo *client.Kongo, args Arguments) error {
 	upstreams, err := kongo.ListUpstreams()
 	if err != nil {
@@ -173,9 +788,33 @@ func printUsage(kongo *client.Kongo, args Arguments) error {
 	return nil
 }
 
+func printUsageMulti(multi *client.MultiKongo, args Arguments) error {
+	return printUsage(nil, args)
+}
+
 func truncateKong(kongo *client.Kongo, args Arguments) error {
-	if askForConfirmation("THIS WILL DELETE ALL KONG ENTRIES, ARE YOU SURE?") {
-		err := kongo.DeleteAllTargets()
+	// Nothing is deleted by a dry run, which needs no confirmation.
+	if kongo.Plan() != nil || askForConfirmation("THIS WILL DELETE ALL KONG ENTRIES, ARE YOU SURE?") {
+		// A dry run leaves Kong as it is, there is nothing to back up.
+		if kongo.Plan() == nil {
+			dir, err := kongo.Backup(*args.BackupDir, *args.Compress)
+			if err != nil {
+				return fmt.Errorf("error backing up Kong before truncating, nothing was deleted: %v", err)
+			}
+			fmt.Println("Backup written to: ", dir)
+		}
+
+		// Interrupting stops the deletions not yet started.
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+		kongo = kongo.WithContext(ctx)
+
+		err := kongo.DeleteAllPlugins()
+		if err != nil {
+			fmt.Println("Error deleting all Plugins: ", err)
+		}
+
+		err = kongo.DeleteAllTargets()
 		if err != nil {
 			fmt.Println("Error deleting all Targets: ", err)
 		}
@@ -194,6 +833,12 @@ func truncateKong(kongo *client.Kongo, args Arguments) error {
 		if err != nil {
 			fmt.Println("Error deleting all Streams: ", err)
 		}
+
+		// Last, the Services and Upstreams which could use them as client certificates being gone.
+		err = kongo.DeleteAllCertificates()
+		if err != nil {
+			fmt.Println("Error deleting all Certificates: ", err)
+		}
 	}
 
 	return nil